@@ -11,26 +11,31 @@ import (
 // format and returns it as a string. The string is ready to be written into the
 // body of an HTTP Request.
 func Query(opts ...Option) (string, error) {
-	for _, opt := range opts {
-		if opt == nil {
-			return "", errors.New("a provided option is nil")
-		}
-	}
-	filters, err := newFilters(opts...)
+	filters, err := queryFilters(opts...)
 	if err != nil {
-		return "", errors.Wrap(err, "cannot create new filter map")
+		return "", err
 	}
 
 	return toString(filters), nil
 }
 
 // NewRequest returns a request configured for the provided url using the provided method.
-// The provided query options are written to the body of the request. The default method is GET.
+// The provided query options are written to the body of the request. The method must be GET,
+// POST, or PUT, as allowed by the Apicalypse specifications, in any case; a blank method
+// defaults to GET, the method recommended by the specifications.
+// The body of the returned request can be rewound with its GetBody function so that the same
+// query can be sent again, for example when a Client retries the request. To write the
+// query into the url instead, use NewEncodedRequest with URLEncoding.
 func NewRequest(method string, url string, opts ...Option) (*http.Request, error) {
 	if blank.Is(url) {
 		return nil, ErrBlankArgument
 	}
 
+	method, err := normalizeMethod(method)
+	if err != nil {
+		return nil, err
+	}
+
 	q, err := Query(opts...)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot create a query")
@@ -64,6 +64,8 @@ func TestNewRequest(t *testing.T) {
 		{"Empty method, empty url, zero options", "", "", nil, nil, ErrBlankArgument},
 		{"Empty method, empty url, single option", "", "", []Option{Limit(15)}, nil, ErrBlankArgument},
 		{"Empty method, empty url, error option", "", "", []Option{Limit(-99)}, nil, ErrBlankArgument},
+		{"Lowercase method, non-empty url, zero options", "post", "http://fake.com/", nil, httptest.NewRequest("POST", "http://fake.com/", nil), nil},
+		{"DELETE method, non-empty url, zero options", "DELETE", "http://fake.com/", nil, nil, ErrInvalidMethod},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -88,6 +90,10 @@ func TestNewRequest(t *testing.T) {
 				t.Errorf("got: <%v>, want: <%v>", req.Body, test.wantRequest.Body)
 			}
 
+			if req.GetBody == nil {
+				t.Errorf("got: <%v>, want: <%v>", "nil GetBody", "non-nil GetBody")
+			}
+
 		})
 	}
 }
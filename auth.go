@@ -0,0 +1,316 @@
+package apicalypse
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Henry-Sarabia/blank"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRefreshBefore is how long before it expires a token is refreshed by default.
+	defaultRefreshBefore = time.Minute
+	// tokenTimeout caps how long a single token request may take.
+	tokenTimeout = 30 * time.Second
+)
+
+// Credentials configure a TokenSource to fetch access tokens with the OAuth2
+// client credentials flow, as required by APIs such as IGDB, whose tokens are
+// issued by Twitch at https://id.twitch.tv/oauth2/token.
+type Credentials struct {
+	// TokenURL is the url of the token endpoint.
+	TokenURL string
+	// ClientID is the client's ID. It is sent to the token endpoint and, as the
+	// Client-ID header, with every authorized request.
+	ClientID string
+	// ClientSecret is the client's secret. It is only sent to the token endpoint.
+	ClientSecret string
+	// Scopes optionally lists the scopes to request.
+	Scopes []string
+	// HTTPClient is used to send token requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// RefreshBefore is how long before a token expires it is refreshed in the
+	// background, so that requests never wait for a token once the first one is
+	// fetched. It is capped at half of the token's lifetime, so that short-lived
+	// tokens are not refreshed on every request. Defaults to one minute.
+	RefreshBefore time.Duration
+}
+
+// Token is an OAuth2 access token.
+type Token struct {
+	// AccessToken is the token sent in the Authorization header.
+	AccessToken string
+	// TokenType is the type of the token, such as "Bearer".
+	TokenType string
+	// Expiry is when the token expires. The zero time means it does not expire.
+	Expiry time.Time
+}
+
+// TokenSource fetches, caches, and refreshes access tokens using the OAuth2 client
+// credentials flow. A token is fetched when it is first needed and refreshed in the
+// background shortly before it expires; concurrent callers share a single token
+// request. A TokenSource is safe for concurrent use by multiple goroutines.
+type TokenSource struct {
+	creds Credentials
+	now   func() time.Time
+
+	mu        sync.Mutex
+	token     *Token
+	refreshAt time.Time
+	err       error
+	fetching  chan struct{}
+}
+
+// NewTokenSource returns a TokenSource that fetches tokens using the provided
+// credentials.
+func NewTokenSource(creds Credentials) (*TokenSource, error) {
+	if blank.Is(creds.TokenURL) || blank.Is(creds.ClientID) || blank.Is(creds.ClientSecret) {
+		return nil, ErrBlankArgument
+	}
+	if creds.RefreshBefore < 0 {
+		return nil, ErrNegativeInput
+	}
+	if creds.RefreshBefore == 0 {
+		creds.RefreshBefore = defaultRefreshBefore
+	}
+	if creds.HTTPClient == nil {
+		creds.HTTPClient = http.DefaultClient
+	}
+
+	return &TokenSource{creds: creds, now: time.Now}, nil
+}
+
+// WithTokenSource is a functional option for authorizing every request the Client
+// sends with tokens from the provided TokenSource. It is equivalent to WithMiddleware
+// with the TokenSource's Middleware.
+func WithTokenSource(ts *TokenSource) ClientOption {
+	return func(c *Client) error {
+		if ts == nil {
+			return ErrMissingInput
+		}
+
+		return WithMiddleware(ts.Middleware())(c)
+	}
+}
+
+// Token returns a valid token, fetching one if there is no cached token or the
+// cached token has expired. If the cached token is about to expire, it is returned
+// while a new one is fetched in the background.
+func (ts *TokenSource) Token(ctx context.Context) (Token, error) {
+	for {
+		ts.mu.Lock()
+		if tok := ts.token; ts.valid(tok) {
+			if ts.fetching == nil && !tok.Expiry.IsZero() && !ts.now().Before(ts.refreshAt) {
+				ts.refresh()
+			}
+			ts.mu.Unlock()
+			return *tok, nil
+		}
+
+		done := ts.fetching
+		if done == nil {
+			done = ts.refresh()
+		}
+		ts.mu.Unlock()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return Token{}, errors.Wrap(ctx.Err(), "cannot wait for token")
+		}
+
+		ts.mu.Lock()
+		tok, err := ts.token, ts.err
+		valid := ts.valid(tok)
+		ts.mu.Unlock()
+
+		switch {
+		case err != nil:
+			return Token{}, err
+		case valid:
+			return *tok, nil
+		case tok != nil:
+			return Token{}, errors.New("fetched token expired before it could be used")
+		}
+		// The fetched token was discarded by invalidate before it could be used,
+		// so another one is fetched.
+	}
+}
+
+// Authorize sets the Authorization and Client-ID headers of the provided request,
+// such as one created by NewRequest, using a token from Token. Requests sent by a
+// Client with WithTokenSource are authorized automatically.
+func (ts *TokenSource) Authorize(req *http.Request) error {
+	tok, err := ts.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	ts.setHeaders(req, tok)
+
+	return nil
+}
+
+// Middleware returns Middleware that authorizes every request with a token from
+// Token. If the API responds with 401 Unauthorized, the token is discarded and the
+// request is sent once more with a new token, provided its body can be rewound.
+func (ts *TokenSource) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			tok, err := ts.Token(req.Context())
+			if err != nil {
+				if req.Body != nil {
+					req.Body.Close()
+				}
+				return nil, errors.Wrap(err, "cannot authorize request")
+			}
+
+			r := req.Clone(req.Context())
+			ts.setHeaders(r, tok)
+			resp, err := next.RoundTrip(r)
+			if err != nil || resp.StatusCode != http.StatusUnauthorized {
+				return resp, err
+			}
+
+			if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+				return resp, nil
+			}
+			ts.invalidate(tok)
+
+			tok, err = ts.Token(req.Context())
+			if err != nil {
+				return resp, nil
+			}
+			r, err = rewind(req)
+			if err != nil {
+				return resp, nil
+			}
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+
+			ts.setHeaders(r, tok)
+			return next.RoundTrip(r)
+		})
+	}
+}
+
+// setHeaders sets the Authorization and Client-ID headers of the provided request.
+func (ts *TokenSource) setHeaders(req *http.Request, tok Token) {
+	req.Header.Set("Authorization", tok.TokenType+" "+tok.AccessToken)
+	req.Header.Set("Client-ID", ts.creds.ClientID)
+}
+
+// valid reports whether the provided token exists and has not expired. The caller
+// must hold ts.mu.
+func (ts *TokenSource) valid(tok *Token) bool {
+	return tok != nil && (tok.Expiry.IsZero() || ts.now().Before(tok.Expiry))
+}
+
+// invalidate discards the cached token if it is the provided token, so that the
+// next call to Token fetches a new one.
+func (ts *TokenSource) invalidate(tok Token) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token != nil && ts.token.AccessToken == tok.AccessToken {
+		ts.token = nil
+	}
+}
+
+// refresh starts fetching a new token in the background and returns a channel
+// that is closed once it is done. The caller must hold ts.mu.
+func (ts *TokenSource) refresh() chan struct{} {
+	done := make(chan struct{})
+	ts.fetching = done
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tokenTimeout)
+		defer cancel()
+		start := ts.now()
+		tok, err := ts.fetch(ctx)
+
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+		if err == nil {
+			ts.token = &tok
+			ts.refreshAt = refreshTime(start, tok.Expiry, ts.creds.RefreshBefore)
+		}
+		ts.err = err
+		ts.fetching = nil
+		close(done)
+	}()
+
+	return done
+}
+
+// refreshTime returns when a token fetched at the provided time and expiring at
+// the provided expiry is refreshed in the background: the provided duration
+// before it expires, but no earlier than halfway through its lifetime.
+func refreshTime(fetched, expiry time.Time, before time.Duration) time.Time {
+	if half := expiry.Sub(fetched) / 2; before > half {
+		before = half
+	}
+
+	return expiry.Add(-before)
+}
+
+// tokenResponse is the body of a successful response from a token endpoint.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// fetch requests a new token from the token endpoint.
+func (ts *TokenSource) fetch(ctx context.Context) (Token, error) {
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {ts.creds.ClientID},
+		"client_secret": {ts.creds.ClientSecret},
+	}
+	if len(ts.creds.Scopes) > 0 {
+		form.Set("scope", strings.Join(ts.creds.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.creds.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, errors.Wrapf(err, "cannot create token request for url '%s'", ts.creds.TokenURL)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	start := ts.now()
+	resp, err := ts.creds.HTTPClient.Do(req)
+	if err != nil {
+		return Token{}, errors.Wrap(err, "cannot request token")
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return Token{}, errors.Wrap(err, "cannot request token")
+	}
+
+	var tr tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return Token{}, errors.Wrap(err, "cannot decode token response")
+	}
+	if tr.AccessToken == "" {
+		return Token{}, errors.New("token response is missing an access token")
+	}
+
+	tok := Token{AccessToken: tr.AccessToken, TokenType: tr.TokenType}
+	if tok.TokenType == "" || strings.EqualFold(tok.TokenType, "bearer") {
+		tok.TokenType = "Bearer"
+	}
+	if tr.ExpiresIn > 0 {
+		tok.Expiry = start.Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+
+	return tok, nil
+}
@@ -0,0 +1,303 @@
+package apicalypse
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTokenServer returns a token endpoint stub that issues the tokens "t1", "t2",
+// and so on, each expiring after the provided number of seconds, and a counter of
+// the tokens it issued.
+func newTokenServer(t *testing.T, expiresIn int) (*httptest.Server, *int32) {
+	var issued int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		if r.PostForm.Get("grant_type") != "client_credentials" || r.PostForm.Get("client_id") != "id" {
+			t.Errorf("got: <%v>, want: <%v>", r.PostForm, "client credentials of 'id'")
+		}
+		if r.PostForm.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"status":403,"message":"invalid client secret"}`))
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		n := atomic.AddInt32(&issued, 1)
+		fmt.Fprintf(w, `{"access_token":"t%d","expires_in":%d,"token_type":"bearer"}`, n, expiresIn)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts, &issued
+}
+
+func TestNewTokenSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		creds   Credentials
+		wantErr error
+	}{
+		{"Valid credentials", Credentials{TokenURL: "http://fake.com/", ClientID: "id", ClientSecret: "secret"}, nil},
+		{"Blank token url", Credentials{ClientID: "id", ClientSecret: "secret"}, ErrBlankArgument},
+		{"Blank client ID", Credentials{TokenURL: "http://fake.com/", ClientSecret: "secret"}, ErrBlankArgument},
+		{"Blank client secret", Credentials{TokenURL: "http://fake.com/", ClientID: "id"}, ErrBlankArgument},
+		{"Negative refresh", Credentials{TokenURL: "http://fake.com/", ClientID: "id", ClientSecret: "secret", RefreshBefore: -1}, ErrNegativeInput},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewTokenSource(test.creds)
+			if errors.Cause(err) != test.wantErr {
+				t.Errorf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestTokenSourceToken(t *testing.T) {
+	srv, issued := newTokenServer(t, 3600)
+
+	ts, err := NewTokenSource(Credentials{TokenURL: srv.URL, ClientID: "id", ClientSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	ts.now = func() time.Time { return now }
+
+	token := func() Token {
+		tok, err := ts.Token(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+
+	tok := token()
+	if tok.AccessToken != "t1" || tok.TokenType != "Bearer" {
+		t.Errorf("got: <%v>, want: <%v>", tok, "Bearer t1")
+	}
+	if !tok.Expiry.Equal(now.Add(time.Hour)) {
+		t.Errorf("got: <%v>, want: <%v>", tok.Expiry, now.Add(time.Hour))
+	}
+
+	if tok := token(); tok.AccessToken != "t1" || atomic.LoadInt32(issued) != 1 {
+		t.Errorf("got: <%v> after <%v> token requests, want: <%v> after <%v>", tok.AccessToken, *issued, "t1", 1)
+	}
+
+	// A token about to expire is still returned while a new one is fetched.
+	now = now.Add(time.Hour - 30*time.Second)
+	if tok := token(); tok.AccessToken != "t1" {
+		t.Errorf("got: <%v>, want: <%v>", tok.AccessToken, "t1")
+	}
+	for i := 0; i < 100 && token().AccessToken == "t1"; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if tok := token(); tok.AccessToken != "t2" {
+		t.Errorf("got: <%v>, want: <%v>", tok.AccessToken, "t2")
+	}
+
+	// An expired token is never returned.
+	now = now.Add(2 * time.Hour)
+	if tok := token(); tok.AccessToken != "t3" {
+		t.Errorf("got: <%v>, want: <%v>", tok.AccessToken, "t3")
+	}
+}
+
+func TestTokenSourceShortLived(t *testing.T) {
+	srv, issued := newTokenServer(t, 60)
+
+	ts, err := NewTokenSource(Credentials{TokenURL: srv.URL, ClientID: "id", ClientSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	now := time.Now()
+	ts.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	token := func() Token {
+		tok, err := ts.Token(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+
+	// A token whose lifetime is shorter than RefreshBefore is not refreshed on
+	// every call.
+	token()
+	mu.Lock()
+	now = now.Add(time.Second)
+	mu.Unlock()
+	for i := 0; i < 5; i++ {
+		token()
+		time.Sleep(20 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(issued); n != 1 {
+		t.Errorf("got: <%v>, want: <%v>", n, 1)
+	}
+
+	// It is refreshed halfway through its lifetime.
+	mu.Lock()
+	now = now.Add(30 * time.Second)
+	mu.Unlock()
+	for i := 0; i < 100 && token().AccessToken == "t1"; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < 5; i++ {
+		token()
+		time.Sleep(20 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(issued); n != 2 {
+		t.Errorf("got: <%v>, want: <%v>", n, 2)
+	}
+}
+
+func TestTokenSourceExpiredToken(t *testing.T) {
+	srv, _ := newTokenServer(t, 1)
+
+	ts, err := NewTokenSource(Credentials{TokenURL: srv.URL, ClientID: "id", ClientSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	now := time.Now()
+	ts.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(time.Second)
+		return now
+	}
+
+	tok, err := ts.Token(context.Background())
+	if err == nil {
+		t.Errorf("got: <%v>, want: <%v>", tok, "an error")
+	}
+	if tok != (Token{}) {
+		t.Errorf("got: <%v>, want: <%v>", tok, Token{})
+	}
+}
+
+func TestTokenSourceConcurrent(t *testing.T) {
+	srv, issued := newTokenServer(t, 3600)
+
+	ts, err := NewTokenSource(Credentials{TokenURL: srv.URL, ClientID: "id", ClientSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ts.Token(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(issued); n != 1 {
+		t.Errorf("got: <%v>, want: <%v>", n, 1)
+	}
+}
+
+func TestTokenSourceError(t *testing.T) {
+	srv, _ := newTokenServer(t, 3600)
+
+	ts, err := NewTokenSource(Credentials{TokenURL: srv.URL, ClientID: "id", ClientSecret: "wrong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ts.Token(context.Background())
+	serr, ok := errors.Cause(err).(*StatusError)
+	if !ok || serr.StatusCode != http.StatusForbidden {
+		t.Errorf("got: <%v>, want: <%v>", err, http.StatusForbidden)
+	}
+}
+
+func TestTokenSourceMiddleware(t *testing.T) {
+	srv, issued := newTokenServer(t, 3600)
+
+	var calls int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.Header.Get("Client-ID") != "id" {
+			t.Errorf("got: <%v>, want: <%v>", r.Header.Get("Client-ID"), "id")
+		}
+		if r.Header.Get("Authorization") == "Bearer t1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(r.Header.Get("Authorization") + "|" + string(b)))
+	}))
+	defer api.Close()
+
+	ts, err := NewTokenSource(Credentials{TokenURL: srv.URL, ClientID: "id", ClientSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewClient(WithTokenSource(ts))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := c.Send(context.Background(), "POST", api.URL, Limit(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Bearer t2|limit 5; "
+	if got := readBody(t, resp); got != want {
+		t.Errorf("got: <%v>, want: <%v>", got, want)
+	}
+
+	if calls != 2 || atomic.LoadInt32(issued) != 2 {
+		t.Errorf("got: <%v> calls and <%v> tokens, want: <%v> and <%v>", calls, *issued, 2, 2)
+	}
+
+	if _, err := NewClient(WithTokenSource(nil)); errors.Cause(err) != ErrMissingInput {
+		t.Errorf("got: <%v>, want: <%v>", err, ErrMissingInput)
+	}
+}
+
+func TestTokenSourceAuthorize(t *testing.T) {
+	srv, _ := newTokenServer(t, 0)
+
+	ts, err := NewTokenSource(Credentials{TokenURL: srv.URL, ClientID: "id", ClientSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := NewRequest("POST", "http://fake.com/", Limit(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.Authorize(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := req.Header.Get("Authorization"); got != "Bearer t1" {
+		t.Errorf("got: <%v>, want: <%v>", got, "Bearer t1")
+	}
+
+	if got := req.Header.Get("Client-ID"); got != "id" {
+		t.Errorf("got: <%v>, want: <%v>", got, "id")
+	}
+}
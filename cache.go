@@ -0,0 +1,296 @@
+package apicalypse
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+	"time"
+)
+
+// Cache is a storage backend for cached responses. Values are opaque byte slices,
+// so a Cache can be implemented on top of any key-value store such as Redis or
+// Memcached. Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the value stored under the provided key and whether it was found.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores the provided value under the provided key for the duration of ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// CachePolicy configures how a Client caches responses.
+//
+// Responses are cached by request method, URL, and canonical query text, so queries
+// built from equivalent options share a cache entry regardless of the order of
+// their options or fields. Requests that carry their own credentials or Accept
+// header, such as an Authorization header set on the request itself, only share
+// entries with requests carrying the same values. Only successful responses to idempotent requests are
+// cached. Errors returned by the Cache are treated as cache misses and never cause
+// a request to fail.
+type CachePolicy struct {
+	// Cache is the backend used to store responses.
+	Cache Cache
+	// TTL is how long a response remains cached.
+	TTL time.Duration
+	// MaxEntrySize is the largest response body in bytes that will be cached.
+	// Larger responses are returned to the caller without being cached.
+	// Zero means there is no limit.
+	MaxEntrySize int64
+}
+
+// WithCache is a functional option for setting the CachePolicy the Client uses
+// to cache responses. Use BypassCache to skip the cache for a single request.
+func WithCache(p CachePolicy) ClientOption {
+	return func(c *Client) error {
+		if p.Cache == nil {
+			return ErrMissingInput
+		}
+		if p.TTL < 0 || p.MaxEntrySize < 0 {
+			return ErrNegativeInput
+		}
+		c.cache = &p
+
+		return nil
+	}
+}
+
+type bypassCacheKey struct{}
+
+// BypassCache returns a copy of the provided context that makes a Client skip
+// its cache lookup for any request sent with it. The fresh response still
+// replaces the cached one.
+func BypassCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassCacheKey{}, true)
+}
+
+// bypassed reports whether the provided context was created by BypassCache.
+func bypassed(ctx context.Context) bool {
+	b, _ := ctx.Value(bypassCacheKey{}).(bool)
+	return b
+}
+
+// cacheKey returns the key for the provided request and whether it is cacheable.
+func cacheKey(req *http.Request) (string, bool) {
+	if !isIdempotent(req) {
+		return "", false
+	}
+
+	q, ok := queryText(req)
+	if !ok {
+		return "", false
+	}
+
+	return req.Method + " " + req.URL.String() + "\n" + headerDigest(req.Header) + "\n" + canonicalize(q), true
+}
+
+// headerDigest returns a hash of the values of the Accept header and the
+// CredentialHeaders of the provided header, so that responses are never shared
+// between requests made with different credentials or asking for different
+// representations. Credentials never appear in cache keys in the clear.
+func headerDigest(h http.Header) string {
+	sum := sha256.New()
+	for _, k := range append([]string{"Accept"}, CredentialHeaders...) {
+		for _, v := range h[http.CanonicalHeaderKey(k)] {
+			io.WriteString(sum, k+": "+v+"\n")
+		}
+	}
+
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// queryText returns the query text of the provided request without consuming
+// its body and whether the body could be read.
+func queryText(req *http.Request) (string, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return "", true
+	}
+
+	if req.GetBody == nil {
+		return "", false
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return "", false
+	}
+	defer body.Close()
+
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return "", false
+	}
+
+	return string(b), true
+}
+
+// lookup returns the cached response stored under the provided key or nil if
+// there is none.
+func (p *CachePolicy) lookup(ctx context.Context, key string, req *http.Request) *http.Response {
+	b, ok, err := p.Cache.Get(ctx, key)
+	if err != nil || !ok {
+		return nil
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(b)), req)
+	if err != nil {
+		return nil
+	}
+
+	return resp
+}
+
+// store caches the provided response under the provided key if it is successful
+// and small enough. It returns a response equivalent to the one provided, which
+// must be used in its place.
+func (p *CachePolicy) store(ctx context.Context, key string, resp *http.Response) (*http.Response, error) {
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	r := io.Reader(resp.Body)
+	if p.MaxEntrySize > 0 {
+		r = io.LimitReader(resp.Body, p.MaxEntrySize+1)
+	}
+
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		resp.Body.Close()
+		return nil, errors.Wrap(err, "cannot read response body")
+	}
+
+	if p.MaxEntrySize > 0 && int64(len(body)) > p.MaxEntrySize {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+
+	if err := resp.Body.Close(); err != nil {
+		return nil, errors.Wrap(err, "cannot close response body")
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	b, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot serialize response")
+	}
+	p.Cache.Set(ctx, key, b, p.TTL)
+
+	return resp, nil
+}
+
+// MemoryCache is an in-memory Cache that evicts the least recently used entries
+// once it is full. Expired entries are removed when they are next accessed.
+// A MemoryCache is safe for concurrent use.
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	size       int64
+	ll         *list.List
+	entries    map[string]*list.Element
+	now        func() time.Time
+}
+
+// memoryEntry is a single entry in a MemoryCache.
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryCache returns a MemoryCache that holds up to maxEntries entries and up
+// to maxBytes bytes of values. A value of zero disables the respective limit.
+func NewMemoryCache(maxEntries int, maxBytes int64) (*MemoryCache, error) {
+	if maxEntries < 0 || maxBytes < 0 {
+		return nil, ErrNegativeInput
+	}
+
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ll:         list.New(),
+		entries:    map[string]*list.Element{},
+		now:        time.Now,
+	}, nil
+}
+
+// Get returns the value stored under the provided key and whether it was found.
+// The returned error is always nil.
+func (m *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	e := el.Value.(*memoryEntry)
+	if !e.expires.IsZero() && !m.now().Before(e.expires) {
+		m.remove(el)
+		return nil, false, nil
+	}
+	m.ll.MoveToFront(el)
+
+	return e.value, true, nil
+}
+
+// Set stores the provided value under the provided key for the duration of ttl.
+// A ttl of zero means the entry does not expire. Values larger than the cache
+// itself are not stored. The returned error is always nil.
+func (m *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.entries[key]; ok {
+		m.remove(el)
+	}
+
+	if m.maxBytes > 0 && int64(len(value)) > m.maxBytes {
+		return nil
+	}
+
+	e := &memoryEntry{key: key, value: value}
+	if ttl > 0 {
+		e.expires = m.now().Add(ttl)
+	}
+	m.entries[key] = m.ll.PushFront(e)
+	m.size += int64(len(value))
+
+	for m.full() {
+		m.remove(m.ll.Back())
+	}
+
+	return nil
+}
+
+// Len returns the number of entries in the cache, including expired entries
+// that have not been removed yet.
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.ll.Len()
+}
+
+// full reports whether the cache exceeds any of its limits.
+func (m *MemoryCache) full() bool {
+	return (m.maxEntries > 0 && m.ll.Len() > m.maxEntries) || (m.maxBytes > 0 && m.size > m.maxBytes)
+}
+
+// remove removes the provided element from the cache.
+func (m *MemoryCache) remove(el *list.Element) {
+	e := m.ll.Remove(el).(*memoryEntry)
+	delete(m.entries, e.key)
+	m.size -= int64(len(e.value))
+}
@@ -0,0 +1,236 @@
+package apicalypse
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithCache(t *testing.T) {
+	m, err := NewMemoryCache(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		policy  CachePolicy
+		wantErr error
+	}{
+		{"Valid policy", CachePolicy{Cache: m, TTL: time.Minute, MaxEntrySize: 1024}, nil},
+		{"Missing cache", CachePolicy{TTL: time.Minute}, ErrMissingInput},
+		{"Negative TTL", CachePolicy{Cache: m, TTL: -time.Minute}, ErrNegativeInput},
+		{"Negative max entry size", CachePolicy{Cache: m, MaxEntrySize: -1}, ErrNegativeInput},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewClient(WithCache(test.policy))
+			if !reflect.DeepEqual(errors.Cause(err), test.wantErr) {
+				t.Errorf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxEntries int
+		maxBytes   int64
+		wantKeys   []string
+	}{
+		{"No limits", 0, 0, []string{"a", "b", "c"}},
+		{"Entry limit", 2, 0, []string{"a", "c"}},
+		{"Byte limit", 0, 6, []string{"a", "c"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := NewMemoryCache(test.maxEntries, test.maxBytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			m.Set(ctx, "a", []byte("aaa"), 0)
+			m.Set(ctx, "b", []byte("bbb"), 0)
+			// Using "a" makes "b" the least recently used entry.
+			m.Get(ctx, "a")
+			m.Set(ctx, "c", []byte("ccc"), 0)
+
+			var got []string
+			for _, k := range []string{"a", "b", "c"} {
+				if _, ok, _ := m.Get(ctx, k); ok {
+					got = append(got, k)
+				}
+			}
+
+			if !reflect.DeepEqual(got, test.wantKeys) {
+				t.Errorf("got: <%v>, want: <%v>", got, test.wantKeys)
+			}
+		})
+	}
+}
+
+func TestMemoryCacheExpiration(t *testing.T) {
+	m, err := NewMemoryCache(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	m.Set(ctx, "short", []byte("x"), time.Second)
+	m.Set(ctx, "long", []byte("x"), time.Hour)
+	m.Set(ctx, "forever", []byte("x"), 0)
+
+	now = now.Add(time.Minute)
+
+	tests := []struct {
+		key    string
+		wantOk bool
+	}{
+		{"short", false},
+		{"long", true},
+		{"forever", true},
+	}
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			_, ok, _ := m.Get(ctx, test.key)
+			if ok != test.wantOk {
+				t.Errorf("got: <%v>, want: <%v>", ok, test.wantOk)
+			}
+		})
+	}
+
+	if m.Len() != 2 {
+		t.Errorf("got: <%v>, want: <%v>", m.Len(), 2)
+	}
+}
+
+func TestClientDoCache(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		maxEntrySize int64
+		second       []Option
+		bypass       bool
+		wantRequests int32
+	}{
+		{"Identical query", 200, "[]", 0, []Option{Fields("name", "id"), Limit(5)}, false, 1},
+		{"Equivalent query", 200, "[]", 0, []Option{Limit(5), Fields("id", "name", "id")}, false, 1},
+		{"Different query", 200, "[]", 0, []Option{Fields("name", "id"), Limit(6)}, false, 2},
+		{"Bypassed cache", 200, "[]", 0, []Option{Fields("name", "id"), Limit(5)}, true, 2},
+		{"Unsuccessful response", 500, "[]", 0, []Option{Fields("name", "id"), Limit(5)}, false, 2},
+		{"Oversized response", 200, strings.Repeat("x", 64), 32, []Option{Fields("name", "id"), Limit(5)}, false, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer ts.Close()
+
+			m, err := NewMemoryCache(0, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c, err := NewClient(WithCache(CachePolicy{Cache: m, TTL: time.Minute, MaxEntrySize: test.maxEntrySize}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			first := []Option{Fields("name", "id"), Limit(5)}
+			for i, opts := range [][]Option{first, test.second} {
+				ctx := context.Background()
+				if i > 0 && test.bypass {
+					ctx = BypassCache(ctx)
+				}
+
+				resp, err := c.Send(ctx, "POST", ts.URL, opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				b, err := ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if resp.StatusCode != test.status {
+					t.Errorf("got: <%v>, want: <%v>", resp.StatusCode, test.status)
+				}
+
+				if string(b) != test.body {
+					t.Errorf("got: <%v>, want: <%v>", string(b), test.body)
+				}
+			}
+
+			if requests != test.wantRequests {
+				t.Errorf("got: <%v>, want: <%v>", requests, test.wantRequests)
+			}
+		})
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	newReq := func(header map[string]string) *http.Request {
+		req, err := NewRequest("POST", "http://fake.com/games", Fields("name"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		return req
+	}
+
+	base := map[string]string{"Authorization": "Bearer a", "Client-ID": "id"}
+	tests := []struct {
+		name     string
+		header   map[string]string
+		wantSame bool
+	}{
+		{"Same credentials", map[string]string{"Authorization": "Bearer a", "Client-ID": "id"}, true},
+		{"Other header", map[string]string{"Authorization": "Bearer a", "Client-ID": "id", "User-Agent": "test"}, true},
+		{"Different token", map[string]string{"Authorization": "Bearer b", "Client-ID": "id"}, false},
+		{"Different client ID", map[string]string{"Authorization": "Bearer a", "Client-ID": "other"}, false},
+		{"No credentials", nil, false},
+		{"Different accept", map[string]string{"Authorization": "Bearer a", "Client-ID": "id", "Accept": "application/protobuf"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want, ok := cacheKey(newReq(base))
+			if !ok {
+				t.Fatal("request is not cacheable")
+			}
+
+			got, ok := cacheKey(newReq(test.header))
+			if !ok {
+				t.Fatal("request is not cacheable")
+			}
+
+			if (got == want) != test.wantSame {
+				t.Errorf("got: <%v>, want same key: <%v>", got == want, test.wantSame)
+			}
+
+			if strings.Contains(got, "Bearer") {
+				t.Errorf("got: <%v>, want: <%v>", got, "a key without credentials")
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package apicalypse
+
+import (
+	"context"
+	"github.com/Henry-Sarabia/blank"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// Client sends Apicalypse requests to an API. A Client is safe for concurrent
+// use by multiple goroutines.
+type Client struct {
+	http       *http.Client
+	method     string
+	encoding   Encoding
+	methods    *MethodPolicy
+	middleware []Middleware
+	limiter    *Limiter
+	retry      *RetryPolicy
+	cache      *CachePolicy
+	profile    *Profile
+	budget     *Budget
+	flights    *flightGroup
+}
+
+// ClientOption is a functional option type used to configure a Client.
+type ClientOption func(*Client) error
+
+// NewClient returns a Client configured with the provided functional options.
+// By default, the Client sends requests with the GET method using http.DefaultClient
+// and does not limit the rate or concurrency of its requests.
+func NewClient(opts ...ClientOption) (*Client, error) {
+	c := &Client{http: http.DefaultClient, method: http.MethodGet}
+
+	for _, opt := range opts {
+		if opt == nil {
+			return nil, errors.New("a provided option is nil")
+		}
+		if err := opt(c); err != nil {
+			return nil, errors.Wrap(err, "cannot create new client")
+		}
+	}
+
+	if _, _, _, err := c.methods.method(c.method, c.encoding); err != nil {
+		return nil, errors.Wrap(err, "cannot create new client")
+	}
+
+	if len(c.middleware) > 0 {
+		hc := *c.http
+		hc.Transport = Chain(hc.Transport, c.middleware...)
+		c.http = &hc
+	}
+
+	return c, nil
+}
+
+// WithHTTPClient is a functional option for setting the underlying HTTP client
+// used to send requests.
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return func(c *Client) error {
+		if hc == nil {
+			return ErrMissingInput
+		}
+		c.http = hc
+
+		return nil
+	}
+}
+
+// WithMethod is a functional option for setting the HTTP method used by functions
+// that send queries on the Client's behalf, such as Fetch and Stream. Some APIs,
+// such as IGDB, only accept queries sent with POST. The method must be GET, POST,
+// or PUT and allowed by the Client's MethodPolicy, if any.
+func WithMethod(method string) ClientOption {
+	return func(c *Client) error {
+		if blank.Is(method) {
+			return ErrBlankArgument
+		}
+		m, err := normalizeMethod(method)
+		if err != nil {
+			return err
+		}
+		c.method = m
+
+		return nil
+	}
+}
+
+// WithEncoding is a functional option for setting the way Send and the functions
+// that send queries on the Client's behalf write queries into their requests. By
+// default, queries are written into the request body.
+func WithEncoding(e Encoding) ClientOption {
+	return func(c *Client) error {
+		if e != BodyEncoding && e != URLEncoding {
+			return ErrUnknownEncoding
+		}
+		c.encoding = e
+
+		return nil
+	}
+}
+
+// WithLimiter is a functional option for setting the Limiter every request must
+// pass through before it is sent. The same Limiter may be shared across Clients.
+func WithLimiter(l *Limiter) ClientOption {
+	return func(c *Client) error {
+		if l == nil {
+			return ErrMissingInput
+		}
+		c.limiter = l
+
+		return nil
+	}
+}
+
+// Send creates a request with NewEncodedRequest using the provided method, url,
+// and query options and the Client's encoding and sends it with Do. A blank method
+// defaults to GET. If the Client has a MethodPolicy, the method must be allowed by
+// it and requests meant for GET are rewritten according to its Fallback.
+func (c *Client) Send(ctx context.Context, method, url string, opts ...Option) (*http.Response, error) {
+	method, enc, override, err := c.methods.method(method, c.encoding)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create request")
+	}
+
+	req, err := NewEncodedRequest(method, url, enc, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create request")
+	}
+	if override {
+		req.Header.Set(methodOverrideHeader, http.MethodGet)
+	}
+
+	return c.Do(ctx, req)
+}
+
+// Do sends the provided request using the provided context. If the Client has a
+// Limiter, Do waits for it before sending the request and holds the request's
+// concurrency slot until the response body is closed. If the Client has a
+// RetryPolicy, failed attempts are retried according to it. If the Client has a
+// CachePolicy, cached responses are returned without sending the request at all.
+// If the Client coalesces requests, concurrent identical requests share a single
+// HTTP call. If the Client has a Profile, requests whose query breaks it are not
+// sent at all, unless the Profile clamps them. If the Client has a Budget,
+// requests whose query is over budget are not sent at all. As with http.Client,
+// the caller must close the response body.
+func (c *Client) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	req, err := c.profile.enforce(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot send request to '%s'", req.URL)
+	}
+
+	if err := c.budget.check(req); err != nil {
+		return nil, errors.Wrapf(err, "cannot send request to '%s'", req.URL)
+	}
+
+	if c.flights == nil {
+		return c.cached(ctx, req)
+	}
+
+	key, ok := cacheKey(req)
+	if !ok {
+		return c.cached(ctx, req)
+	}
+	if bypassed(ctx) {
+		key = "bypass " + key
+	}
+
+	return c.flights.do(ctx, key, req, c.cached)
+}
+
+// cached sends the provided request unless its response is found in the Client's
+// cache, storing the response afterwards if possible.
+func (c *Client) cached(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if c.cache == nil {
+		return c.do(ctx, req)
+	}
+
+	key, ok := cacheKey(req)
+	if !ok {
+		return c.do(ctx, req)
+	}
+
+	if !bypassed(ctx) {
+		if resp := c.cache.lookup(ctx, key, req); resp != nil {
+			return resp, nil
+		}
+	}
+
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.cache.store(ctx, key, resp)
+}
+
+// do sends the provided request, retrying failed attempts according to the
+// Client's RetryPolicy.
+func (c *Client) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, req.WithContext(withAttempt(ctx, attempt)))
+		if !c.retry.shouldRetry(ctx, req, resp, err, attempt) {
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot send request to '%s'", req.URL)
+			}
+			return resp, nil
+		}
+
+		delay := c.retry.delay(resp, attempt)
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if c.retry.OnRetry != nil {
+			c.retry.OnRetry(RetryEvent{
+				Request:    req,
+				Attempt:    attempt,
+				Delay:      delay,
+				StatusCode: status,
+				Err:        err,
+			})
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return nil, errors.Wrap(err, "cannot wait to retry request")
+		}
+
+		req, err = rewind(req)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot rewind request body")
+		}
+	}
+}
+
+// send sends a single attempt of the provided request, waiting for the Client's
+// Limiter if one is set.
+func (c *Client) send(ctx context.Context, req *http.Request) (*http.Response, error) {
+	release := func() {}
+	if c.limiter != nil {
+		r, err := c.limiter.Acquire(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot acquire limiter")
+		}
+		release = r
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+
+	return resp, nil
+}
+
+// releaseBody wraps a response body and calls release once the body is closed.
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+// Close closes the underlying body and calls release.
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,159 @@
+package apicalypse
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewClient(t *testing.T) {
+	l, err := NewLimiter(1, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    []ClientOption
+		wantErr error
+	}{
+		{"Zero options", nil, nil},
+		{"Valid options", []ClientOption{WithHTTPClient(&http.Client{}), WithMethod("POST"), WithLimiter(l), WithEncoding(URLEncoding)}, nil},
+		{"Nil HTTP client", []ClientOption{WithHTTPClient(nil)}, ErrMissingInput},
+		{"Nil limiter", []ClientOption{WithLimiter(nil)}, ErrMissingInput},
+		{"Blank method", []ClientOption{WithMethod(" ")}, ErrBlankArgument},
+		{"Unknown encoding", []ClientOption{WithEncoding(Encoding(9))}, ErrUnknownEncoding},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewClient(test.opts...)
+			if !reflect.DeepEqual(errors.Cause(err), test.wantErr) {
+				t.Errorf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientSend(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write(b)
+	}))
+	defer ts.Close()
+
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		url      string
+		opts     []Option
+		wantBody string
+		wantErr  error
+	}{
+		{"Single option", ts.URL, []Option{Limit(15)}, "limit 15; ", nil},
+		{"Error option", ts.URL, []Option{Limit(-15)}, "", ErrNegativeInput},
+		{"Empty url", "", []Option{Limit(15)}, "", ErrBlankArgument},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := c.Send(context.Background(), "POST", test.url, test.opts...)
+			if !reflect.DeepEqual(errors.Cause(err), test.wantErr) {
+				t.Errorf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+
+			if test.wantErr != nil {
+				return
+			}
+			defer resp.Body.Close()
+
+			b, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != test.wantBody {
+				t.Errorf("got: <%v>, want: <%v>", string(b), test.wantBody)
+			}
+		})
+	}
+}
+
+func TestClientDoLimiter(t *testing.T) {
+	var cur, max int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&cur, 1)
+		defer atomic.AddInt32(&cur, -1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	l, err := NewLimiter(0, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewClient(WithLimiter(l))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.Send(context.Background(), "GET", ts.URL, Limit(1))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if max > 3 {
+		t.Errorf("got: <%v>, want: <%v>", max, 3)
+	}
+}
+
+func TestClientDoCancel(t *testing.T) {
+	l, err := NewLimiter(0, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewClient(WithLimiter(l))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Hold the only concurrency slot.
+	if _, err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = c.Send(ctx, "GET", "http://fake.com/", Limit(1))
+	if errors.Cause(err) != context.Canceled {
+		t.Errorf("got: <%v>, want: <%v>", err, context.Canceled)
+	}
+}
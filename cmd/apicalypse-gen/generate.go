@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+)
+
+// initialisms maps the words that are not simply capitalized in generated
+// identifiers to how they are written.
+var initialisms = map[string]string{
+	"api":  "API",
+	"http": "HTTP",
+	"id":   "ID",
+	"ids":  "IDs",
+	"json": "JSON",
+	"url":  "URL",
+	"urls": "URLs",
+	"uuid": "UUID",
+}
+
+// options configure the generated code.
+type options struct {
+	pkg    string
+	source string
+	// depth is the number of levels of references for which nested field paths,
+	// such as "cover.url", are generated.
+	depth int
+}
+
+// generate returns the formatted Go source for the provided spec.
+func generate(s *spec, o options) ([]byte, error) {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "// Code generated by apicalypse-gen from %s. DO NOT EDIT.\n\n", o.source)
+	fmt.Fprintf(&b, "package %s\n\n", o.pkg)
+	fmt.Fprintf(&b, "import \"github.com/Henry-Sarabia/apicalypse\"\n")
+
+	for _, e := range s.Endpoints {
+		t := e.typeName()
+
+		fmt.Fprintf(&b, "\n// %sEndpoint is the name of the %s endpoint.\n", t, e.Name)
+		fmt.Fprintf(&b, "const %sEndpoint = %q\n", t, e.Name)
+
+		fmt.Fprintf(&b, "\n// %s is an item returned by the %s endpoint.\n", t, e.Name)
+		fmt.Fprintf(&b, "type %s struct {\n", t)
+		for _, f := range e.Fields {
+			fmt.Fprintf(&b, "\t%s %s `json:%q`\n", identifier(f.Name), goType(s, f), f.Name)
+		}
+		fmt.Fprintf(&b, "}\n")
+
+		paths, err := fieldPaths(s, e, o.depth)
+		if err != nil {
+			return nil, fmt.Errorf("endpoint '%s': %v", e.Name, err)
+		}
+		if len(paths) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&b, "\n// Fields of the %s endpoint.\n", e.Name)
+		fmt.Fprintf(&b, "const (\n")
+		for _, p := range paths {
+			fmt.Fprintf(&b, "\t%s apicalypse.%s = %q\n", p.name, p.fieldType, p.path)
+		}
+		fmt.Fprintf(&b, ")\n")
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("cannot format generated code: %v", err)
+	}
+
+	return src, nil
+}
+
+// goType returns the Go type of the provided field's values.
+func goType(s *spec, f field) string {
+	t := fieldTypes[f.Type].goType
+	if f.Ref != "" {
+		t = "apicalypse.Ref[" + s.endpoint(f.Ref).typeName() + "]"
+	}
+	switch {
+	case f.Array:
+		t = "[]" + t
+	case f.Nullable && f.Ref == "":
+		t = "*" + t
+	}
+	return t
+}
+
+// fieldPath is a generated constant for the path of a field.
+type fieldPath struct {
+	name      string
+	fieldType string
+	path      string
+}
+
+// fieldPaths returns the constants for the paths of an endpoint's fields,
+// including the fields of referenced endpoints up to the provided depth.
+func fieldPaths(s *spec, e endpoint, depth int) ([]fieldPath, error) {
+	var paths []fieldPath
+	seen := map[string]string{e.typeName() + "Endpoint": "the endpoint's name"}
+
+	var walk func(e *endpoint, name, path string, level int) error
+	walk = func(e *endpoint, name, path string, level int) error {
+		for _, f := range e.Fields {
+			p := fieldPath{
+				name:      name + identifier(f.Name),
+				fieldType: "IntField",
+				path:      path + f.Name,
+			}
+			if f.Ref == "" {
+				p.fieldType = fieldTypes[f.Type].fieldType
+			}
+
+			if other, ok := seen[p.name]; ok {
+				return fmt.Errorf("field '%s' generates the name '%s', which is already used by %s", p.path, p.name, other)
+			}
+			seen[p.name] = fmt.Sprintf("field '%s'", p.path)
+			paths = append(paths, p)
+
+			if f.Ref != "" && level < depth {
+				if err := walk(s.endpoint(f.Ref), p.name, p.path+".", level+1); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := walk(&e, e.typeName(), "", 0); err != nil {
+		return nil, err
+	}
+
+	return paths, nil
+}
+
+// identifier returns the exported Go identifier for a snake case name, such as
+// "CoverURL" for "cover_url".
+func identifier(name string) string {
+	var b strings.Builder
+	for _, w := range strings.Split(name, "_") {
+		if w == "" {
+			continue
+		}
+		if i, ok := initialisms[strings.ToLower(w)]; ok {
+			b.WriteString(i)
+			continue
+		}
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+
+	id := b.String()
+	if id == "" || (id[0] >= '0' && id[0] <= '9') {
+		id = "X" + id
+	}
+	return id
+}
+
+// singular returns the singular form of a plural endpoint name, such as "game" for
+// "games" or "company" for "companies".
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies"):
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "sses"), strings.HasSuffix(name, "uses"), strings.HasSuffix(name, "xes"), strings.HasSuffix(name, "ches"):
+		return strings.TrimSuffix(name, "es")
+	case strings.HasSuffix(name, "ss"):
+		return name
+	}
+	return strings.TrimSuffix(name, "s")
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	s, err := parseSpec([]byte(`
+endpoints:
+  - name: games
+    fields:
+      - {name: name, type: string}
+      - {name: cover, ref: covers}
+  - name: covers
+    type: Artwork
+    fields:
+      - {name: image_id, type: string}
+      - {name: width, type: int, nullable: true}
+      - {name: game, ref: games, nullable: true}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		depth int
+		want  []string
+		omit  []string
+	}{
+		{"No nested paths", 0, []string{"package igdb", "Cover apicalypse.Ref[Artwork]", "Width *int `json:\"width\"`", "Game apicalypse.Ref[Game] `json:\"game\"`", "ArtworkImageID apicalypse.StringField = \"image_id\""}, []string{"GameCoverImageID"}},
+		{"Nested paths", 1, []string{"GameCoverImageID apicalypse.StringField = \"cover.image_id\"", "ArtworkGameCover"}, []string{"GameCoverGameName"}},
+		{"Deeply nested paths", 2, []string{"GameCoverGameName apicalypse.StringField = \"cover.game.name\""}, []string{"GameCoverGameCoverImageID"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := generate(s, options{pkg: "igdb", source: "schema.yaml", depth: test.depth})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Compare without the alignment added by gofmt.
+			src := strings.Join(strings.Fields(string(b)), " ")
+			for _, w := range test.want {
+				if !strings.Contains(src, w) {
+					t.Errorf("got: <%v>, want: <%v>", src, w)
+				}
+			}
+			for _, o := range test.omit {
+				if strings.Contains(src, o) {
+					t.Errorf("got: <%v>, want it to omit: <%v>", src, o)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateNameCollision(t *testing.T) {
+	s, err := parseSpec([]byte(`
+endpoints:
+  - name: games
+    fields:
+      - {name: cover_url, type: string}
+      - {name: cover, ref: covers}
+  - name: covers
+    fields:
+      - {name: url, type: string}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = generate(s, options{pkg: "igdb", depth: 1})
+	want := "endpoint 'games': field 'cover.url' generates the name 'GameCoverURL', which is already used by field 'cover_url'"
+	if err == nil || err.Error() != want {
+		t.Errorf("got: <%v>, want: <%v>", err, want)
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"name", "Name"},
+		{"first_release_date", "FirstReleaseDate"},
+		{"id", "ID"},
+		{"cover_url", "CoverURL"},
+		{"game_ids", "GameIDs"},
+		{"_private", "Private"},
+		{"3d", "X3d"},
+	}
+	for _, test := range tests {
+		if got := identifier(test.name); got != test.want {
+			t.Errorf("got: <%v>, want: <%v>", got, test.want)
+		}
+	}
+}
+
+func TestSingular(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"games", "game"},
+		{"companies", "company"},
+		{"statuses", "status"},
+		{"franchises", "franchise"},
+		{"boxes", "box"},
+		{"search", "search"},
+		{"access", "access"},
+	}
+	for _, test := range tests {
+		if got := singular(test.name); got != test.want {
+			t.Errorf("got: <%v>, want: <%v>", got, test.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Command apicalypse-gen generates typed query builders from a schema description
+// of an API. For each endpoint in the schema it generates a struct for the
+// endpoint's results, a constant holding the endpoint's name, and typed constants
+// for the paths of the endpoint's fields. The field constants provide predicates
+// that return apicalypse options, so a misspelled field name is a compile error
+// rather than a query that silently returns nothing:
+//
+//	apicalypse.NewRequest("POST", url, apicalypse.Select(GameName, GameRating), GameRating.Gt(80))
+//
+// The schema is written in YAML or JSON and lists each endpoint with its fields.
+// A field has either a type (int, float, string, or bool) or a reference to
+// another endpoint, and may hold a list of values:
+//
+//	package: igdb
+//	endpoints:
+//	  - name: games
+//	    fields:
+//	      - {name: id, type: int}
+//	      - {name: name, type: string}
+//	      - {name: rating, type: float}
+//	      - {name: cover, ref: covers}
+//	      - {name: genres, ref: genres, array: true}
+//	  - name: covers
+//	    fields:
+//	      - {name: url, type: string}
+//
+// OpenAPI 3 documents and JSON Schema documents may be used as the schema instead,
+// in which case the endpoints are built from their component schemas or
+// definitions as described by apicalypse.ParseOpenAPI and apicalypse.ParseJSONSchema.
+//
+// The command is meant to be run by go generate:
+//
+//	//go:generate go run github.com/Henry-Sarabia/apicalypse/cmd/apicalypse-gen -schema schema.yaml -o schema_gen.go
+//
+// Usage:
+//
+//	apicalypse-gen [flags]
+//
+// The flags are:
+//
+//	-schema file
+//		schema, OpenAPI 3 document, or JSON Schema document to generate
+//		code from (required)
+//	-o file
+//		file to write the generated code to instead of standard output
+//	-package name
+//		name of the generated package; defaults to the schema's package or,
+//		when run by go generate, the package containing the directive
+//	-depth n
+//		levels of references to generate nested field paths for, such as
+//		GameCoverURL for "cover.url" (default 1)
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("apicalypse-gen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: apicalypse-gen [flags]\n\nGenerate typed query builders from a schema.\n\nFlags:")
+		fs.PrintDefaults()
+	}
+
+	schema := fs.String("schema", "", "schema `file` to generate code from")
+	out := fs.String("o", "", "`file` to write the generated code to instead of standard output")
+	pkg := fs.String("package", "", "`name` of the generated package")
+	depth := fs.Int("depth", 1, "levels of references to generate nested field paths for")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *schema == "" || fs.NArg() > 0 || *depth < 0 {
+		fs.Usage()
+		return 2
+	}
+
+	data, err := ioutil.ReadFile(*schema)
+	if err != nil {
+		fmt.Fprintf(stderr, "apicalypse-gen: %v\n", err)
+		return 1
+	}
+
+	s, err := parseSpec(data)
+	if err != nil {
+		fmt.Fprintf(stderr, "apicalypse-gen: %s: %v\n", *schema, err)
+		return 1
+	}
+
+	name := packageName(*pkg, s)
+	if !token.IsIdentifier(name) {
+		fmt.Fprintf(stderr, "apicalypse-gen: invalid package name '%s' (use -package)\n", name)
+		return 2
+	}
+
+	src, err := generate(s, options{pkg: name, source: filepath.Base(*schema), depth: *depth})
+	if err != nil {
+		fmt.Fprintf(stderr, "apicalypse-gen: %s: %v\n", *schema, err)
+		return 1
+	}
+
+	if *out == "" {
+		stdout.Write(src)
+		return 0
+	}
+
+	if err := ioutil.WriteFile(*out, src, 0644); err != nil {
+		fmt.Fprintf(stderr, "apicalypse-gen: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// packageName returns the name of the generated package. The flag takes precedence
+// over the schema, which takes precedence over the package running go generate.
+func packageName(flagName string, s *spec) string {
+	switch {
+	case flagName != "":
+		return flagName
+	case s.Package != "":
+		return s.Package
+	}
+	return os.Getenv("GOPACKAGE")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	golden, err := ioutil.ReadFile("testdata/schema.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{"YAML schema", []string{"-schema", "testdata/schema.yaml"}, 0, string(golden), ""},
+		{"JSON schema", []string{"-schema", "testdata/schema.json", "-package", "api"}, 0, "package api", ""},
+		{"Missing package", []string{"-schema", "testdata/schema.json"}, 2, "", "invalid package name ''"},
+		{"Missing schema flag", nil, 2, "", "Usage:"},
+		{"Missing schema file", []string{"-schema", "testdata/missing.yaml"}, 1, "", "no such file"},
+		{"Negative depth", []string{"-schema", "testdata/schema.yaml", "-depth", "-1"}, 2, "", "Usage:"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("GOPACKAGE", "")
+
+			var stdout, stderr bytes.Buffer
+			code := run(test.args, &stdout, &stderr)
+			if code != test.wantCode {
+				t.Errorf("got: <%v>, want: <%v>", code, test.wantCode)
+			}
+
+			if !strings.Contains(stdout.String(), test.wantStdout) {
+				t.Errorf("got: <%v>, want: <%v>", stdout.String(), test.wantStdout)
+			}
+
+			if !strings.Contains(stderr.String(), test.wantStderr) {
+				t.Errorf("got: <%v>, want: <%v>", stderr.String(), test.wantStderr)
+			}
+		})
+	}
+}
+
+func TestRunGoGenerate(t *testing.T) {
+	t.Setenv("GOPACKAGE", "games")
+	out := filepath.Join(t.TempDir(), "schema_gen.go")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-schema", "testdata/schema.json", "-o", out}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got: <%v>, want: <%v>: %s", code, 0, stderr.String())
+	}
+
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(b), "package games") {
+		t.Errorf("got: <%v>, want: <%v>", string(b), "package games")
+	}
+}
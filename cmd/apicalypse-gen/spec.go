@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"github.com/Henry-Sarabia/apicalypse"
+	"go/token"
+	"gopkg.in/yaml.v3"
+	"regexp"
+)
+
+// spec is a schema description listing the endpoints of an API, the fields of
+// their results, and the endpoints those fields reference. Specs are written in
+// YAML or JSON, for example:
+//
+//	package: igdb
+//	endpoints:
+//	  - name: games
+//	    fields:
+//	      - {name: name, type: string}
+//	      - {name: rating, type: float}
+//	      - {name: cover, ref: covers}
+//	      - {name: genres, ref: genres, array: true}
+type spec struct {
+	// Package is the default name of the generated package.
+	Package   string     `yaml:"package"`
+	Endpoints []endpoint `yaml:"endpoints"`
+}
+
+// endpoint describes an endpoint and the fields of its results.
+type endpoint struct {
+	// Name is the name of the endpoint in URLs and multiqueries, such as "games".
+	Name string `yaml:"name"`
+	// Type is the name of the generated struct. It defaults to the singular form
+	// of the endpoint's name, such as "Game".
+	Type   string  `yaml:"type"`
+	Fields []field `yaml:"fields"`
+}
+
+// field describes a field of an endpoint's results. A field either holds values of
+// a type or references the items of another endpoint.
+type field struct {
+	Name string `yaml:"name"`
+	// Type is one of int, float, string, or bool.
+	Type string `yaml:"type"`
+	// Ref is the name of the referenced endpoint.
+	Ref string `yaml:"ref"`
+	// Array is true if the field holds a list of values or references.
+	Array bool `yaml:"array"`
+	// Nullable is true if the field may be null, in which case a field holding a
+	// single value is generated as a pointer.
+	Nullable bool `yaml:"nullable"`
+}
+
+// fieldTypes maps each field type to the Go type of its values and the typed
+// field used for its path.
+var fieldTypes = map[string]struct{ goType, fieldType string }{
+	"int":    {"int", "IntField"},
+	"float":  {"float64", "FloatField"},
+	"string": {"string", "StringField"},
+	"bool":   {"bool", "BoolField"},
+}
+
+// namePattern matches valid endpoint and field names.
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseSpec parses a spec written in YAML or JSON and validates it. OpenAPI 3
+// documents and JSON Schema documents are converted into specs.
+func parseSpec(data []byte) (*spec, error) {
+	var keys map[string]interface{}
+	if err := yaml.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("cannot parse schema: %v", err)
+	}
+
+	var s *spec
+	switch {
+	case keys["openapi"] != nil:
+		schema, err := apicalypse.ParseOpenAPI(data)
+		if err != nil {
+			return nil, err
+		}
+		s = specFromSchema(schema)
+	case keys["$schema"] != nil, keys["$defs"] != nil, keys["definitions"] != nil:
+		schema, err := apicalypse.ParseJSONSchema(data)
+		if err != nil {
+			return nil, err
+		}
+		s = specFromSchema(schema)
+	default:
+		s = &spec{}
+		if err := yaml.Unmarshal(data, s); err != nil {
+			return nil, fmt.Errorf("cannot parse schema: %v", err)
+		}
+	}
+
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// specFromSchema returns a spec describing the endpoints of the provided schema.
+func specFromSchema(schema *apicalypse.Schema) *spec {
+	s := &spec{}
+	for _, name := range schema.Endpoints() {
+		e, _ := schema.Endpoint(name)
+
+		se := endpoint{Name: name}
+		for _, f := range e.Fields {
+			sf := field{Name: f.Name, Ref: f.Ref, Array: f.Array, Nullable: f.Nullable}
+			if f.Ref == "" {
+				sf.Type = f.Type.String()
+			}
+			se.Fields = append(se.Fields, sf)
+		}
+		s.Endpoints = append(s.Endpoints, se)
+	}
+
+	return s
+}
+
+// validate reports the first problem found in the spec.
+func (s *spec) validate() error {
+	if len(s.Endpoints) == 0 {
+		return fmt.Errorf("schema has no endpoints")
+	}
+
+	names := map[string]bool{}
+	types := map[string]bool{}
+	for _, e := range s.Endpoints {
+		if !namePattern.MatchString(e.Name) {
+			return fmt.Errorf("invalid endpoint name '%s'", e.Name)
+		}
+		if names[e.Name] {
+			return fmt.Errorf("endpoint '%s' is repeated", e.Name)
+		}
+		names[e.Name] = true
+
+		t := e.typeName()
+		if !token.IsIdentifier(t) || !token.IsExported(t) {
+			return fmt.Errorf("endpoint '%s': invalid type name '%s'", e.Name, t)
+		}
+		if types[t] {
+			return fmt.Errorf("endpoint '%s': type name '%s' is repeated", e.Name, t)
+		}
+		types[t] = true
+	}
+
+	for _, e := range s.Endpoints {
+		seen := map[string]bool{}
+		for _, f := range e.Fields {
+			if !namePattern.MatchString(f.Name) {
+				return fmt.Errorf("endpoint '%s': invalid field name '%s'", e.Name, f.Name)
+			}
+			if seen[f.Name] {
+				return fmt.Errorf("endpoint '%s': field '%s' is repeated", e.Name, f.Name)
+			}
+			seen[f.Name] = true
+
+			switch {
+			case f.Ref != "" && f.Type != "":
+				return fmt.Errorf("endpoint '%s': field '%s' has both a type and a reference", e.Name, f.Name)
+			case f.Ref != "":
+				if !names[f.Ref] {
+					return fmt.Errorf("endpoint '%s': field '%s' references unknown endpoint '%s'", e.Name, f.Name, f.Ref)
+				}
+			default:
+				if _, ok := fieldTypes[f.Type]; !ok {
+					return fmt.Errorf("endpoint '%s': field '%s' has unknown type '%s'", e.Name, f.Name, f.Type)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// endpoint returns the endpoint with the provided name.
+func (s *spec) endpoint(name string) *endpoint {
+	for i := range s.Endpoints {
+		if s.Endpoints[i].Name == name {
+			return &s.Endpoints[i]
+		}
+	}
+	return nil
+}
+
+// typeName returns the name of the struct generated for the endpoint.
+func (e endpoint) typeName() string {
+	if e.Type != "" {
+		return e.Type
+	}
+	return identifier(singular(e.Name))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{"Valid YAML", "endpoints:\n  - name: games\n    fields:\n      - {name: name, type: string}\n", ""},
+		{"Valid JSON", `{"endpoints": [{"name": "games", "fields": [{"name": "id", "type": "int"}]}]}`, ""},
+		{"Malformed", "endpoints: [", "cannot parse schema"},
+		{"No endpoints", "package: igdb\n", "schema has no endpoints"},
+		{"Invalid endpoint name", "endpoints: [{name: games/count}]", "invalid endpoint name 'games/count'"},
+		{"Repeated endpoint", "endpoints: [{name: games}, {name: games}]", "endpoint 'games' is repeated"},
+		{"Invalid type name", "endpoints: [{name: games, type: game}]", "invalid type name 'game'"},
+		{"Repeated type name", "endpoints: [{name: games}, {name: game}]", "type name 'Game' is repeated"},
+		{"Invalid field name", "endpoints: [{name: games, fields: [{name: 'a.b', type: int}]}]", "invalid field name 'a.b'"},
+		{"Repeated field", "endpoints: [{name: games, fields: [{name: a, type: int}, {name: a, type: int}]}]", "field 'a' is repeated"},
+		{"Type and reference", "endpoints: [{name: games, fields: [{name: a, type: int, ref: games}]}]", "has both a type and a reference"},
+		{"Unknown reference", "endpoints: [{name: games, fields: [{name: cover, ref: covers}]}]", "references unknown endpoint 'covers'"},
+		{"Unknown type", "endpoints: [{name: games, fields: [{name: a, type: date}]}]", "unknown type 'date'"},
+		{"Missing type", "endpoints: [{name: games, fields: [{name: a}]}]", "unknown type ''"},
+		{"OpenAPI", "openapi: 3.0.0\ncomponents:\n  schemas:\n    Game:\n      properties:\n        name: {type: string}\n", ""},
+		{"Invalid OpenAPI", "openapi: 2.0.0\n", "unsupported OpenAPI version"},
+		{"JSON Schema", `{"$defs": {"Game": {"properties": {"cover": {"$ref": "#/$defs/Cover"}}}, "Cover": {"properties": {"url": {"type": "string"}}}}}`, ""},
+		{"JSON Schema without objects", `{"$schema": "https://json-schema.org/draft/2020-12/schema"}`, "schema has no endpoints"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseSpec([]byte(test.schema))
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("got: <%v>, want: <%v>", err, nil)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseSpecFromSchema(t *testing.T) {
+	s, err := parseSpec([]byte(`{"$defs": {"GameMode": {"properties": {"name": {"type": "string"}, "games": {"type": "array", "items": {"$ref": "#/$defs/GameMode"}}}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []endpoint{{Name: "game_mode", Fields: []field{
+		{Name: "name", Type: "string"},
+		{Name: "games", Ref: "game_mode", Array: true},
+	}}}
+	if !reflect.DeepEqual(s.Endpoints, want) {
+		t.Errorf("got: <%v>, want: <%v>", s.Endpoints, want)
+	}
+}
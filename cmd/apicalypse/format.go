@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/Henry-Sarabia/apicalypse"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// runFormat runs the fmt command.
+func runFormat(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: apicalypse fmt [flags] [files]\n\nRewrite queries into canonical form. Each file holds a single query.\nWith no files, the query is read from standard input.\n\nFlags:")
+		fs.PrintDefaults()
+	}
+	write := fs.Bool("w", false, "write the result to each file instead of standard output")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(stderr, "apicalypse fmt: cannot use -w with standard input")
+			return 2
+		}
+
+		b, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "apicalypse fmt: %v\n", err)
+			return 1
+		}
+
+		q, err := apicalypse.Format(string(b))
+		if err != nil {
+			fmt.Fprintf(stderr, "apicalypse fmt: <stdin>:%v\n", err)
+			return 1
+		}
+		fmt.Fprintln(stdout, q)
+
+		return 0
+	}
+
+	code := 0
+	for _, name := range fs.Args() {
+		if err := formatFile(name, *write, stdout); err != nil {
+			fmt.Fprintf(stderr, "apicalypse fmt: %v\n", err)
+			code = 1
+		}
+	}
+
+	return code
+}
+
+// formatFile formats the query held by the named file and either writes it back
+// to the file or to w.
+func formatFile(name string, write bool, w io.Writer) error {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	q, err := apicalypse.Format(string(b))
+	if err != nil {
+		return fmt.Errorf("%s:%v", name, err)
+	}
+
+	if !write {
+		_, err := fmt.Fprintln(w, q)
+		return err
+	}
+
+	fi, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(name, []byte(q+"\n"), fi.Mode().Perm())
+}
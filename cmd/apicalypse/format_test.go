@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunFormat(t *testing.T) {
+	tests := []struct {
+		name       string
+		stdin      string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{"Standard input", "limit 5; fields b, a;", nil, 0, "fields a,b; limit 5;\n", ""},
+		{"Invalid query", "where id = (1;", nil, 1, "", "<stdin>:1:12: unbalanced '('"},
+		{"Write standard input", "limit 5;", []string{"-w"}, 2, "", "cannot use -w"},
+		{"Missing file", "", []string{"does-not-exist.apicalypse"}, 1, "", "does-not-exist"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := runFormat(test.args, strings.NewReader(test.stdin), &stdout, &stderr)
+			if code != test.wantCode {
+				t.Errorf("got: <%v>, want: <%v>", code, test.wantCode)
+			}
+
+			if stdout.String() != test.wantStdout {
+				t.Errorf("got: <%v>, want: <%v>", stdout.String(), test.wantStdout)
+			}
+
+			if !strings.Contains(stderr.String(), test.wantStderr) {
+				t.Errorf("got: <%v>, want: <%v>", stderr.String(), test.wantStderr)
+			}
+		})
+	}
+}
+
+func TestRunFormatWrite(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "games.apicalypse")
+	if err := ioutil.WriteFile(name, []byte("limit 5;\nfields  name,id;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runFormat([]string{"-w", name}, strings.NewReader(""), &stdout, &stderr); code != 0 {
+		t.Fatalf("got: <%v>, want: <%v>: %s", code, 0, stderr.String())
+	}
+
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "fields id,name; limit 5;\n" {
+		t.Errorf("got: <%v>, want: <%v>", string(b), "fields id,name; limit 5;\n")
+	}
+
+	if stdout.Len() != 0 {
+		t.Errorf("got: <%v>, want: <%v>", stdout.String(), "")
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/Henry-Sarabia/apicalypse"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// runInfer runs the infer command.
+func runInfer(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("infer", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, `Usage: apicalypse infer [flags] [endpoint=]path...
+
+Infer a schema from sample responses. Each path is a JSON file holding a response
+or a directory of such files. The endpoint of a file defaults to its name without
+the extension and the endpoint of a directory defaults to the directory's name.
+Samples of the same endpoint are merged. The schema can be used by apicalypse-gen
+and read with apicalypse.ParseSchema.
+
+Flags:`)
+		fs.PrintDefaults()
+	}
+	out := fs.String("o", "", "`file` to write the schema to instead of standard output")
+	format := fs.String("format", "yaml", "schema `format`: yaml or json")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() == 0 || (*format != "yaml" && *format != "json") {
+		fs.Usage()
+		return 2
+	}
+
+	in := apicalypse.NewInference()
+	for _, arg := range fs.Args() {
+		endpoint, files, err := sampleFiles(arg)
+		if err != nil {
+			fmt.Fprintf(stderr, "apicalypse infer: %v\n", err)
+			return 1
+		}
+
+		for _, name := range files {
+			b, err := ioutil.ReadFile(name)
+			if err != nil {
+				fmt.Fprintf(stderr, "apicalypse infer: %v\n", err)
+				return 1
+			}
+			if err := in.Add(endpoint, b); err != nil {
+				fmt.Fprintf(stderr, "apicalypse infer: %s: %v\n", name, err)
+				return 1
+			}
+		}
+	}
+
+	for _, s := range in.Skipped() {
+		fmt.Fprintf(stderr, "apicalypse infer: skipped %s\n", s)
+	}
+
+	schema, err := in.Schema()
+	if err != nil {
+		fmt.Fprintf(stderr, "apicalypse infer: %v\n", err)
+		return 1
+	}
+
+	b, err := encodeSchema(schema, *format)
+	if err != nil {
+		fmt.Fprintf(stderr, "apicalypse infer: %v\n", err)
+		return 1
+	}
+
+	if *out == "" {
+		stdout.Write(b)
+		return 0
+	}
+
+	if err := ioutil.WriteFile(*out, b, 0644); err != nil {
+		fmt.Fprintf(stderr, "apicalypse infer: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// encodeSchema encodes the schema in the provided format with two-space indents.
+func encodeSchema(s *apicalypse.Schema, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	if format == "json" {
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		err := enc.Encode(s)
+		return buf.Bytes(), err
+	}
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(s); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sampleFiles returns the endpoint and the sample files named by an argument of
+// the form "[endpoint=]path".
+func sampleFiles(arg string) (string, []string, error) {
+	endpoint, path := "", arg
+	if i := strings.Index(arg, "="); i >= 0 {
+		endpoint, path = arg[:i], arg[i+1:]
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !fi.IsDir() {
+		if endpoint == "" {
+			endpoint = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		return endpoint, []string{path}, nil
+	}
+
+	if endpoint == "" {
+		endpoint = filepath.Base(filepath.Clean(path))
+	}
+
+	files, err := filepath.Glob(filepath.Join(path, "*.json"))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(files) == 0 {
+		return "", nil, fmt.Errorf("%s: no JSON files found", path)
+	}
+	sort.Strings(files)
+
+	return endpoint, files, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunInfer(t *testing.T) {
+	dir := t.TempDir()
+	games := filepath.Join(dir, "games")
+	if err := os.Mkdir(games, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		filepath.Join(games, "1.json"):    `[{"id": 1, "name": "Doom", "rating": 90}]`,
+		filepath.Join(games, "2.json"):    `[{"id": 2, "name": "Quake", "rating": 85.5, "status": null}]`,
+		filepath.Join(dir, "covers.json"): `{"id": 3, "url": "a.jpg"}`,
+		filepath.Join(dir, "broken.json"): `[{"id": 1}`,
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			"Directory",
+			[]string{games},
+			0,
+			"endpoints:\n" +
+				"  - name: games\n" +
+				"    fields:\n" +
+				"      - {name: id, type: int, sortable: true}\n" +
+				"      - {name: name, type: string, sortable: true}\n" +
+				"      - {name: rating, type: float, sortable: true}\n",
+			"skipped games.status: only null values",
+		},
+		{
+			"Named file",
+			[]string{"-format", "json", "artworks=" + filepath.Join(dir, "covers.json")},
+			0,
+			`"name": "artworks"`,
+			"",
+		},
+		{"No samples", nil, 2, "", "Usage"},
+		{"Invalid format", []string{"-format", "xml", games}, 2, "", "Usage"},
+		{"Missing file", []string{filepath.Join(dir, "missing.json")}, 1, "", "missing.json"},
+		{"Invalid sample", []string{filepath.Join(dir, "broken.json")}, 1, "", "cannot decode sample of endpoint 'broken'"},
+		{"Empty directory", []string{t.TempDir()}, 1, "", "no JSON files found"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := runInfer(test.args, strings.NewReader(""), &stdout, &stderr)
+			if code != test.wantCode {
+				t.Errorf("got: <%v>, want: <%v>", code, test.wantCode)
+			}
+
+			if !strings.Contains(stdout.String(), test.wantStdout) {
+				t.Errorf("got: <%v>, want: <%v>", stdout.String(), test.wantStdout)
+			}
+
+			if !strings.Contains(stderr.String(), test.wantStderr) {
+				t.Errorf("got: <%v>, want: <%v>", stderr.String(), test.wantStderr)
+			}
+		})
+	}
+}
+
+func TestRunInferWrite(t *testing.T) {
+	dir := t.TempDir()
+	sample := filepath.Join(dir, "games.json")
+	if err := ioutil.WriteFile(sample, []byte(`[{"id": 1}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "schema.yaml")
+	var stdout, stderr bytes.Buffer
+	if code := runInfer([]string{"-o", out, sample}, strings.NewReader(""), &stdout, &stderr); code != 0 {
+		t.Fatalf("got: <%v>, want: <%v>: %s", code, 0, stderr.String())
+	}
+
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "endpoints:\n  - name: games\n    fields:\n      - {name: id, type: int, sortable: true}\n"
+	if string(b) != want {
+		t.Errorf("got: <%v>, want: <%v>", string(b), want)
+	}
+
+	if stdout.Len() != 0 {
+		t.Errorf("got: <%v>, want: <%v>", stdout.String(), "")
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/Henry-Sarabia/apicalypse"
+	"io"
+	"io/ioutil"
+)
+
+// runLint runs the lint command.
+func runLint(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: apicalypse lint [files]\n\nReport problems in queries. Each file holds a single query.\nWith no files, the query is read from standard input.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	type source struct {
+		name string
+		text []byte
+	}
+
+	var sources []source
+	if fs.NArg() == 0 {
+		b, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "apicalypse lint: %v\n", err)
+			return 1
+		}
+		sources = append(sources, source{"<stdin>", b})
+	}
+	for _, name := range fs.Args() {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(stderr, "apicalypse lint: %v\n", err)
+			return 1
+		}
+		sources = append(sources, source{name, b})
+	}
+
+	code := 0
+	for _, src := range sources {
+		for _, issue := range apicalypse.Lint(string(src.text)) {
+			fmt.Fprintf(stdout, "%s:%v\n", src.name, issue)
+			code = 1
+		}
+	}
+
+	return code
+}
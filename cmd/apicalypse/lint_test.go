@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunLint(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.apicalypse")
+	invalid := filepath.Join(dir, "invalid.apicalypse")
+	if err := ioutil.WriteFile(valid, []byte("fields name; limit 5;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(invalid, []byte("fields name;\nlimit -5;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		stdin      string
+		args       []string
+		wantCode   int
+		wantStdout string
+	}{
+		{"Valid standard input", "fields name;", nil, 0, ""},
+		{"Invalid standard input", "fields ,name;", nil, 1, "<stdin>:1:8: invalid fields: a provided argument is blank or empty\n"},
+		{"Valid file", "", []string{valid}, 0, ""},
+		{"Invalid file", "", []string{valid, invalid}, 1, invalid + ":2:7: invalid limit: input cannot be a negative number\n"},
+		{"Missing file", "", []string{filepath.Join(dir, "missing.apicalypse")}, 1, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := runLint(test.args, strings.NewReader(test.stdin), &stdout, &stderr)
+			if code != test.wantCode {
+				t.Errorf("got: <%v>, want: <%v>", code, test.wantCode)
+			}
+
+			if stdout.String() != test.wantStdout {
+				t.Errorf("got: <%v>, want: <%v>", stdout.String(), test.wantStdout)
+			}
+		})
+	}
+}
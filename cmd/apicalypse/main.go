@@ -0,0 +1,80 @@
+// Command apicalypse builds Apicalypse queries from flags and either prints them
+// or sends them to an API endpoint. It can also format and lint existing queries,
+// build queries interactively, and infer schemas from sample responses.
+//
+// Usage:
+//
+//	apicalypse <command> [flags] [arguments]
+//
+// The commands are:
+//
+//	query    print the query built from the provided flags
+//	send     send the query built from the provided flags to an endpoint
+//	fmt      rewrite queries into canonical form
+//	lint     report problems in queries
+//	repl     build and send queries interactively
+//	infer    infer a schema from sample responses
+//
+// Use "apicalypse <command> -h" for more information about a command.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// command is a subcommand of the apicalypse tool.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+}
+
+// commands returns the subcommands of the apicalypse tool.
+func commands() []command {
+	return []command{
+		{"query", "print the query built from the provided flags", runQuery},
+		{"send", "send the query built from the provided flags to an endpoint", runSend},
+		{"fmt", "rewrite queries into canonical form", runFormat},
+		{"lint", "report problems in queries", runLint},
+		{"repl", "build and send queries interactively", runRepl},
+		{"infer", "infer a schema from sample responses", runInfer},
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the subcommand named by the first argument and returns the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) < 1 {
+		usage(stderr)
+		return 2
+	}
+
+	for _, c := range commands() {
+		if c.name == args[0] {
+			return c.run(args[1:], stdin, stdout, stderr)
+		}
+	}
+
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stdout)
+		return 0
+	}
+
+	fmt.Fprintf(stderr, "apicalypse: unknown command '%s'\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+// usage writes the usage of the apicalypse tool to w.
+func usage(w io.Writer) {
+	fmt.Fprint(w, "Usage:\n\n\tapicalypse <command> [flags] [arguments]\n\nThe commands are:\n\n")
+	for _, c := range commands() {
+		fmt.Fprintf(w, "\t%-8s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w, "\nUse \"apicalypse <command> -h\" for more information about a command.")
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{"Zero arguments", nil, 2, "", "Usage:"},
+		{"Help", []string{"help"}, 0, "Usage:", ""},
+		{"Unknown command", []string{"fetch"}, 2, "", "unknown command 'fetch'"},
+		{"Known command", []string{"query", "-limit", "5"}, 0, "limit 5;", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(test.args, strings.NewReader(""), &stdout, &stderr)
+			if code != test.wantCode {
+				t.Errorf("got: <%v>, want: <%v>", code, test.wantCode)
+			}
+
+			if !strings.Contains(stdout.String(), test.wantStdout) {
+				t.Errorf("got: <%v>, want: <%v>", stdout.String(), test.wantStdout)
+			}
+
+			if !strings.Contains(stderr.String(), test.wantStderr) {
+				t.Errorf("got: <%v>, want: <%v>", stderr.String(), test.wantStderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// format is an output format for API responses.
+type format int
+
+const (
+	formatJSON format = iota
+	formatNDJSON
+	formatTable
+)
+
+// parseFormat returns the output format with the provided name.
+func parseFormat(name string) (format, error) {
+	switch name {
+	case "json":
+		return formatJSON, nil
+	case "ndjson":
+		return formatNDJSON, nil
+	case "table":
+		return formatTable, nil
+	}
+
+	return 0, fmt.Errorf("unknown output format '%s': want json, ndjson, or table", name)
+}
+
+// writeResponse writes the provided JSON response body to w in the provided format.
+func writeResponse(w io.Writer, body []byte, f format) error {
+	switch f {
+	case formatNDJSON:
+		return writeNDJSON(w, body)
+	case formatTable:
+		return writeTable(w, body)
+	}
+
+	return writeJSON(w, body)
+}
+
+// writeJSON writes the provided JSON body to w with indentation.
+func writeJSON(w io.Writer, body []byte) error {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, body, "", "  "); err != nil {
+		return fmt.Errorf("cannot format response as JSON: %v", err)
+	}
+	buf.WriteByte('\n')
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// writeNDJSON writes each element of the provided JSON array to w on its own line.
+// A body that is not an array is written as a single line.
+func writeNDJSON(w io.Writer, body []byte) error {
+	var items []json.RawMessage
+	if err := json.Unmarshal(body, &items); err != nil {
+		items = []json.RawMessage{body}
+	}
+
+	for _, item := range items {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, item); err != nil {
+			return fmt.Errorf("cannot format response as NDJSON: %v", err)
+		}
+		buf.WriteByte('\n')
+
+		if _, err := buf.WriteTo(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeTable writes the provided JSON array of objects to w as a table with one
+// row per object and one column per key. The "id" column comes first and the
+// remaining columns are sorted by name. Nested values are written as compact JSON.
+func writeTable(w io.Writer, body []byte) error {
+	var rows []map[string]json.RawMessage
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return fmt.Errorf("cannot format response as a table: want an array of objects: %v", err)
+	}
+
+	cols := tableColumns(rows)
+	if len(cols) == 0 {
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(cols, "\t")))
+	for _, row := range rows {
+		cells := make([]string, len(cols))
+		for i, c := range cols {
+			cells[i] = tableCell(row[c])
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+// tableColumns returns the columns of a table holding the provided rows.
+func tableColumns(rows []map[string]json.RawMessage) []string {
+	seen := map[string]bool{}
+	var cols []string
+	for _, row := range rows {
+		for k := range row {
+			if !seen[k] && k != "id" {
+				seen[k] = true
+				cols = append(cols, k)
+			}
+		}
+	}
+	sort.Strings(cols)
+
+	for _, row := range rows {
+		if _, ok := row["id"]; ok {
+			return append([]string{"id"}, cols...)
+		}
+	}
+
+	return cols
+}
+
+// tableCell returns the text of a table cell holding the provided value.
+func tableCell(v json.RawMessage) string {
+	if len(v) == 0 {
+		return ""
+	}
+
+	var s string
+	if err := json.Unmarshal(v, &s); err == nil {
+		return strings.NewReplacer("\t", " ", "\n", " ").Replace(s)
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, v); err != nil {
+		return string(v)
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteResponse(t *testing.T) {
+	body := []byte(`[{"name":"Halo","id":1,"genres":[5,12]},{"id":2,"name":"Zel\tda","rating":90.5}]`)
+
+	tests := []struct {
+		name      string
+		body      []byte
+		format    string
+		want      string
+		wantError bool
+	}{
+		{"JSON", []byte(`[{"id":1}]`), "json", "[\n  {\n    \"id\": 1\n  }\n]\n", false},
+		{"NDJSON array", body, "ndjson", "{\"name\":\"Halo\",\"id\":1,\"genres\":[5,12]}\n{\"id\":2,\"name\":\"Zel\\tda\",\"rating\":90.5}\n", false},
+		{"NDJSON object", []byte(`{"count": 5}`), "ndjson", "{\"count\":5}\n", false},
+		{"Table", body, "table", "ID  GENRES  NAME    RATING\n1   [5,12]  Halo    \n2           Zel da  90.5\n", false},
+		{"Empty table", []byte(`[]`), "table", "", false},
+		{"Table from object", []byte(`{"count": 5}`), "table", "", true},
+		{"Invalid JSON", []byte(`[{`), "json", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := parseFormat(test.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			err = writeResponse(&buf, test.body, f)
+			if (err != nil) != test.wantError {
+				t.Fatalf("got: <%v>, want error: <%v>", err, test.wantError)
+			}
+
+			if buf.String() != test.want {
+				t.Errorf("got: <%q>, want: <%q>", buf.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name      string
+		want      format
+		wantError bool
+	}{
+		{"json", formatJSON, false},
+		{"ndjson", formatNDJSON, false},
+		{"table", formatTable, false},
+		{"yaml", 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseFormat(test.name)
+			if (err != nil) != test.wantError {
+				t.Errorf("got: <%v>, want error: <%v>", err, test.wantError)
+			}
+
+			if got != test.want {
+				t.Errorf("got: <%v>, want: <%v>", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/Henry-Sarabia/apicalypse"
+	"io"
+	"strings"
+)
+
+// multiFlag is a flag that may be provided more than once.
+type multiFlag []string
+
+// String returns the values of the flag joined by commas.
+func (m *multiFlag) String() string {
+	return strings.Join(*m, ",")
+}
+
+// Set appends a value to the flag.
+func (m *multiFlag) Set(v string) error {
+	*m = append(*m, v)
+	return nil
+}
+
+// queryFlags holds the flags used to build a query.
+type queryFlags struct {
+	fields       string
+	exclude      string
+	where        multiFlag
+	limit        int
+	offset       int
+	sort         string
+	search       string
+	searchColumn string
+}
+
+// register defines the query flags on the provided flag set.
+func (q *queryFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&q.fields, "fields", "", "comma separated `list` of fields to include")
+	fs.StringVar(&q.exclude, "exclude", "", "comma separated `list` of fields to exclude")
+	fs.Var(&q.where, "where", "`filter` the results (may be repeated; filters are AND'd together)")
+	fs.IntVar(&q.limit, "limit", -1, "maximum number of results to return")
+	fs.IntVar(&q.offset, "offset", -1, "index to start returning results from")
+	fs.StringVar(&q.sort, "sort", "", "sort the results by a `field` followed by \"asc\" or \"desc\", with further fields separated by commas breaking ties (e.g. \"rating desc, name\")")
+	fs.StringVar(&q.search, "search", "", "search for a `term`")
+	fs.StringVar(&q.searchColumn, "search-column", "", "`column` to search in (defaults to the API's default column)")
+}
+
+// options returns the functional options described by the query flags.
+func (q *queryFlags) options() ([]apicalypse.Option, error) {
+	var opts []apicalypse.Option
+
+	if q.fields != "" {
+		opts = append(opts, apicalypse.Fields(strings.Split(q.fields, ",")...))
+	}
+	if q.exclude != "" {
+		opts = append(opts, apicalypse.Exclude(strings.Split(q.exclude, ",")...))
+	}
+	if len(q.where) > 0 {
+		opts = append(opts, apicalypse.Where(q.where...))
+	}
+	if q.limit >= 0 {
+		opts = append(opts, apicalypse.Limit(q.limit))
+	}
+	if q.offset >= 0 {
+		opts = append(opts, apicalypse.Offset(q.offset))
+	}
+	if q.sort != "" {
+		keys, err := apicalypse.ParseSort(q.sort)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sort '%s': %v", q.sort, err)
+		}
+		opts = append(opts, apicalypse.SortBy(keys...))
+	}
+	if q.search != "" {
+		opts = append(opts, apicalypse.Search(q.searchColumn, q.search))
+	}
+
+	return opts, nil
+}
+
+// runQuery runs the query command.
+func runQuery(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: apicalypse query [flags]\n\nPrint the query built from the provided flags.\n\nFlags:")
+		fs.PrintDefaults()
+	}
+
+	var q queryFlags
+	q.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	opts, err := q.options()
+	if err != nil {
+		fmt.Fprintf(stderr, "apicalypse query: %v\n", err)
+		return 2
+	}
+
+	s, err := apicalypse.Query(opts...)
+	if err != nil {
+		fmt.Fprintf(stderr, "apicalypse query: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, strings.TrimSpace(s))
+
+	return 0
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"github.com/Henry-Sarabia/apicalypse"
+	"strings"
+	"testing"
+)
+
+func TestRunQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+	}{
+		{"Zero flags", nil, 0, "\n"},
+		{"Fields and limit", []string{"--fields", "name,rating", "--limit", "5"}, 0, "fields name,rating; limit 5;\n"},
+		{"Repeated where", []string{"-where", "rating > 80", "-where", "platforms = 6"}, 0, "where rating > 80 & platforms = 6;\n"},
+		{"Sort with order", []string{"-sort", "rating desc"}, 0, "sort rating desc;\n"},
+		{"Sort without order", []string{"-sort", "rating"}, 0, "sort rating asc;\n"},
+		{"Multiple sort keys", []string{"-sort", "rating desc, name"}, 0, "sort rating desc, name asc;\n"},
+		{"Invalid sort order", []string{"-sort", "rating descending"}, 1, ""},
+		{"Search with column", []string{"-search", "halo", "-search-column", "name"}, 0, "search name \"halo\";\n"},
+		{"All flags", []string{"-fields", "name", "-exclude", "url", "-where", "id > 5", "-limit", "10", "-offset", "20", "-sort", "id asc", "-search", "zelda"}, 0, "fields name; exclude url; search \"zelda\"; where id > 5; sort id asc; limit 10; offset 20;\n"},
+		{"Zero limit", []string{"-limit", "0"}, 0, "limit 0;\n"},
+		{"Blank field", []string{"-fields", "name,,id"}, 1, ""},
+		{"Invalid sort", []string{"-sort", "rating desc extra"}, 2, ""},
+		{"Unknown flag", []string{"-frobnicate"}, 2, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := runQuery(test.args, strings.NewReader(""), &stdout, &stderr)
+			if code != test.wantCode {
+				t.Errorf("got: <%v>, want: <%v>", code, test.wantCode)
+			}
+
+			if code == 0 && stdout.String() != test.wantStdout {
+				t.Errorf("got: <%v>, want: <%v>", stdout.String(), test.wantStdout)
+			}
+		})
+	}
+}
+
+func TestSortAgreement(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"Order", "rating desc", "sort rating desc;"},
+		{"No order", "rating", "sort rating asc;"},
+		{"Mixed keys", "rating desc, name", "sort rating desc, name asc;"},
+		{"Invalid order", "rating descending", ""},
+		{"Extra words", "rating desc extra", ""},
+		{"Blank key", "rating desc,", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := runQuery([]string{"-sort", test.value}, strings.NewReader(""), &stdout, &stderr)
+			if got := strings.TrimSpace(stdout.String()); got != test.want || (code == 0) != (test.want != "") {
+				t.Errorf("query command: got: <%v>, want: <%v>", got, test.want)
+			}
+
+			var got string
+			opt, err := apicalypse.ParseClause("sort", test.value)
+			if err == nil {
+				got, _ = apicalypse.Query(opt)
+			}
+			if got = strings.TrimSpace(got); got != test.want {
+				t.Errorf("ParseClause: got: <%v>, want: <%v>", got, test.want)
+			}
+
+			query := "sort " + test.value + ";"
+			issues := apicalypse.Lint(query)
+			if (len(issues) == 0) != (test.want != "") {
+				t.Errorf("Lint: got: <%v>, want issues: <%v>", issues, test.want == "")
+			}
+
+			// Format does not validate clauses, so only valid sorts are compared.
+			if test.want == "" {
+				return
+			}
+			if got, err := apicalypse.Format(query); err != nil || got != test.want {
+				t.Errorf("Format: got: <%v>, want: <%v>", got, test.want)
+			}
+		})
+	}
+}
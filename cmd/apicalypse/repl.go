@@ -0,0 +1,466 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/Henry-Sarabia/apicalypse"
+	"github.com/pkg/errors"
+	"golang.org/x/term"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// replPrompt is the prompt shown by an interactive session.
+const replPrompt = "apicalypse> "
+
+// maxFieldDepth is the deepest level of nesting at which field names are learned
+// from responses.
+const maxFieldDepth = 3
+
+// replHelp describes the commands understood by the REPL.
+const replHelp = `Commands:
+  endpoint [url]        show or set the endpoint queries are sent to
+  header Name: value    send a header with every query
+  fields a,b,...        include fields
+  exclude a,b,...       exclude fields
+  where <filter>        filter the results (filters are AND'd together)
+  limit <n>             limit the number of results
+  offset <n>            skip the first n results
+  sort <field> <order>  sort the results ("asc" or "desc")
+  search [column] "t"   search for a term
+  show                  print the current query
+  undo                  remove the most recently added option
+  reset                 remove every option
+  history               list the commands entered so far
+  send                  send the query and show the first page of results
+  next, prev            show the next or previous page of results
+  page <n>              show page n of the results
+  help                  show this help
+  quit                  leave the REPL
+`
+
+// clauseCommands are the REPL commands that add an option to the query.
+var clauseCommands = []string{"fields", "exclude", "where", "limit", "offset", "sort", "search"}
+
+// replCommands are every command understood by the REPL.
+var replCommands = append([]string{"endpoint", "header", "show", "undo", "reset", "history", "send", "next", "prev", "page", "help", "quit", "exit"}, clauseCommands...)
+
+// step is an option added to the query by a REPL command.
+type step struct {
+	command string
+	opt     apicalypse.Option
+}
+
+// session is the state of a REPL session.
+type session struct {
+	out      io.Writer
+	client   *apicalypse.Client
+	method   string
+	headers  http.Header
+	timeout  time.Duration
+	format   format
+	pageSize int
+
+	endpoint string
+	steps    []step
+	history  []string
+	results  []json.RawMessage
+	page     int
+	fields   map[string]bool
+}
+
+// lineReader reads the lines entered in a REPL session.
+type lineReader interface {
+	ReadLine() (string, error)
+}
+
+// scanReader is a lineReader for non-interactive input.
+type scanReader struct {
+	*bufio.Scanner
+}
+
+// ReadLine returns the next line of input.
+func (s scanReader) ReadLine() (string, error) {
+	if !s.Scan() {
+		if err := s.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return s.Text(), nil
+}
+
+// runRepl runs the repl command.
+func runRepl(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("repl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: apicalypse repl [flags] [url]\n\nStart an interactive shell for building and sending queries to an endpoint.\n\nFlags:")
+		fs.PrintDefaults()
+	}
+
+	var headers multiFlag
+	method := fs.String("method", http.MethodPost, "HTTP `method` used to send queries")
+	fs.Var(&headers, "H", "`header` to send in the form \"Name: value\" (may be repeated)")
+	output := fs.String("output", "json", "output `format`: json, ndjson, or table")
+	pageSize := fs.Int("page", 10, "number of results shown per page")
+	timeout := fs.Duration("timeout", 30*time.Second, "maximum `duration` to wait for each response")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() > 1 || *pageSize < 1 {
+		fs.Usage()
+		return 2
+	}
+
+	f, err := parseFormat(*output)
+	if err != nil {
+		fmt.Fprintf(stderr, "apicalypse repl: %v\n", err)
+		return 2
+	}
+
+	c, err := apicalypse.NewClient()
+	if err != nil {
+		fmt.Fprintf(stderr, "apicalypse repl: %v\n", err)
+		return 1
+	}
+
+	s := &session{
+		out:      stdout,
+		client:   c,
+		method:   *method,
+		headers:  http.Header{},
+		timeout:  *timeout,
+		format:   f,
+		pageSize: *pageSize,
+		endpoint: fs.Arg(0),
+		fields:   map[string]bool{},
+	}
+	if err := setHeaders(s.headers, headers); err != nil {
+		fmt.Fprintf(stderr, "apicalypse repl: %v\n", err)
+		return 2
+	}
+
+	var lr lineReader = scanReader{bufio.NewScanner(stdin)}
+	if file, ok := stdin.(*os.File); ok && term.IsTerminal(int(file.Fd())) {
+		state, err := term.MakeRaw(int(file.Fd()))
+		if err != nil {
+			fmt.Fprintf(stderr, "apicalypse repl: %v\n", err)
+			return 1
+		}
+		defer term.Restore(int(file.Fd()), state)
+
+		t := term.NewTerminal(struct {
+			io.Reader
+			io.Writer
+		}{stdin, stdout}, replPrompt)
+		t.AutoCompleteCallback = s.complete
+		s.out = t
+		lr = t
+		fmt.Fprintln(s.out, `Type "help" for a list of commands.`)
+	}
+
+	for {
+		line, err := lr.ReadLine()
+		if err != nil {
+			return 0
+		}
+		if s.exec(line) {
+			return 0
+		}
+	}
+}
+
+// exec executes a single line of input and reports whether the session should end.
+func (s *session) exec(line string) bool {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return false
+	}
+	s.history = append(s.history, line)
+
+	cmd, arg := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		cmd, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+
+	switch cmd {
+	case "quit", "exit":
+		return true
+	case "help":
+		fmt.Fprint(s.out, replHelp)
+	case "endpoint":
+		if arg != "" {
+			s.endpoint = arg
+		}
+		fmt.Fprintln(s.out, s.endpoint)
+	case "header":
+		if err := setHeaders(s.headers, []string{arg}); err != nil {
+			s.errorf("%v", err)
+		}
+	case "show":
+		s.show()
+	case "undo":
+		if len(s.steps) == 0 {
+			s.errorf("nothing to undo")
+			break
+		}
+		fmt.Fprintf(s.out, "removed: %s\n", s.steps[len(s.steps)-1].command)
+		s.steps = s.steps[:len(s.steps)-1]
+	case "reset":
+		s.steps = nil
+	case "history":
+		for i, h := range s.history[:len(s.history)-1] {
+			fmt.Fprintf(s.out, "%4d  %s\n", i+1, h)
+		}
+	case "send":
+		s.send()
+	case "next":
+		s.showPage(s.page + 1)
+	case "prev":
+		s.showPage(s.page - 1)
+	case "page":
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			s.errorf("invalid page '%s'", arg)
+			break
+		}
+		s.showPage(n - 1)
+	default:
+		if !contains(clauseCommands, cmd) {
+			s.errorf("unknown command '%s' (type \"help\" for a list of commands)", cmd)
+			break
+		}
+		s.addClause(cmd, arg, line)
+	}
+
+	return false
+}
+
+// addClause adds the option described by a clause command to the query.
+func (s *session) addClause(cmd, arg, line string) {
+	opt, err := apicalypse.ParseClause(cmd, arg)
+	if err != nil {
+		s.errorf("%s: %v", cmd, err)
+		return
+	}
+
+	if _, err := apicalypse.Query(append(s.options(), opt)...); err != nil {
+		s.errorf("%s: %v", cmd, errors.Cause(err))
+		return
+	}
+	s.steps = append(s.steps, step{command: line, opt: opt})
+}
+
+// options returns the options added to the query so far.
+func (s *session) options() []apicalypse.Option {
+	opts := make([]apicalypse.Option, len(s.steps))
+	for i, st := range s.steps {
+		opts[i] = st.opt
+	}
+	return opts
+}
+
+// show prints the current query.
+func (s *session) show() {
+	q, err := apicalypse.Query(s.options()...)
+	if err != nil {
+		s.errorf("%v", err)
+		return
+	}
+	fmt.Fprintln(s.out, strings.TrimSpace(q))
+}
+
+// send sends the current query to the endpoint and shows the first page of results.
+func (s *session) send() {
+	if s.endpoint == "" {
+		s.errorf("no endpoint set (use \"endpoint <url>\")")
+		return
+	}
+
+	req, err := apicalypse.NewRequest(s.method, s.endpoint, s.options()...)
+	if err != nil {
+		s.errorf("%v", err)
+		return
+	}
+	for k, v := range s.headers {
+		req.Header[k] = v
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	resp, err := s.client.Do(ctx, req)
+	if err != nil {
+		s.errorf("%v", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		s.errorf("cannot read response body: %v", err)
+		return
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		s.errorf("unexpected status '%s'\n%s", resp.Status, body)
+		return
+	}
+
+	var results []json.RawMessage
+	if err := json.Unmarshal(body, &results); err != nil {
+		// Responses that are not arrays, such as counts, are shown as they are.
+		if err := writeResponse(s.out, body, formatJSON); err != nil {
+			s.errorf("%v", err)
+		}
+		return
+	}
+
+	s.results = results
+	for _, r := range results {
+		learnFields(s.fields, "", r, 1)
+	}
+	s.showPage(0)
+}
+
+// showPage shows the results on the provided page, starting at 0.
+func (s *session) showPage(n int) {
+	if s.results == nil {
+		s.errorf("no results (use \"send\")")
+		return
+	}
+
+	pages := (len(s.results) + s.pageSize - 1) / s.pageSize
+	if n < 0 || (n >= pages && n > 0) {
+		s.errorf("no such page (there are %d)", pages)
+		return
+	}
+	s.page = n
+
+	end := (n + 1) * s.pageSize
+	if end > len(s.results) {
+		end = len(s.results)
+	}
+
+	b, err := json.Marshal(s.results[n*s.pageSize : end])
+	if err != nil {
+		s.errorf("%v", err)
+		return
+	}
+	if err := writeResponse(s.out, b, s.format); err != nil {
+		s.errorf("%v", err)
+		return
+	}
+
+	fmt.Fprintf(s.out, "-- page %d of %d (%d results) --\n", n+1, pages, len(s.results))
+}
+
+// errorf prints an error message.
+func (s *session) errorf(format string, args ...interface{}) {
+	fmt.Fprintf(s.out, "error: "+format+"\n", args...)
+}
+
+// complete completes the command or field name being typed when tab is pressed.
+// Commands are completed at the start of the line and field names, learned from
+// previous responses, after a clause command.
+func (s *session) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+
+	head := line[:pos]
+	start := strings.LastIndexAny(head, " \t,(&|!=<>") + 1
+	word := head[start:]
+
+	var candidates []string
+	if start == 0 {
+		candidates = replCommands
+	} else {
+		cmd := strings.Fields(head)[0]
+		if !contains(clauseCommands, cmd) {
+			return "", 0, false
+		}
+		for f := range s.fields {
+			candidates = append(candidates, f)
+		}
+	}
+
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, word) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+	sort.Strings(matches)
+
+	completion := commonPrefix(matches)
+	if len(matches) == 1 && start == 0 {
+		completion += " "
+	}
+	if completion == word {
+		return "", 0, false
+	}
+
+	return head[:start] + completion + line[pos:], start + len(completion), true
+}
+
+// learnFields adds the names of the fields of the provided JSON value to fields.
+// Nested objects, including those inside arrays, add their fields joined to the
+// name of their parent by a dot.
+func learnFields(fields map[string]bool, prefix string, v json.RawMessage, depth int) {
+	if depth > maxFieldDepth {
+		return
+	}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(v, &obj); err == nil {
+		for k, child := range obj {
+			name := prefix + k
+			fields[name] = true
+			learnFields(fields, name+".", child, depth+1)
+		}
+		return
+	}
+
+	var arr []json.RawMessage
+	if err := json.Unmarshal(v, &arr); err == nil {
+		for _, child := range arr {
+			learnFields(fields, prefix, child, depth)
+		}
+	}
+}
+
+// commonPrefix returns the longest prefix shared by every provided string.
+func commonPrefix(ss []string) string {
+	p := ss[0]
+	for _, s := range ss[1:] {
+		for !strings.HasPrefix(s, p) {
+			p = p[:len(p)-1]
+		}
+	}
+	return p
+}
+
+// contains reports whether ss contains s.
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
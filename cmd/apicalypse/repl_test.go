@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRunRepl(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Client-ID") != "abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(`[{"id":1,"query":"` + string(b) + `"},{"id":2},{"id":3}]`))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		args     []string
+		input    string
+		wantCode int
+		want     []string
+	}{
+		{"Build query", nil, "fields name\nwhere a = 1\nwhere b = 2\nlimit 5\nshow\n", 0, []string{"fields name; where b = 2 & a = 1; limit 5;"}},
+		{"Undo", nil, "limit 5\noffset 2\nundo\nshow\n", 0, []string{"removed: offset 2", "limit 5;"}},
+		{"Reset", nil, "limit 5\nreset\nshow\nundo\n", 0, []string{"error: nothing to undo"}},
+		{"Invalid option", nil, "limit -5\nsort name up\nshow\n", 0, []string{"error: limit: input cannot be a negative number", "error: sort: sort order must be asc or desc"}},
+		{"Unknown command", nil, "order name\n", 0, []string{"error: unknown command 'order'"}},
+		{"History", nil, "limit 5\nshow\nhistory\n", 0, []string{"   1  limit 5", "   2  show"}},
+		{"Send without endpoint", nil, "send\n", 0, []string{"error: no endpoint set"}},
+		{"Send", []string{"-H", "Client-ID: abc", "-output", "ndjson", ts.URL}, "limit 5\nsend\n", 0, []string{`{"id":1,"query":"limit 5; "}`, "-- page 1 of 1 (3 results) --"}},
+		{"Paging", []string{"-H", "Client-ID: abc", "-output", "ndjson", "-page", "2", ts.URL}, "send\nnext\nnext\nprev\npage 2\n", 0, []string{"-- page 2 of 2 (3 results) --", `{"id":3}`, "error: no such page (there are 2)", "-- page 1 of 2 (3 results) --"}},
+		{"Endpoint and header commands", []string{"-output", "ndjson"}, "endpoint " + ts.URL + "\nheader Client-ID: abc\nsend\n", 0, []string{ts.URL, `{"id":2}`}},
+		{"Unsuccessful status", []string{ts.URL}, "send\n", 0, []string{"error: unexpected status '401 Unauthorized'"}},
+		{"Next without results", nil, "next\n", 0, []string{"error: no results"}},
+		{"Invalid page size", []string{"-page", "0"}, "", 2, nil},
+		{"Too many arguments", []string{"a", "b"}, "", 2, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := runRepl(test.args, strings.NewReader(test.input), &stdout, &stderr)
+			if code != test.wantCode {
+				t.Errorf("got: <%v>, want: <%v>", code, test.wantCode)
+			}
+
+			for _, w := range test.want {
+				if !strings.Contains(stdout.String(), w) {
+					t.Errorf("got: <%v>, want: <%v>", stdout.String(), w)
+				}
+			}
+		})
+	}
+}
+
+func TestRunReplQuit(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	runRepl(nil, strings.NewReader("limit 5\nquit\nshow\n"), &stdout, &stderr)
+
+	if stdout.String() != "" {
+		t.Errorf("got: <%v>, want: <%v>", stdout.String(), "")
+	}
+}
+
+func TestLearnFields(t *testing.T) {
+	fields := map[string]bool{}
+	learnFields(fields, "", json.RawMessage(`{"id":1,"cover":{"url":"x"},"genres":[{"name":"a"},{"slug":"b"}],"tags":[1,2]}`), 1)
+
+	var got []string
+	for f := range fields {
+		got = append(got, f)
+	}
+	sort.Strings(got)
+
+	want := []string{"cover", "cover.url", "genres", "genres.name", "genres.slug", "id", "tags"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: <%v>, want: <%v>", got, want)
+	}
+}
+
+func TestSessionComplete(t *testing.T) {
+	s := &session{fields: map[string]bool{"name": true, "cover.url": true, "cover.width": true, "rating": true}}
+
+	tests := []struct {
+		name    string
+		line    string
+		pos     int
+		key     rune
+		want    string
+		wantPos int
+		wantOK  bool
+	}{
+		{"Command", "li", 2, '\t', "limit ", 6, true},
+		{"Ambiguous command", "s", 1, '\t', "", 0, false},
+		{"Common prefix of commands", "se", 2, '\t', "se", 0, false},
+		{"Field", "fields na", 9, '\t', "fields name", 11, true},
+		{"Field after comma", "fields name,ra", 14, '\t', "fields name,rating", 18, true},
+		{"Nested field prefix", "fields cov", 10, '\t', "fields cover.", 13, true},
+		{"Field in where", "where rat", 9, '\t', "where rating", 12, true},
+		{"Field before cursor", "where na = 1", 8, '\t', "where name = 1", 10, true},
+		{"No field completion for other commands", "endpoint na", 11, '\t', "", 0, false},
+		{"Other key", "li", 2, 'x', "", 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, gotPos, ok := s.complete(test.line, test.pos, test.key)
+			if ok != test.wantOK {
+				t.Fatalf("got: <%v>, want: <%v>", ok, test.wantOK)
+			}
+			if !ok {
+				return
+			}
+
+			if got != test.want {
+				t.Errorf("got: <%v>, want: <%v>", got, test.want)
+			}
+
+			if gotPos != test.wantPos {
+				t.Errorf("got: <%v>, want: <%v>", gotPos, test.wantPos)
+			}
+		})
+	}
+}
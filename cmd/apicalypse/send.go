@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/Henry-Sarabia/apicalypse"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// runSend runs the send command.
+func runSend(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("send", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: apicalypse send [flags] <url>\n\nSend the query built from the provided flags to an endpoint and print the response.\n\nFlags:")
+		fs.PrintDefaults()
+	}
+
+	var (
+		q       queryFlags
+		headers multiFlag
+	)
+	q.register(fs)
+	method := fs.String("method", http.MethodPost, "HTTP `method` used to send the query")
+	fs.Var(&headers, "H", "`header` to send in the form \"Name: value\" (may be repeated)")
+	output := fs.String("output", "json", "output `format`: json, ndjson, or table")
+	timeout := fs.Duration("timeout", 30*time.Second, "maximum `duration` to wait for the response")
+	export := fs.String("export", "", "print the request in the given `format` (curl, http, or har) instead of sending it")
+	redact := fs.Bool("redact", true, "redact credentials, such as the Authorization header, from exported requests")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	format, err := parseFormat(*output)
+	if err != nil {
+		fmt.Fprintf(stderr, "apicalypse send: %v\n", err)
+		return 2
+	}
+
+	opts, err := q.options()
+	if err != nil {
+		fmt.Fprintf(stderr, "apicalypse send: %v\n", err)
+		return 2
+	}
+
+	req, err := apicalypse.NewRequest(*method, fs.Arg(0), opts...)
+	if err != nil {
+		fmt.Fprintf(stderr, "apicalypse send: %v\n", err)
+		return 1
+	}
+
+	if err := setHeaders(req.Header, headers); err != nil {
+		fmt.Fprintf(stderr, "apicalypse send: %v\n", err)
+		return 2
+	}
+
+	if *export != "" {
+		return exportRequest(req, *export, *redact, stdout, stderr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	c, err := apicalypse.NewClient()
+	if err != nil {
+		fmt.Fprintf(stderr, "apicalypse send: %v\n", err)
+		return 1
+	}
+
+	resp, err := c.Do(ctx, req)
+	if err != nil {
+		fmt.Fprintf(stderr, "apicalypse send: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Fprintf(stderr, "apicalypse send: cannot read response body: %v\n", err)
+		return 1
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		fmt.Fprintf(stderr, "apicalypse send: unexpected status '%s'\n%s\n", resp.Status, body)
+		return 1
+	}
+
+	if err := writeResponse(stdout, body, format); err != nil {
+		fmt.Fprintf(stderr, "apicalypse send: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// setHeaders adds the provided headers in the form "Name: value" to h.
+func setHeaders(h http.Header, headers []string) error {
+	for _, hdr := range headers {
+		i := strings.Index(hdr, ":")
+		if i <= 0 {
+			return fmt.Errorf("invalid header '%s': want \"Name: value\"", hdr)
+		}
+		h.Add(strings.TrimSpace(hdr[:i]), strings.TrimSpace(hdr[i+1:]))
+	}
+
+	return nil
+}
+
+// exportFormats are the formats requests can be exported in by name.
+var exportFormats = map[string]apicalypse.ExportFormat{
+	"curl": apicalypse.CurlFormat,
+	"http": apicalypse.HTTPFormat,
+	"har":  apicalypse.HARFormat,
+}
+
+// exportRequest prints the provided request in the named format and returns the
+// exit code.
+func exportRequest(req *http.Request, name string, redact bool, stdout, stderr io.Writer) int {
+	format, ok := exportFormats[name]
+	if !ok {
+		fmt.Fprintf(stderr, "apicalypse send: unknown export format '%s': want curl, http, or har\n", name)
+		return 2
+	}
+
+	var headers []string
+	if redact {
+		headers = apicalypse.CredentialHeaders
+	}
+
+	s, err := apicalypse.Export(req, format, headers...)
+	if err != nil {
+		fmt.Fprintf(stderr, "apicalypse send: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, strings.TrimRight(s, "\r\n"))
+
+	return 0
+}
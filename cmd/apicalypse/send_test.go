@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunSend(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Client-ID") != "abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message":"missing client id"}`))
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(`[{"method":"` + r.Method + `","query":"` + string(b) + `"}]`))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{"Default flags", []string{"-H", "Client-ID: abc", "-limit", "5", ts.URL}, 0, "[\n  {\n    \"method\": \"POST\",\n    \"query\": \"limit 5; \"\n  }\n]\n", ""},
+		{"Custom method", []string{"-H", "Client-ID: abc", "-method", "GET", "-output", "ndjson", ts.URL}, 0, "{\"method\":\"GET\",\"query\":\"\"}\n", ""},
+		{"Unsuccessful status", []string{"-limit", "5", ts.URL}, 1, "", "401 Unauthorized"},
+		{"Invalid header", []string{"-H", "Client-ID abc", ts.URL}, 2, "", "invalid header"},
+		{"Invalid output", []string{"-output", "xml", ts.URL}, 2, "", "unknown output format"},
+		{"Missing url", []string{"-limit", "5"}, 2, "", "Usage:"},
+		{"Invalid option", []string{"-fields", " ", ts.URL}, 1, "", "blank"},
+		{"Export curl", []string{"-H", "Client-ID: abc", "-limit", "5", "-export", "curl", "http://fake.com/games"}, 0, "curl \\\n  -X POST \\\n  'http://fake.com/games' \\\n  -H 'Client-Id: REDACTED' \\\n  --data-raw 'limit 5; '\n", ""},
+		{"Export without redaction", []string{"-H", "Client-ID: abc", "-export", "http", "-redact=false", "http://fake.com/games"}, 0, "POST /games HTTP/1.1\r\nHost: fake.com\r\nUser-Agent: Go-http-client/1.1\r\nContent-Length: 0\r\nClient-Id: abc\n", ""},
+		{"Invalid export", []string{"-export", "wget", ts.URL}, 2, "", "unknown export format"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := runSend(test.args, strings.NewReader(""), &stdout, &stderr)
+			if code != test.wantCode {
+				t.Errorf("got: <%v>, want: <%v>", code, test.wantCode)
+			}
+
+			if stdout.String() != test.wantStdout {
+				t.Errorf("got: <%v>, want: <%v>", stdout.String(), test.wantStdout)
+			}
+
+			if !strings.Contains(stderr.String(), test.wantStderr) {
+				t.Errorf("got: <%v>, want: <%v>", stderr.String(), test.wantStderr)
+			}
+		})
+	}
+}
+
+func TestSetHeaders(t *testing.T) {
+	tests := []struct {
+		name      string
+		headers   []string
+		want      http.Header
+		wantError bool
+	}{
+		{"Zero headers", nil, http.Header{}, false},
+		{"Multiple headers", []string{"Client-ID: abc", "Authorization:Bearer xyz"}, http.Header{"Client-Id": {"abc"}, "Authorization": {"Bearer xyz"}}, false},
+		{"Missing colon", []string{"Client-ID abc"}, http.Header{}, true},
+		{"Missing name", []string{": abc"}, http.Header{}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := http.Header{}
+			err := setHeaders(h, test.headers)
+			if (err != nil) != test.wantError {
+				t.Errorf("got: <%v>, want error: <%v>", err, test.wantError)
+			}
+
+			if !test.wantError && len(h) != len(test.want) {
+				t.Errorf("got: <%v>, want: <%v>", h, test.want)
+			}
+			for k, v := range test.want {
+				if h.Get(k) != v[0] {
+					t.Errorf("got: <%v>, want: <%v>", h.Get(k), v[0])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package apicalypse
+
+import (
+	"bytes"
+	"context"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WithCoalescing is a functional option that makes the Client share a single HTTP
+// call between concurrent identical requests. Requests are identical when they
+// have the same method, URL, and canonical query text, and carry the same
+// credential and Accept headers, if any, as for caching. Every caller receives its
+// own copy of the shared response.
+//
+// A caller whose context is done stops waiting without affecting the other callers.
+// The shared call is only canceled once every caller waiting on it has given up.
+func WithCoalescing() ClientOption {
+	return func(c *Client) error {
+		c.flights = &flightGroup{}
+		return nil
+	}
+}
+
+// flightGroup deduplicates concurrent calls that share a key.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+// flight is a call in progress or completed by a flightGroup.
+type flight struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+	resp    *http.Response
+	body    []byte
+	err     error
+}
+
+// do calls fn with the provided request unless a call with the same key is
+// already in flight, in which case it waits for that call's response instead.
+func (g *flightGroup) do(ctx context.Context, key string, req *http.Request, fn func(context.Context, *http.Request) (*http.Response, error)) (*http.Response, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flight{}
+	}
+
+	f, ok := g.calls[key]
+	if !ok {
+		fctx, cancel := context.WithCancel(detachedContext{ctx})
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = f
+		go g.run(fctx, key, f, req.WithContext(fctx), fn)
+	}
+	f.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		if f.err != nil {
+			return nil, f.err
+		}
+		return f.copy(req), nil
+	case <-ctx.Done():
+		g.leave(key, f)
+		return nil, errors.Wrap(ctx.Err(), "cannot wait for response")
+	}
+}
+
+// run performs the call for the provided flight and records its result.
+func (g *flightGroup) run(ctx context.Context, key string, f *flight, req *http.Request, fn func(context.Context, *http.Request) (*http.Response, error)) {
+	defer f.cancel()
+
+	resp, err := fn(ctx, req)
+	if err == nil {
+		f.body, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			err = errors.Wrap(err, "cannot read response body")
+		}
+	}
+	f.resp, f.err = resp, err
+
+	g.mu.Lock()
+	if g.calls[key] == f {
+		delete(g.calls, key)
+	}
+	g.mu.Unlock()
+
+	close(f.done)
+}
+
+// leave removes a waiter from the provided flight, canceling the flight if no
+// waiters remain.
+func (g *flightGroup) leave(key string, f *flight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	f.waiters--
+	if f.waiters > 0 {
+		return
+	}
+
+	if g.calls[key] == f {
+		delete(g.calls, key)
+	}
+	f.cancel()
+}
+
+// detachedContext is a context that keeps the values of its parent but is never
+// canceled and has no deadline, so that a shared call outlives the caller that
+// started it.
+type detachedContext struct {
+	parent context.Context
+}
+
+// Deadline reports that the context has no deadline.
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+// Done returns nil, as the context is never canceled.
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+// Err returns nil, as the context is never canceled.
+func (detachedContext) Err() error { return nil }
+
+// Value returns the parent's value for the provided key.
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// copy returns a copy of the flight's response for the provided request.
+func (f *flight) copy(req *http.Request) *http.Response {
+	resp := *f.resp
+	resp.Header = f.resp.Header.Clone()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(f.body))
+	resp.ContentLength = int64(len(f.body))
+	resp.Request = req
+
+	return &resp
+}
@@ -0,0 +1,176 @@
+package apicalypse
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientDoCoalescing(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         func(i int) []Option
+		wantRequests int32
+	}{
+		{"Identical queries", func(i int) []Option { return []Option{Fields("id"), Limit(5)} }, 1},
+		{"Equivalent queries", func(i int) []Option {
+			if i%2 == 0 {
+				return []Option{Fields("id", "name")}
+			}
+			return []Option{Fields("name", "id")}
+		}, 1},
+		{"Different queries", func(i int) []Option { return []Option{Limit(i + 1)} }, 8},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests int32
+			unblock := make(chan struct{})
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				<-unblock
+				w.Write([]byte(`[{"id":1}]`))
+			}))
+			defer ts.Close()
+
+			c, err := NewClient(WithCoalescing())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					resp, err := c.Send(context.Background(), "POST", ts.URL, test.opts(i)...)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					defer resp.Body.Close()
+
+					b, err := ioutil.ReadAll(resp.Body)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+
+					if string(b) != `[{"id":1}]` {
+						t.Errorf("got: <%v>, want: <%v>", string(b), `[{"id":1}]`)
+					}
+				}(i)
+			}
+
+			// Give every goroutine a chance to join a flight before responding.
+			time.Sleep(50 * time.Millisecond)
+			close(unblock)
+			wg.Wait()
+
+			if requests != test.wantRequests {
+				t.Errorf("got: <%v>, want: <%v>", requests, test.wantRequests)
+			}
+		})
+	}
+}
+
+func TestClientDoCoalescingCancel(t *testing.T) {
+	unblock := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+		w.Write([]byte("[]"))
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(WithCoalescing())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first caller gives up early while the second keeps waiting.
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 2)
+	go func() {
+		_, err := c.Send(ctx, "POST", ts.URL, Limit(1))
+		errc <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	go func() {
+		resp, err := c.Send(context.Background(), "POST", ts.URL, Limit(1))
+		if err == nil {
+			resp.Body.Close()
+		}
+		errc <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	cancel()
+	if err := <-errc; errors.Cause(err) != context.Canceled {
+		t.Errorf("got: <%v>, want: <%v>", err, context.Canceled)
+	}
+
+	close(unblock)
+	if err := <-errc; err != nil {
+		t.Errorf("got: <%v>, want: <%v>", err, nil)
+	}
+}
+
+func TestClientDoCoalescingCancelAll(t *testing.T) {
+	canceled := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices a closed connection once the body is read.
+		ioutil.ReadAll(r.Body)
+		<-r.Context().Done()
+		close(canceled)
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(WithCoalescing())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.Send(ctx, "POST", ts.URL, Limit(1))
+		errc <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	if err := <-errc; errors.Cause(err) != context.Canceled {
+		t.Errorf("got: <%v>, want: <%v>", err, context.Canceled)
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("got: <upstream request not canceled>, want: <upstream request canceled>")
+	}
+}
+
+func TestDetachedContext(t *testing.T) {
+	type key struct{}
+	parent, cancel := context.WithTimeout(context.WithValue(context.Background(), key{}, "value"), time.Hour)
+	cancel()
+
+	ctx := detachedContext{parent}
+	if ctx.Err() != nil || ctx.Done() != nil {
+		t.Errorf("got: <%v>, want: <%v>", ctx.Err(), nil)
+	}
+
+	if _, ok := ctx.Deadline(); ok {
+		t.Errorf("got: <%v>, want: <%v>", ok, false)
+	}
+
+	if got := ctx.Value(key{}); got != "value" {
+		t.Errorf("got: <%v>, want: <%v>", got, "value")
+	}
+}
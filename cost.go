@@ -0,0 +1,330 @@
+package apicalypse
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Cost is the estimated cost of a query along with the measurements it is based
+// on. The measurements of a multiquery are summed across its subqueries, except
+// for Depth, which is the deepest of them.
+type Cost struct {
+	// Fields is the number of fields in the fields clause, including wildcards.
+	Fields int
+	// Depth is the deepest expansion of a field, such as 2 for "cover.image.url"
+	// or "cover.image.*".
+	Depth int
+	// Wildcards is the number of fields in the fields clause that are or end
+	// with "*".
+	Wildcards int
+	// WhereTerms is the number of comparisons in the where clause. A search
+	// clause counts as one more term.
+	WhereTerms int
+	// Limit is the number of results requested, which is the CostModel's
+	// DefaultLimit for queries without a limit clause.
+	Limit int
+	// Subqueries is the number of subqueries of a multiquery, or 1 for a query.
+	Subqueries int
+	// Total is the estimated cost computed from the measurements by a CostModel.
+	Total float64
+}
+
+// add adds the measurements and total of the provided cost to c.
+func (c *Cost) add(o Cost) {
+	c.Fields += o.Fields
+	c.Wildcards += o.Wildcards
+	c.WhereTerms += o.WhereTerms
+	c.Limit += o.Limit
+	c.Subqueries += o.Subqueries
+	c.Total += o.Total
+	if o.Depth > c.Depth {
+		c.Depth = o.Depth
+	}
+}
+
+// CostModel holds the weights used to estimate the cost of a query. The cost of
+// a query is the sum of the weight of each of its fields, the weight of its where
+// terms, and the weight of its requested results. A field weighs Field, or
+// Wildcard if it is a wildcard, multiplied by 1 plus Depth for every level it is
+// expanded. Each where term weighs WhereTerm and each requested result weighs
+// Result. The cost of a multiquery is the sum of the cost of its subqueries.
+type CostModel struct {
+	Field     float64
+	Wildcard  float64
+	Depth     float64
+	WhereTerm float64
+	Result    float64
+	// DefaultLimit is the number of results assumed for queries without a limit
+	// clause.
+	DefaultLimit int
+}
+
+// DefaultCostModel is the CostModel used by EstimateCost and by Budgets without
+// a model of their own. Wildcards weigh as much as ten fields, each level of
+// expansion doubles the weight of a field, and queries without a limit are
+// assumed to request 10 results, the default of most Apicalypse APIs.
+var DefaultCostModel = CostModel{
+	Field:        1,
+	Wildcard:     10,
+	Depth:        1,
+	WhereTerm:    2,
+	Result:       0.1,
+	DefaultLimit: 10,
+}
+
+// EstimateCost estimates the cost of the query the provided functional options
+// make up using the DefaultCostModel.
+func EstimateCost(opts ...Option) (Cost, error) {
+	return DefaultCostModel.Estimate(opts...)
+}
+
+// Estimate estimates the cost of the query the provided functional options make
+// up.
+func (m CostModel) Estimate(opts ...Option) (Cost, error) {
+	filters, err := queryFilters(opts...)
+	if err != nil {
+		return Cost{}, errors.Wrap(err, "cannot estimate cost")
+	}
+
+	return m.estimate(filters)
+}
+
+// EstimateMultiquery estimates the cost of the multiquery the provided subqueries
+// make up.
+func (m CostModel) EstimateMultiquery(subs ...Subquery) (Cost, error) {
+	if len(subs) <= 0 {
+		return Cost{}, ErrMissingInput
+	}
+
+	var total Cost
+	for _, s := range subs {
+		c, err := m.Estimate(s.Options...)
+		if err != nil {
+			return Cost{}, errors.Wrapf(err, "subquery '%s'", s.Name)
+		}
+		total.add(c)
+	}
+
+	return total, nil
+}
+
+// EstimateQuery estimates the cost of the provided query string, which may be a
+// multiquery.
+func (m CostModel) EstimateQuery(query string) (Cost, error) {
+	scan := scanQuery(query, 0)
+	if len(scan.problems) > 0 {
+		p := scan.problems[0]
+		return Cost{}, errors.Errorf("cannot estimate cost: %v: %s", position(query, p.offset), p.message)
+	}
+
+	filters := map[string]string{}
+	var subs Cost
+	for _, c := range scan.clauses(query) {
+		switch {
+		case c.keyword == "":
+			continue
+		case c.keyword == "query":
+			open, close := strings.Index(c.value, "{"), strings.LastIndex(c.value, "}")
+			if open < 0 || close < open {
+				return Cost{}, errors.Errorf("cannot estimate cost: %v: subquery is missing its body", position(query, c.valueOffset))
+			}
+			sc, err := m.EstimateQuery(c.value[open+1 : close])
+			if err != nil {
+				return Cost{}, errors.Wrapf(err, "%v", position(query, c.valueOffset))
+			}
+			subs.add(sc)
+		default:
+			if _, ok := filters[c.keyword]; ok {
+				return Cost{}, errors.Errorf("cannot estimate cost: %v: clause '%s' is repeated", position(query, c.offset), c.keyword)
+			}
+			filters[c.keyword] = c.value
+		}
+	}
+
+	if subs.Subqueries > 0 {
+		return subs, nil
+	}
+
+	return m.estimate(filters)
+}
+
+// estimate estimates the cost of a query with the provided clauses.
+func (m CostModel) estimate(filters map[string]string) (Cost, error) {
+	c := Cost{Subqueries: 1, Limit: m.DefaultLimit}
+
+	if v, ok := filters["fields"]; ok {
+		for _, f := range strings.Split(v, ",") {
+			f = strings.TrimSpace(f)
+			if f == "" {
+				continue
+			}
+
+			depth := strings.Count(f, ".")
+			weight := m.Field
+			if f == "*" || strings.HasSuffix(f, ".*") {
+				weight = m.Wildcard
+				c.Wildcards++
+			}
+
+			c.Fields++
+			c.Total += weight * (1 + m.Depth*float64(depth))
+			if depth > c.Depth {
+				c.Depth = depth
+			}
+		}
+	}
+
+	if v, ok := filters["where"]; ok {
+		comps, err := parseWhere(v)
+		if err != nil {
+			return Cost{}, errors.Wrap(err, "cannot estimate cost of where clause")
+		}
+		c.WhereTerms += len(comps)
+	}
+	if _, ok := filters["search"]; ok {
+		c.WhereTerms++
+	}
+
+	if v, ok := filters["limit"]; ok {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return Cost{}, errors.Wrapf(err, "cannot estimate cost of limit '%s'", v)
+		}
+		c.Limit = n
+	}
+
+	c.Total += m.WhereTerm*float64(c.WhereTerms) + m.Result*float64(c.Limit)
+
+	return c, nil
+}
+
+// Budget caps the estimated cost of the queries sent by a Client. A zero maximum
+// leaves its measurement uncapped.
+type Budget struct {
+	// Model is the CostModel costs are estimated with. Defaults to the
+	// DefaultCostModel. WithBudget keeps a copy of the model, so later changes to
+	// it do not affect the Client.
+	Model *CostModel
+
+	MaxTotal      float64
+	MaxFields     int
+	MaxDepth      int
+	MaxWildcards  int
+	MaxWhereTerms int
+	MaxLimit      int
+	MaxSubqueries int
+
+	// Warn, if set, is called with queries over budget, which are then sent
+	// anyway instead of being rejected.
+	Warn func(req *http.Request, err *BudgetError)
+}
+
+// BudgetError occurs when the estimated cost of a query exceeds a Budget.
+type BudgetError struct {
+	// Cost is the estimated cost of the query.
+	Cost Cost
+	// Exceeded describes each maximum that was exceeded, such as "depth 3 > 2".
+	Exceeded []string
+}
+
+// Error returns the exceeded maximums.
+func (e *BudgetError) Error() string {
+	return "query exceeds budget: " + strings.Join(e.Exceeded, ", ")
+}
+
+// Check returns a *BudgetError if the provided cost exceeds any of the Budget's
+// maximums.
+func (b Budget) Check(c Cost) error {
+	var exceeded []string
+	if b.MaxTotal > 0 && c.Total > b.MaxTotal {
+		exceeded = append(exceeded, fmt.Sprintf("total %g > %g", c.Total, b.MaxTotal))
+	}
+
+	limits := []struct {
+		name string
+		n    int
+		max  int
+	}{
+		{"fields", c.Fields, b.MaxFields},
+		{"depth", c.Depth, b.MaxDepth},
+		{"wildcards", c.Wildcards, b.MaxWildcards},
+		{"where terms", c.WhereTerms, b.MaxWhereTerms},
+		{"limit", c.Limit, b.MaxLimit},
+		{"subqueries", c.Subqueries, b.MaxSubqueries},
+	}
+	for _, l := range limits {
+		if l.max > 0 && l.n > l.max {
+			exceeded = append(exceeded, fmt.Sprintf("%s %d > %d", l.name, l.n, l.max))
+		}
+	}
+
+	if len(exceeded) > 0 {
+		return &BudgetError{Cost: c, Exceeded: exceeded}
+	}
+
+	return nil
+}
+
+// WithBudget is a functional option for checking the estimated cost of every
+// request's query against the provided Budget before the request is sent.
+// Requests over budget fail with a *BudgetError, or are reported to the Budget's
+// Warn function and sent anyway if it is set.
+func WithBudget(b Budget) ClientOption {
+	return func(c *Client) error {
+		if b.MaxTotal < 0 || b.MaxFields < 0 || b.MaxDepth < 0 || b.MaxWildcards < 0 ||
+			b.MaxWhereTerms < 0 || b.MaxLimit < 0 || b.MaxSubqueries < 0 {
+			return ErrNegativeInput
+		}
+		m := DefaultCostModel
+		if b.Model != nil {
+			m = *b.Model
+		}
+		b.Model = &m
+		c.budget = &b
+
+		return nil
+	}
+}
+
+// check estimates the cost of the provided request's query and checks it
+// against the Budget, calling Warn instead of returning a *BudgetError if set.
+func (b *Budget) check(req *http.Request) error {
+	if b == nil {
+		return nil
+	}
+
+	cost, err := b.estimate(req)
+	if err != nil {
+		return err
+	}
+
+	err = b.Check(cost)
+	if berr, ok := err.(*BudgetError); ok && b.Warn != nil {
+		b.Warn(req, berr)
+		return nil
+	}
+
+	return err
+}
+
+// estimate estimates the cost of the query in the provided request's body or, if
+// the body is empty, in its url parameters.
+func (b *Budget) estimate(req *http.Request) (Cost, error) {
+	q, ok := queryText(req)
+	if !ok {
+		return Cost{}, errors.New("cannot read query from request body")
+	}
+	if strings.TrimSpace(q) != "" {
+		return b.Model.EstimateQuery(q)
+	}
+
+	filters, err := RequestClauses(req)
+	if err != nil {
+		return Cost{}, err
+	}
+
+	return b.Model.estimate(filters)
+}
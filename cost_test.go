@@ -0,0 +1,213 @@
+package apicalypse
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestEstimateCost(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    Cost
+		wantErr bool
+	}{
+		{"Expanded fields", []Option{Fields("name", "cover.url", "cover.image.*"), Where("rating > 80 & genres = (4, 5)"), Limit(50)}, Cost{Fields: 3, Depth: 2, Wildcards: 1, WhereTerms: 2, Limit: 50, Subqueries: 1, Total: 42}, false},
+		{"Default limit", []Option{Fields("*")}, Cost{Fields: 1, Wildcards: 1, Limit: 10, Subqueries: 1, Total: 11}, false},
+		{"Search term", []Option{Search("", "halo"), Where("id = 1")}, Cost{WhereTerms: 2, Limit: 10, Subqueries: 1, Total: 5}, false},
+		{"No fields", []Option{Limit(0)}, Cost{Subqueries: 1}, false},
+		{"Invalid where", []Option{Where("id = ")}, Cost{}, true},
+		{"Invalid option", []Option{Limit(-1)}, Cost{}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := EstimateCost(test.opts...)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got: <%v>, want error: <%v>", err, test.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got: <%+v>, want: <%+v>", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCostModelEstimateQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    Cost
+		wantErr bool
+	}{
+		{"Query", "fields name, cover.url; limit 5;", Cost{Fields: 2, Depth: 1, Limit: 5, Subqueries: 1, Total: 3.5}, false},
+		{"Multiquery", `query games "a" { fields name; limit 5; }; query covers "b" { fields *; where id = 1; };`, Cost{Fields: 2, Wildcards: 1, WhereTerms: 1, Limit: 15, Subqueries: 2, Total: 14.5}, false},
+		{"Repeated clause", "limit 5; limit 6;", Cost{}, true},
+		{"Unbalanced subquery", `query games "a" { fields name;`, Cost{}, true},
+		{"Invalid limit", "limit many;", Cost{}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := DefaultCostModel.EstimateQuery(test.query)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got: <%v>, want error: <%v>", err, test.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got: <%+v>, want: <%+v>", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCostModelEstimateMultiquery(t *testing.T) {
+	m := CostModel{Field: 2, Result: 1}
+	got, err := m.EstimateMultiquery(
+		Subquery{Endpoint: "games", Name: "a", Options: []Option{Fields("name", "slug"), Limit(3)}},
+		Subquery{Endpoint: "covers", Name: "b", Options: []Option{Fields("cover.url"), Limit(1)}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Cost{Fields: 3, Depth: 1, Limit: 4, Subqueries: 2, Total: 10}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: <%+v>, want: <%+v>", got, want)
+	}
+
+	if _, err := m.EstimateMultiquery(); err != ErrMissingInput {
+		t.Errorf("got: <%v>, want: <%v>", err, ErrMissingInput)
+	}
+}
+
+func TestBudgetCheck(t *testing.T) {
+	cost := Cost{Fields: 3, Depth: 2, Wildcards: 1, WhereTerms: 2, Limit: 50, Subqueries: 1, Total: 42}
+
+	tests := []struct {
+		name   string
+		budget Budget
+		want   []string
+	}{
+		{"Unlimited", Budget{}, nil},
+		{"Within budget", Budget{MaxTotal: 42, MaxDepth: 2, MaxLimit: 500}, nil},
+		{"Over budget", Budget{MaxTotal: 40, MaxDepth: 1, MaxWildcards: 1, MaxLimit: 10}, []string{"total 42 > 40", "depth 2 > 1", "limit 50 > 10"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.budget.Check(cost)
+			if test.want == nil {
+				if err != nil {
+					t.Errorf("got: <%v>, want: <%v>", err, nil)
+				}
+				return
+			}
+
+			berr, ok := err.(*BudgetError)
+			if !ok {
+				t.Fatalf("got: <%v>, want: <%v>", err, "*BudgetError")
+			}
+
+			if !reflect.DeepEqual(berr.Exceeded, test.want) {
+				t.Errorf("got: <%v>, want: <%v>", berr.Exceeded, test.want)
+			}
+
+			if berr.Cost != cost {
+				t.Errorf("got: <%v>, want: <%v>", berr.Cost, cost)
+			}
+		})
+	}
+}
+
+func TestWithBudget(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer ts.Close()
+
+	var warned []string
+	tests := []struct {
+		name      string
+		budget    Budget
+		enc       Encoding
+		opts      []Option
+		wantErr   bool
+		wantCalls int32
+		wantWarn  int
+	}{
+		{"Within budget", Budget{MaxLimit: 10}, BodyEncoding, []Option{Limit(10)}, false, 1, 0},
+		{"Rejected", Budget{MaxLimit: 10}, BodyEncoding, []Option{Limit(11)}, true, 0, 0},
+		{"Rejected in url", Budget{MaxWildcards: 1}, URLEncoding, []Option{Fields("*", "cover.*")}, true, 0, 0},
+		{"Warned", Budget{MaxLimit: 10, Warn: func(req *http.Request, err *BudgetError) { warned = append(warned, err.Error()) }}, BodyEncoding, []Option{Limit(11)}, false, 1, 1},
+		{"Default limit", Budget{MaxLimit: 5}, BodyEncoding, []Option{Fields("name")}, true, 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+			warned = nil
+
+			c, err := NewClient(WithBudget(test.budget), WithMethod("POST"), WithEncoding(test.enc))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := c.Send(context.Background(), "", ts.URL, test.opts...)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got: <%v>, want error: <%v>", err, test.wantErr)
+			}
+			if err == nil {
+				resp.Body.Close()
+			}
+
+			if _, ok := errors.Cause(err).(*BudgetError); test.wantErr && !ok {
+				t.Errorf("got: <%v>, want: <%v>", err, "*BudgetError")
+			}
+
+			if calls != test.wantCalls {
+				t.Errorf("got: <%v>, want: <%v>", calls, test.wantCalls)
+			}
+
+			if len(warned) != test.wantWarn {
+				t.Errorf("got: <%v>, want: <%v>", warned, test.wantWarn)
+			}
+		})
+	}
+
+	if _, err := NewClient(WithBudget(Budget{MaxLimit: -1})); errors.Cause(err) != ErrNegativeInput {
+		t.Errorf("got: <%v>, want: <%v>", err, ErrNegativeInput)
+	}
+}
+
+func TestWithBudgetCopiesModel(t *testing.T) {
+	defer func(m CostModel) { DefaultCostModel = m }(DefaultCostModel)
+
+	model := CostModel{Field: 5}
+	tests := []struct {
+		name   string
+		budget Budget
+		change func()
+		want   CostModel
+	}{
+		{"Default model", Budget{}, func() { DefaultCostModel.Field = 100 }, DefaultCostModel},
+		{"Provided model", Budget{Model: &model}, func() { model.Field = 100 }, model},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := NewClient(WithBudget(test.budget))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			test.change()
+
+			if got := *c.budget.Model; got != test.want {
+				t.Errorf("got: <%v>, want: <%v>", got, test.want)
+			}
+		})
+	}
+}
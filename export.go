@@ -0,0 +1,248 @@
+package apicalypse
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExportFormat is a format a request can be exported in.
+type ExportFormat int
+
+const (
+	// CurlFormat renders a request as a curl command that can be pasted into a
+	// POSIX shell.
+	CurlFormat ExportFormat = iota
+	// HTTPFormat renders a request as the raw HTTP/1.1 text sent on the wire.
+	HTTPFormat
+	// HARFormat renders a request as a HAR 1.2 entry, as used by browser developer
+	// tools and HTTP debugging proxies. The entry has no response.
+	HARFormat
+)
+
+// String returns the name of the format.
+func (f ExportFormat) String() string {
+	switch f {
+	case CurlFormat:
+		return "curl"
+	case HTTPFormat:
+		return "http"
+	case HARFormat:
+		return "har"
+	}
+	return "unknown"
+}
+
+// Export renders the provided request, such as one created by NewRequest, in the
+// provided format, so that exactly what would be sent can be inspected or replayed
+// with other tools. The values of the provided headers are replaced with
+// "REDACTED"; pass CredentialHeaders to redact the usual credentials. The request's
+// body is not consumed, so it must be empty or rewindable with GetBody.
+func Export(req *http.Request, format ExportFormat, redact ...string) (string, error) {
+	if req == nil {
+		return "", ErrMissingInput
+	}
+
+	body, ok := queryText(req)
+	if !ok {
+		return "", errors.New("cannot export request whose body cannot be rewound")
+	}
+
+	header := req.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	for _, k := range redact {
+		if _, ok := header[http.CanonicalHeaderKey(k)]; ok {
+			header.Set(k, redactedValue)
+		}
+	}
+
+	switch format {
+	case CurlFormat:
+		return exportCurl(req, header, body), nil
+	case HTTPFormat:
+		return exportHTTP(req, header, body)
+	case HARFormat:
+		return exportHAR(req, header, body)
+	}
+
+	return "", errors.Errorf("unknown export format %d", format)
+}
+
+// exportCurl renders a request as a curl command.
+func exportCurl(req *http.Request, header http.Header, body string) string {
+	args := []string{"curl"}
+	if req.Method != http.MethodGet || body != "" {
+		args = append(args, "-X "+req.Method)
+	}
+	args = append(args, shellQuote(req.URL.String()))
+
+	for _, k := range sortedKeys(header) {
+		for _, v := range header[k] {
+			args = append(args, "-H "+shellQuote(k+": "+v))
+		}
+	}
+
+	if body != "" {
+		args = append(args, "--data-raw "+shellQuote(body))
+	}
+
+	return strings.Join(args, " \\\n  ")
+}
+
+// shellQuote quotes s for a POSIX shell. Single quotes preserve everything but
+// single quotes themselves, which are written by closing the quotes, escaping the
+// single quote with a backslash, and opening the quotes again.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// exportHTTP renders a request as raw HTTP/1.1 text.
+func exportHTTP(req *http.Request, header http.Header, body string) (string, error) {
+	r := req.Clone(req.Context())
+	r.Header = header
+	r.Body = ioutil.NopCloser(strings.NewReader(body))
+	r.ContentLength = int64(len(body))
+	if body == "" {
+		r.Body = http.NoBody
+	}
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		return "", errors.Wrap(err, "cannot write request")
+	}
+
+	return buf.String(), nil
+}
+
+// harEntry is a HAR 1.2 entry.
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            int         `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+// harRequest is the request of a HAR entry.
+type harRequest struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []harPair   `json:"cookies"`
+	Headers     []harPair   `json:"headers"`
+	QueryString []harPair   `json:"queryString"`
+	PostData    *harPayload `json:"postData,omitempty"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+// harResponse is the response of a HAR entry. Exported entries have no response,
+// which HAR represents with a status of 0.
+type harResponse struct {
+	Status      int        `json:"status"`
+	StatusText  string     `json:"statusText"`
+	HTTPVersion string     `json:"httpVersion"`
+	Cookies     []harPair  `json:"cookies"`
+	Headers     []harPair  `json:"headers"`
+	Content     harContent `json:"content"`
+	RedirectURL string     `json:"redirectURL"`
+	HeadersSize int        `json:"headersSize"`
+	BodySize    int        `json:"bodySize"`
+}
+
+// harPair is a name and value, such as a header or url parameter.
+type harPair struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harPayload is the body of a HAR request.
+type harPayload struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// harContent is the body of a HAR response.
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+}
+
+// harTimings are the timings of a HAR entry.
+type harTimings struct {
+	Send    int `json:"send"`
+	Wait    int `json:"wait"`
+	Receive int `json:"receive"`
+}
+
+// exportHAR renders a request as a HAR entry.
+func exportHAR(req *http.Request, header http.Header, body string) (string, error) {
+	hr := harRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []harPair{},
+		Headers:     []harPair{},
+		QueryString: []harPair{},
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+
+	for _, k := range sortedKeys(header) {
+		for _, v := range header[k] {
+			hr.Headers = append(hr.Headers, harPair{Name: k, Value: v})
+		}
+	}
+
+	params := req.URL.Query()
+	for _, k := range sortedKeys(params) {
+		for _, v := range params[k] {
+			hr.QueryString = append(hr.QueryString, harPair{Name: k, Value: v})
+		}
+	}
+
+	if body != "" {
+		mime := header.Get("Content-Type")
+		if mime == "" {
+			mime = "text/plain"
+		}
+		hr.PostData = &harPayload{MimeType: mime, Text: body}
+	}
+
+	e := harEntry{
+		StartedDateTime: time.Now().UTC().Format(time.RFC3339Nano),
+		Request:         hr,
+		Response: harResponse{
+			Cookies:     []harPair{},
+			Headers:     []harPair{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+	}
+
+	b, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return "", errors.Wrap(err, "cannot encode HAR entry")
+	}
+
+	return string(b), nil
+}
+
+// sortedKeys returns the keys of the provided map in sorted order.
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
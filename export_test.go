@@ -0,0 +1,165 @@
+package apicalypse
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	req, err := NewRequest("POST", "https://api.com/games?key=abc", Fields("name"), Search("", "it's halo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Client-ID", "id")
+
+	get, err := NewRequest("GET", "https://api.com/games")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		req     *http.Request
+		format  ExportFormat
+		redact  []string
+		want    string
+		wantErr bool
+	}{
+		{
+			"Curl",
+			req,
+			CurlFormat,
+			[]string{"authorization"},
+			"curl \\\n" +
+				"  -X POST \\\n" +
+				"  'https://api.com/games?key=abc' \\\n" +
+				"  -H 'Authorization: REDACTED' \\\n" +
+				"  -H 'Client-Id: id' \\\n" +
+				`  --data-raw 'fields name; search "it'\''s halo"; '`,
+			false,
+		},
+		{"Curl without body", get, CurlFormat, nil, "curl \\\n  'https://api.com/games'", false},
+		{
+			"HTTP",
+			req,
+			HTTPFormat,
+			CredentialHeaders,
+			"POST /games?key=abc HTTP/1.1\r\n" +
+				"Host: api.com\r\n" +
+				"User-Agent: Go-http-client/1.1\r\n" +
+				"Content-Length: 33\r\n" +
+				"Authorization: REDACTED\r\n" +
+				"Client-Id: REDACTED\r\n" +
+				"\r\n" +
+				`fields name; search "it's halo"; `,
+			false,
+		},
+		{"Unknown format", req, ExportFormat(9), nil, "", true},
+		{"Nil request", nil, CurlFormat, nil, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Export(test.req, test.format, test.redact...)
+			if (err != nil) != test.wantErr {
+				t.Errorf("got: <%v>, want error: <%v>", err, test.wantErr)
+			}
+
+			if got != test.want {
+				t.Errorf("got: <%v>, want: <%v>", got, test.want)
+			}
+		})
+	}
+
+	if h := req.Header.Get("Authorization"); h != "Bearer secret" {
+		t.Errorf("got: <%v>, want: <%v>", h, "Bearer secret")
+	}
+
+	b, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `fields name; search "it's halo"; ` {
+		t.Errorf("got: <%v>, want: <%v>", string(b), `fields name; search "it's halo"; `)
+	}
+}
+
+func TestExportCurlShell(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no shell available")
+	}
+
+	body := `where name = "it's" & id = (1,2); search "$HOME \n";`
+	req, err := http.NewRequest("POST", "https://api.com/games", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd, err := Export(req, CurlFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replacing curl with a function that prints its last argument shows the
+	// body the shell would pass to curl.
+	script := "curl() { for a; do last=$a; done; printf '%s' \"$last\"; }\n" + cmd
+	out, err := exec.Command(sh, "-c", script).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(out) != body {
+		t.Errorf("got: <%v>, want: <%v>", string(out), body)
+	}
+}
+
+func TestExportHAR(t *testing.T) {
+	req, err := NewRequest("POST", "https://api.com/games?key=abc", Limit(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+
+	got, err := Export(req, HARFormat, CredentialHeaders...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entry struct {
+		Request struct {
+			Method      string
+			URL         string
+			Headers     []harPair
+			QueryString []harPair
+			PostData    harPayload
+			BodySize    int
+		}
+	}
+	if err := json.Unmarshal([]byte(got), &entry); err != nil {
+		t.Fatal(errors.Wrap(err, got))
+	}
+
+	r := entry.Request
+	if r.Method != "POST" || r.URL != "https://api.com/games?key=abc" || r.BodySize != 9 {
+		t.Errorf("got: <%v>, want: <%v>", r, "POST https://api.com/games?key=abc with 9 bytes")
+	}
+
+	if want := []harPair{{"Authorization", "REDACTED"}}; !reflect.DeepEqual(r.Headers, want) {
+		t.Errorf("got: <%v>, want: <%v>", r.Headers, want)
+	}
+
+	if want := []harPair{{"key", "abc"}}; !reflect.DeepEqual(r.QueryString, want) {
+		t.Errorf("got: <%v>, want: <%v>", r.QueryString, want)
+	}
+
+	if want := (harPayload{MimeType: "text/plain", Text: "limit 5; "}); r.PostData != want {
+		t.Errorf("got: <%v>, want: <%v>", r.PostData, want)
+	}
+}
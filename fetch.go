@@ -0,0 +1,138 @@
+package apicalypse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// maxErrorBody is the maximum number of bytes of a response body kept by a StatusError.
+const maxErrorBody = 4 << 10
+
+// StatusError occurs when an API responds with a status code outside of the 2xx range.
+type StatusError struct {
+	// StatusCode is the status code of the response.
+	StatusCode int
+	// Status is the status line of the response (e.g. "404 Not Found").
+	Status string
+	// URL is the url the request was sent to.
+	URL string
+	// Body holds up to the first 4KB of the response body.
+	Body []byte
+}
+
+// Error returns the status of the response along with its url and body.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status '%s' from url '%s': %s", e.Status, e.URL, e.Body)
+}
+
+// checkStatus returns a StatusError if the provided response does not have a
+// status code in the 2xx range.
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+
+	var url string
+	if resp.Request != nil {
+		url = resp.Request.URL.String()
+	}
+
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		URL:        url,
+		Body:       body,
+	}
+}
+
+// Fetch sends a query built from the provided options to the provided url using
+// the Client's method and decodes the returned JSON array into a slice of T.
+// If the API responds with a status code outside of the 2xx range, the returned
+// error is a *StatusError.
+func Fetch[T any](ctx context.Context, c *Client, url string, opts ...Option) ([]T, error) {
+	resp, err := c.Send(ctx, c.method, url, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []T
+	if err := decodeJSON(resp, &items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// decodeJSON decodes the JSON body of the provided response into v and closes the
+// body. If the response has a status code outside of the 2xx range, the returned
+// error is a *StatusError.
+func decodeJSON(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return errors.Wrap(err, "cannot decode response body")
+	}
+
+	return nil
+}
+
+// Stream sends a query built from the provided options to the provided url using
+// the Client's method and decodes the returned JSON array one element at a time,
+// calling fn with each decoded element. Unlike Fetch, Stream never holds the whole
+// response in memory. If fn returns an error, Stream stops and returns that error.
+// If the API responds with a status code outside of the 2xx range, the returned
+// error is a *StatusError.
+func Stream[T any](ctx context.Context, c *Client, url string, fn func(T) error, opts ...Option) error {
+	if fn == nil {
+		return ErrMissingInput
+	}
+
+	resp, err := c.Send(ctx, c.method, url, opts...)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	tok, err := dec.Token()
+	if err != nil {
+		return errors.Wrap(err, "cannot decode response body")
+	}
+	if tok == nil {
+		return nil
+	}
+	if tok != json.Delim('[') {
+		return errors.Errorf("cannot decode response body: expected array, found '%v'", tok)
+	}
+
+	for dec.More() {
+		var item T
+		if err := dec.Decode(&item); err != nil {
+			return errors.Wrap(err, "cannot decode response element")
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return errors.Wrap(err, "cannot decode response body")
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package apicalypse
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type testGame struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// fetchServer returns a server that responds to every request with the provided
+// status and body, reporting the method and query of each request to t.
+func fetchServer(t *testing.T, status int, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("got: <%v>, want: <%v>", r.Method, "POST")
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		if string(b) != "fields id,name; " {
+			t.Errorf("got: <%v>, want: <%v>", string(b), "fields id,name; ")
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+}
+
+func TestFetch(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantGames  []testGame
+		wantStatus int
+		wantError  bool
+	}{
+		{"Multiple items", 200, `[{"id":1,"name":"Halo"},{"id":2,"name":"Zelda"}]`, []testGame{{1, "Halo"}, {2, "Zelda"}}, 0, false},
+		{"Zero items", 200, `[]`, []testGame{}, 0, false},
+		{"Null body", 200, `null`, nil, 0, false},
+		{"Invalid body", 200, `{"id":1}`, nil, 0, true},
+		{"Unsuccessful status", 401, `{"message":"unauthorized"}`, nil, 401, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts := fetchServer(t, test.status, test.body)
+			defer ts.Close()
+
+			c, err := NewClient(WithMethod("POST"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			games, err := Fetch[testGame](context.Background(), c, ts.URL, Fields("id", "name"))
+			if (err != nil) != test.wantError {
+				t.Fatalf("got: <%v>, want error: <%v>", err, test.wantError)
+			}
+
+			if !reflect.DeepEqual(games, test.wantGames) {
+				t.Errorf("got: <%v>, want: <%v>", games, test.wantGames)
+			}
+
+			if test.wantStatus == 0 {
+				return
+			}
+
+			serr, ok := errors.Cause(err).(*StatusError)
+			if !ok {
+				t.Fatalf("got: <%T>, want: <%T>", errors.Cause(err), serr)
+			}
+
+			if serr.StatusCode != test.wantStatus || string(serr.Body) != test.body {
+				t.Errorf("got: <%v>, want: <%v>", serr, test.wantStatus)
+			}
+		})
+	}
+}
+
+func TestStream(t *testing.T) {
+	stop := errors.New("stop")
+
+	tests := []struct {
+		name      string
+		body      string
+		stopAfter int
+		wantGames []testGame
+		wantErr   error
+		wantError bool
+	}{
+		{"Multiple items", `[{"id":1,"name":"Halo"},{"id":2,"name":"Zelda"}]`, 0, []testGame{{1, "Halo"}, {2, "Zelda"}}, nil, false},
+		{"Zero items", `[]`, 0, nil, nil, false},
+		{"Null body", `null`, 0, nil, nil, false},
+		{"Non-array body", `{"id":1}`, 0, nil, nil, true},
+		{"Truncated body", `[{"id":1,"name":"Halo"},{"id":2`, 0, []testGame{{1, "Halo"}}, nil, true},
+		{"Stopped early", `[{"id":1,"name":"Halo"},{"id":2,"name":"Zelda"}]`, 1, []testGame{{1, "Halo"}}, stop, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts := fetchServer(t, 200, test.body)
+			defer ts.Close()
+
+			c, err := NewClient(WithMethod("POST"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var games []testGame
+			err = Stream(context.Background(), c, ts.URL, func(g testGame) error {
+				games = append(games, g)
+				if len(games) == test.stopAfter {
+					return stop
+				}
+				return nil
+			}, Fields("id", "name"))
+			if (err != nil) != test.wantError {
+				t.Fatalf("got: <%v>, want error: <%v>", err, test.wantError)
+			}
+
+			if test.wantErr != nil && err != test.wantErr {
+				t.Errorf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+
+			if !reflect.DeepEqual(games, test.wantGames) {
+				t.Errorf("got: <%v>, want: <%v>", games, test.wantGames)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package apicalypse
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+)
+
+// Field is a typed path to a field of an endpoint's results, such as "name" or
+// "cover.url". The typed fields are primarily used by code generated with the
+// apicalypse-gen command so that misspelled field names are caught by the compiler
+// rather than silently returning empty results.
+type Field interface {
+	// Path returns the field's path.
+	Path() string
+}
+
+// Select is a functional option for setting the included fields in the results
+// from a query using typed fields. It is equivalent to Fields.
+func Select(fields ...Field) Option {
+	return Fields(paths(fields)...)
+}
+
+// Omit is a functional option for setting the excluded fields in the results from
+// a query using typed fields. It is equivalent to Exclude.
+func Omit(fields ...Field) Option {
+	return Exclude(paths(fields)...)
+}
+
+// paths returns the paths of the provided fields.
+func paths(fields []Field) []string {
+	p := make([]string, len(fields))
+	for i, f := range fields {
+		p[i] = f.Path()
+	}
+	return p
+}
+
+// IntField is a field holding integers, including references to other endpoints
+// when they are not expanded.
+type IntField string
+
+// Path returns the field's path.
+func (f IntField) Path() string { return string(f) }
+
+// Eq filters the results to those whose field equals n.
+func (f IntField) Eq(n int) Option { return compare(f, "=", strconv.Itoa(n)) }
+
+// Ne filters the results to those whose field does not equal n.
+func (f IntField) Ne(n int) Option { return compare(f, "!=", strconv.Itoa(n)) }
+
+// Gt filters the results to those whose field is greater than n.
+func (f IntField) Gt(n int) Option { return compare(f, ">", strconv.Itoa(n)) }
+
+// Gte filters the results to those whose field is greater than or equal to n.
+func (f IntField) Gte(n int) Option { return compare(f, ">=", strconv.Itoa(n)) }
+
+// Lt filters the results to those whose field is less than n.
+func (f IntField) Lt(n int) Option { return compare(f, "<", strconv.Itoa(n)) }
+
+// Lte filters the results to those whose field is less than or equal to n.
+func (f IntField) Lte(n int) Option { return compare(f, "<=", strconv.Itoa(n)) }
+
+// In filters the results to those whose field equals any of the provided values.
+func (f IntField) In(ns ...int) Option {
+	vals := make([]string, len(ns))
+	for i, n := range ns {
+		vals[i] = strconv.Itoa(n)
+	}
+	return in(f, vals)
+}
+
+// IsNull filters the results to those whose field is null.
+func (f IntField) IsNull() Option { return compare(f, "=", "null") }
+
+// NotNull filters the results to those whose field is not null.
+func (f IntField) NotNull() Option { return compare(f, "!=", "null") }
+
+// Asc sorts the results by the field in ascending order.
+func (f IntField) Asc() Option { return Sort(string(f), Asc) }
+
+// Desc sorts the results by the field in descending order.
+func (f IntField) Desc() Option { return Sort(string(f), Desc) }
+
+// FloatField is a field holding floating point numbers.
+type FloatField string
+
+// Path returns the field's path.
+func (f FloatField) Path() string { return string(f) }
+
+// Eq filters the results to those whose field equals v.
+func (f FloatField) Eq(v float64) Option { return compare(f, "=", formatFloat(v)) }
+
+// Ne filters the results to those whose field does not equal v.
+func (f FloatField) Ne(v float64) Option { return compare(f, "!=", formatFloat(v)) }
+
+// Gt filters the results to those whose field is greater than v.
+func (f FloatField) Gt(v float64) Option { return compare(f, ">", formatFloat(v)) }
+
+// Gte filters the results to those whose field is greater than or equal to v.
+func (f FloatField) Gte(v float64) Option { return compare(f, ">=", formatFloat(v)) }
+
+// Lt filters the results to those whose field is less than v.
+func (f FloatField) Lt(v float64) Option { return compare(f, "<", formatFloat(v)) }
+
+// Lte filters the results to those whose field is less than or equal to v.
+func (f FloatField) Lte(v float64) Option { return compare(f, "<=", formatFloat(v)) }
+
+// IsNull filters the results to those whose field is null.
+func (f FloatField) IsNull() Option { return compare(f, "=", "null") }
+
+// NotNull filters the results to those whose field is not null.
+func (f FloatField) NotNull() Option { return compare(f, "!=", "null") }
+
+// Asc sorts the results by the field in ascending order.
+func (f FloatField) Asc() Option { return Sort(string(f), Asc) }
+
+// Desc sorts the results by the field in descending order.
+func (f FloatField) Desc() Option { return Sort(string(f), Desc) }
+
+// StringField is a field holding strings.
+type StringField string
+
+// Path returns the field's path.
+func (f StringField) Path() string { return string(f) }
+
+// Eq filters the results to those whose field equals s.
+func (f StringField) Eq(s string) Option { return compare(f, "=", quote(s)) }
+
+// Ne filters the results to those whose field does not equal s.
+func (f StringField) Ne(s string) Option { return compare(f, "!=", quote(s)) }
+
+// Prefix filters the results to those whose field begins with s.
+func (f StringField) Prefix(s string) Option { return compare(f, "=", quote(s)+"*") }
+
+// Suffix filters the results to those whose field ends with s.
+func (f StringField) Suffix(s string) Option { return compare(f, "=", "*"+quote(s)) }
+
+// Contains filters the results to those whose field contains s.
+func (f StringField) Contains(s string) Option { return compare(f, "=", "*"+quote(s)+"*") }
+
+// In filters the results to those whose field equals any of the provided values.
+func (f StringField) In(ss ...string) Option {
+	vals := make([]string, len(ss))
+	for i, s := range ss {
+		vals[i] = quote(s)
+	}
+	return in(f, vals)
+}
+
+// IsNull filters the results to those whose field is null.
+func (f StringField) IsNull() Option { return compare(f, "=", "null") }
+
+// NotNull filters the results to those whose field is not null.
+func (f StringField) NotNull() Option { return compare(f, "!=", "null") }
+
+// Asc sorts the results by the field in ascending order.
+func (f StringField) Asc() Option { return Sort(string(f), Asc) }
+
+// Desc sorts the results by the field in descending order.
+func (f StringField) Desc() Option { return Sort(string(f), Desc) }
+
+// BoolField is a field holding booleans.
+type BoolField string
+
+// Path returns the field's path.
+func (f BoolField) Path() string { return string(f) }
+
+// Eq filters the results to those whose field equals b.
+func (f BoolField) Eq(b bool) Option { return compare(f, "=", strconv.FormatBool(b)) }
+
+// IsNull filters the results to those whose field is null.
+func (f BoolField) IsNull() Option { return compare(f, "=", "null") }
+
+// NotNull filters the results to those whose field is not null.
+func (f BoolField) NotNull() Option { return compare(f, "!=", "null") }
+
+// compare returns a Where option comparing the field to the provided value.
+func compare(f Field, op, value string) Option {
+	return Where(f.Path() + " " + op + " " + value)
+}
+
+// in returns a Where option matching the field against any of the provided values.
+func in(f Field, vals []string) Option {
+	if len(vals) == 0 {
+		return func(map[string]string) error {
+			return ErrMissingInput
+		}
+	}
+	return compare(f, "=", "("+strings.Join(vals, ",")+")")
+}
+
+// quoteReplacer escapes the characters that cannot appear unescaped in a quoted string.
+var quoteReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// quote returns s as a quoted string.
+func quote(s string) string {
+	return `"` + quoteReplacer.Replace(s) + `"`
+}
+
+// formatFloat returns the shortest representation of v.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// Ref is a reference to an item of another endpoint. APIs such as IGDB return a
+// reference as the referenced item's ID unless the field is expanded, in which case
+// the whole item is returned. A Ref decodes either form: ID is always set and Value
+// is only set when the reference was expanded.
+type Ref[T any] struct {
+	ID    int
+	Value *T
+}
+
+// UnmarshalJSON decodes either an ID or an expanded item.
+func (r *Ref[T]) UnmarshalJSON(b []byte) error {
+	var id int
+	if err := json.Unmarshal(b, &id); err == nil {
+		r.ID, r.Value = id, nil
+		return nil
+	}
+
+	var v T
+	if err := json.Unmarshal(b, &v); err != nil {
+		return errors.Wrap(err, "cannot decode reference")
+	}
+
+	var item struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(b, &item); err != nil {
+		return errors.Wrap(err, "cannot decode reference ID")
+	}
+
+	r.ID, r.Value = item.ID, &v
+	return nil
+}
+
+// MarshalJSON encodes the expanded item if it is set and the ID otherwise.
+func (r Ref[T]) MarshalJSON() ([]byte, error) {
+	if r.Value != nil {
+		return json.Marshal(r.Value)
+	}
+	return json.Marshal(r.ID)
+}
@@ -0,0 +1,110 @@
+package apicalypse
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"reflect"
+	"testing"
+)
+
+func TestFieldOptions(t *testing.T) {
+	const (
+		rating IntField    = "rating"
+		score  FloatField  = "aggregated_rating"
+		name   StringField = "name"
+		free   BoolField   = "free"
+		url    StringField = "cover.url"
+	)
+
+	tests := []struct {
+		name    string
+		opt     Option
+		want    string
+		wantErr error
+	}{
+		{"Int equal", rating.Eq(80), "where rating = 80; ", nil},
+		{"Int not equal", rating.Ne(80), "where rating != 80; ", nil},
+		{"Int greater than", rating.Gt(80), "where rating > 80; ", nil},
+		{"Int greater than or equal", rating.Gte(80), "where rating >= 80; ", nil},
+		{"Int less than", rating.Lt(-1), "where rating < -1; ", nil},
+		{"Int less than or equal", rating.Lte(80), "where rating <= 80; ", nil},
+		{"Int in", rating.In(1, 2, 3), "where rating = (1,2,3); ", nil},
+		{"Int in without values", rating.In(), "", ErrMissingInput},
+		{"Int null", rating.IsNull(), "where rating = null; ", nil},
+		{"Int not null", rating.NotNull(), "where rating != null; ", nil},
+		{"Int ascending", rating.Asc(), "sort rating asc; ", nil},
+		{"Int descending", rating.Desc(), "sort rating desc; ", nil},
+		{"Float greater than", score.Gt(72.5), "where aggregated_rating > 72.5; ", nil},
+		{"Float whole number", score.Lte(70), "where aggregated_rating <= 70; ", nil},
+		{"Float descending", score.Desc(), "sort aggregated_rating desc; ", nil},
+		{"String equal", name.Eq("Halo"), `where name = "Halo"; `, nil},
+		{"String escaped", name.Ne(`The "Best" \ Game`), `where name != "The \"Best\" \\ Game"; `, nil},
+		{"String prefix", name.Prefix("Hal"), `where name = "Hal"*; `, nil},
+		{"String suffix", name.Suffix("alo"), `where name = *"alo"; `, nil},
+		{"String contains", name.Contains("al"), `where name = *"al"*; `, nil},
+		{"String in", name.In("a", "b"), `where name = ("a","b"); `, nil},
+		{"Nested string", url.NotNull(), `where cover.url != null; `, nil},
+		{"Bool equal", free.Eq(true), "where free = true; ", nil},
+		{"Bool null", free.IsNull(), "where free = null; ", nil},
+		{"Select", Select(name, rating, url), "fields name,rating,cover.url; ", nil},
+		{"Select without fields", Select(), "", ErrMissingInput},
+		{"Omit", Omit(score, free), "exclude aggregated_rating,free; ", nil},
+		{"Combined predicates", ComposeOptions(rating.Gt(80), free.Eq(true)), "where free = true & rating > 80; ", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Query(test.opt)
+			if errors.Cause(err) != test.wantErr {
+				t.Fatalf("got: <%v>, want: <%v>", errors.Cause(err), test.wantErr)
+			}
+
+			if got != test.want {
+				t.Errorf("got: <%v>, want: <%v>", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRef(t *testing.T) {
+	type cover struct {
+		ID  int    `json:"id"`
+		URL string `json:"url"`
+	}
+
+	tests := []struct {
+		name     string
+		json     string
+		want     Ref[cover]
+		wantJSON string
+		wantErr  bool
+	}{
+		{"ID", `5`, Ref[cover]{ID: 5}, `5`, false},
+		{"Expanded", `{"id":5,"url":"x"}`, Ref[cover]{ID: 5, Value: &cover{ID: 5, URL: "x"}}, `{"id":5,"url":"x"}`, false},
+		{"Invalid", `"five"`, Ref[cover]{}, ``, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got Ref[cover]
+			err := json.Unmarshal([]byte(test.json), &got)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got: <%v>, want error: <%v>", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got: <%v>, want: <%v>", got, test.want)
+			}
+
+			b, err := json.Marshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != test.wantJSON {
+				t.Errorf("got: <%v>, want: <%v>", string(b), test.wantJSON)
+			}
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package apicalypse
 
 import (
+	"github.com/Henry-Sarabia/blank"
 	"github.com/pkg/errors"
+	"sort"
 	"strings"
+	"unicode"
 )
 
 // newFilters returns a filter map mutated by the provided Option arguments.
@@ -19,16 +22,172 @@ func newFilters(funcOpts ...Option) (map[string]string, error) {
 	return filters, nil
 }
 
-// toString returns the filters as a single string.
+// clauseOrder is the canonical order in which clauses are written to a query.
+// Clauses not listed here are written afterwards in alphabetical order.
+var clauseOrder = []string{"fields", "exclude", "search", "where", "sort", "limit", "offset"}
+
+// toString returns the filters as a single string. The filters are always written
+// in the same order so that equal filter maps produce equal strings.
 func toString(f map[string]string) string {
 	if len(f) <= 0 {
 		return ""
 	}
 
 	b := strings.Builder{}
-	for k, v := range f {
-		b.WriteString(k + " " + v + "; ")
+	for _, k := range clauseKeys(f) {
+		b.WriteString(k + " " + f[k] + "; ")
+	}
+
+	return b.String()
+}
+
+// clauseKeys returns the keys of the provided filters in canonical order.
+func clauseKeys(f map[string]string) []string {
+	keys := make([]string, 0, len(f))
+	for _, k := range clauseOrder {
+		if _, ok := f[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+
+	var rest []string
+	for k := range f {
+		if clauseIndex(k) < 0 {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+
+	return append(keys, rest...)
+}
+
+// clauseIndex returns the position of the provided clause in the canonical order
+// or -1 if it has no fixed position.
+func clauseIndex(clause string) int {
+	for i, c := range clauseOrder {
+		if c == clause {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseFilters parses a query string into a filter map. Clauses are separated by
+// semicolons; semicolons inside quotes, parentheses, brackets, or braces do not
+// end a clause.
+func parseFilters(q string) (map[string]string, error) {
+	filters := map[string]string{}
+
+	for _, c := range splitClauses(q) {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+
+		i := strings.IndexAny(c, " \t\r\n")
+		if i < 0 {
+			return nil, errors.Errorf("clause '%s' is missing a value", c)
+		}
+
+		k := c[:i]
+		if _, ok := filters[k]; ok {
+			return nil, errors.Errorf("clause '%s' is repeated", k)
+		}
+		filters[k] = collapseSpace(strings.TrimSpace(c[i:]))
+	}
+
+	return filters, nil
+}
+
+// splitClauses splits a query string on the semicolons that end its clauses.
+func splitClauses(q string) []string {
+	var clauses []string
+	for _, s := range scanQuery(q, 0).spans {
+		clauses = append(clauses, q[s.start:s.end])
+	}
+
+	return clauses
+}
+
+// collapseSpace replaces each run of whitespace outside of quotes with a single space.
+func collapseSpace(s string) string {
+	b := strings.Builder{}
+	quoted, escaped, space := false, false, false
+
+	for _, r := range s {
+		if !quoted && unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteRune(' ')
+			space = false
+		}
+		b.WriteRune(r)
+
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		}
 	}
 
 	return b.String()
 }
+
+// canonicalize returns the canonical form of a query string. Clauses are ordered,
+// whitespace is collapsed, and the field lists of the fields and exclude clauses
+// are sorted and deduplicated. Queries that cannot be parsed only have their
+// whitespace collapsed.
+func canonicalize(q string) string {
+	filters, err := parseFilters(q)
+	if err != nil {
+		return collapseSpace(strings.TrimSpace(q))
+	}
+
+	return toString(canonicalFilters(filters))
+}
+
+// canonicalFilters sorts and deduplicates the field lists of the fields and
+// exclude clauses of the provided filters, writes the order of every sort key,
+// and returns the filters.
+func canonicalFilters(filters map[string]string) map[string]string {
+	for _, k := range []string{"fields", "exclude"} {
+		if v, ok := filters[k]; ok {
+			filters[k] = canonicalFields(v)
+		}
+	}
+
+	if v, ok := filters["sort"]; ok {
+		if keys, err := ParseSort(v); err == nil {
+			s := make([]string, len(keys))
+			for i, k := range keys {
+				s[i] = k.String()
+			}
+			filters["sort"] = strings.Join(s, ", ")
+		}
+	}
+
+	return filters
+}
+
+// canonicalFields sorts and deduplicates a comma separated list of fields.
+func canonicalFields(list string) string {
+	seen := map[string]bool{}
+	var fields []string
+
+	for _, f := range strings.Split(list, ",") {
+		f = blank.Remove(f)
+		if f == "" || seen[f] {
+			continue
+		}
+		seen[f] = true
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	return strings.Join(fields, ",")
+}
@@ -44,6 +44,7 @@ func TestToString(t *testing.T) {
 		{"Zero filters", map[string]string{}, nil},
 		{"Single filter", map[string]string{"limit": "15"}, []string{"limit 15; "}},
 		{"Multiple filters", map[string]string{"limit": "15", "fields": "id,name,rating"}, []string{"limit 15; ", "fields id,name,rating; "}},
+		{"Canonical order", map[string]string{"limit": "15", "offset": "5", "where": "a = 1", "fields": "id", "custom": "x"}, []string{"fields id; where a = 1; limit 15; offset 5; custom x; "}},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -57,3 +58,56 @@ func TestToString(t *testing.T) {
 		})
 	}
 }
+
+func TestParseFilters(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		wantFilters map[string]string
+		wantErr     bool
+	}{
+		{"Empty query", "", map[string]string{}, false},
+		{"Single clause", "limit 15;", map[string]string{"limit": "15"}, false},
+		{"Missing final semicolon", "limit 15; offset 5", map[string]string{"limit": "15", "offset": "5"}, false},
+		{"Extra whitespace", "  fields  id,   name ;\n\twhere a   =  1;", map[string]string{"fields": "id, name", "where": "a = 1"}, false},
+		{"Quoted semicolon", `search "a;  b"; limit 1;`, map[string]string{"search": `"a;  b"`, "limit": "1"}, false},
+		{"Escaped quote", `search "a\";b"; limit 1;`, map[string]string{"search": `"a\";b"`, "limit": "1"}, false},
+		{"Nested braces", `query games "x" { fields id; limit 1; };`, map[string]string{"query": `games "x" { fields id; limit 1; }`}, false},
+		{"Missing value", "limit;", nil, true},
+		{"Repeated clause", "limit 1; limit 2;", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filters, err := parseFilters(test.query)
+			if (err != nil) != test.wantErr {
+				t.Errorf("got: <%v>, want error: <%v>", err, test.wantErr)
+			}
+
+			if !reflect.DeepEqual(filters, test.wantFilters) {
+				t.Errorf("got: <%v>, want: <%v>", filters, test.wantFilters)
+			}
+		})
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"Empty query", "", ""},
+		{"Ordered clauses", "limit 5; fields name;", "fields name; limit 5; "},
+		{"Sorted fields", "fields rating, name,id;", "fields id,name,rating; "},
+		{"Duplicate fields", "exclude name,name,id;", "exclude id,name; "},
+		{"Invalid query", "  limit;   offset 5 ", "limit; offset 5"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := canonicalize(test.query)
+			if got != test.want {
+				t.Errorf("got: <%v>, want: <%v>", got, test.want)
+			}
+		})
+	}
+}
@@ -1,8 +1,13 @@
 module github.com/Henry-Sarabia/apicalypse
 
-go 1.13
+go 1.23
 
 require (
 	github.com/Henry-Sarabia/blank v3.0.0+incompatible
 	github.com/pkg/errors v0.9.1
+	golang.org/x/term v0.27.0
+	google.golang.org/protobuf v1.36.9
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/sys v0.28.0 // indirect
@@ -0,0 +1,347 @@
+package apicalypse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"sort"
+	"strings"
+)
+
+// valueKind is a kind of JSON value seen in sample results.
+type valueKind int
+
+const (
+	kindInt valueKind = 1 << iota
+	kindFloat
+	kindString
+	kindBool
+	kindObject
+	kindArray
+)
+
+// kindNames are the names of the kinds of JSON values.
+var kindNames = []struct {
+	kind valueKind
+	name string
+}{
+	{kindInt, "int"},
+	{kindFloat, "float"},
+	{kindString, "string"},
+	{kindBool, "bool"},
+	{kindObject, "object"},
+	{kindArray, "array"},
+}
+
+// String returns the names of the kinds in the set.
+func (k valueKind) String() string {
+	var names []string
+	for _, kn := range kindNames {
+		if k&kn.kind != 0 {
+			names = append(names, kn.name)
+		}
+	}
+	return strings.Join(names, " and ")
+}
+
+// Inference infers a Schema from sample results, such as responses recorded as
+// test fixtures, when no schema is published for an API. Add any number of samples
+// for each endpoint; the inferred schema describes every field seen in any of the
+// samples. An Inference is not safe for concurrent use.
+type Inference struct {
+	objects map[string]*objectSample
+}
+
+// NewInference returns an Inference without any samples.
+func NewInference() *Inference {
+	return &Inference{objects: map[string]*objectSample{}}
+}
+
+// Add adds a sample of results from the provided endpoint. The sample is a JSON
+// array of result objects, as returned by the endpoint, or a single result object.
+func (in *Inference) Add(endpoint string, data []byte) error {
+	if strings.TrimSpace(endpoint) == "" {
+		return ErrBlankArgument
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	v, err := decodeOrdered(dec)
+	if err != nil {
+		return errors.Wrapf(err, "cannot decode sample of endpoint '%s'", endpoint)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.Errorf("cannot decode sample of endpoint '%s': unexpected data after the sample", endpoint)
+	}
+
+	var items []interface{}
+	switch v := v.(type) {
+	case []interface{}:
+		items = v
+	case *orderedObject:
+		items = []interface{}{v}
+	default:
+		return errors.Errorf("sample of endpoint '%s' is not an object or an array of objects", endpoint)
+	}
+
+	for i, item := range items {
+		if _, ok := item.(*orderedObject); !ok {
+			return errors.Errorf("sample of endpoint '%s': item %d is not an object", endpoint, i)
+		}
+	}
+
+	obj, ok := in.objects[endpoint]
+	if !ok {
+		obj = newObjectSample()
+		in.objects[endpoint] = obj
+	}
+	for _, item := range items {
+		obj.add(item.(*orderedObject))
+	}
+
+	return nil
+}
+
+// Schema returns the schema inferred from the samples added so far.
+//
+// Each field's type is the type of its values, with integers and floats merged
+// into floats. A field holding objects is a reference to an endpoint named after
+// the field, such as "games_cover", whose fields are inferred from those objects.
+// Since APIs such as IGDB return the ID of a referenced item unless the field is
+// expanded, a field holding both integers and objects is also a reference. Arrays
+// are inferred from their elements. A field is nullable if it was null or missing
+// from any result. Scalar fields that are not arrays are sortable.
+//
+// Fields whose type cannot be inferred, such as those that were always null or
+// that hold values of conflicting types, are left out of the schema. Use Skipped
+// to list them.
+func (in *Inference) Schema() (*Schema, error) {
+	endpoints, _ := in.build()
+
+	s := NewSchema()
+	if err := s.Register(endpoints...); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Skipped describes each field left out of the inferred schema and why, such as
+// "games.status: only null values" or "games.tag: conflicting types int and string".
+func (in *Inference) Skipped() []string {
+	_, skipped := in.build()
+	return skipped
+}
+
+// build returns the inferred endpoints and the fields that were left out.
+func (in *Inference) build() ([]EndpointSchema, []string) {
+	names := make([]string, 0, len(in.objects))
+	for n := range in.objects {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	b := &inferBuilder{}
+	for _, n := range names {
+		b.endpoint(n, in.objects[n])
+	}
+
+	return b.endpoints, b.skipped
+}
+
+// inferBuilder builds endpoints from object samples.
+type inferBuilder struct {
+	endpoints []EndpointSchema
+	skipped   []string
+}
+
+// endpoint adds the endpoint inferred from the provided object sample.
+func (b *inferBuilder) endpoint(name string, obj *objectSample) {
+	e := EndpointSchema{Name: name}
+	for _, key := range obj.order {
+		v := obj.fields[key]
+
+		f, reason := b.field(name, key, v)
+		if reason != "" {
+			b.skipped = append(b.skipped, fmt.Sprintf("%s.%s: %s", name, key, reason))
+			continue
+		}
+		f.Nullable = v.nulls > 0 || v.count < obj.count
+		e.Fields = append(e.Fields, f)
+	}
+
+	b.endpoints = append(b.endpoints, e)
+}
+
+// field returns the field inferred from the provided value sample of an endpoint
+// or the reason it cannot be inferred.
+func (b *inferBuilder) field(endpoint, name string, v *valueSample) (FieldSchema, string) {
+	if v.kinds&kindArray == 0 {
+		f, reason := b.scalar(endpoint, name, v)
+		f.Sortable = reason == "" && f.Ref == ""
+		return f, reason
+	}
+
+	switch {
+	case v.kinds != kindArray:
+		return FieldSchema{}, "conflicting types " + v.kinds.String()
+	case v.elems == nil || v.elems.kinds == 0:
+		return FieldSchema{}, "only empty arrays"
+	case v.elems.kinds&kindArray != 0:
+		return FieldSchema{}, "nested arrays"
+	}
+
+	f, reason := b.scalar(endpoint, name, v.elems)
+	f.Array = true
+	return f, reason
+}
+
+// scalar returns the field inferred from a value sample that holds no arrays or
+// the reason it cannot be inferred.
+func (b *inferBuilder) scalar(endpoint, name string, v *valueSample) (FieldSchema, string) {
+	f := FieldSchema{Name: name}
+
+	switch v.kinds {
+	case 0:
+		return f, "only null values"
+	case kindInt:
+		f.Type = TypeInt
+	case kindFloat, kindInt | kindFloat:
+		f.Type = TypeFloat
+	case kindString:
+		f.Type = TypeString
+	case kindBool:
+		f.Type = TypeBool
+	case kindObject, kindInt | kindObject:
+		f.Ref = endpoint + "_" + name
+		b.endpoint(f.Ref, v.object)
+	default:
+		return f, "conflicting types " + v.kinds.String()
+	}
+
+	return f, ""
+}
+
+// objectSample is the merged structure of sampled objects.
+type objectSample struct {
+	count  int
+	fields map[string]*valueSample
+	order  []string
+}
+
+// newObjectSample returns an objectSample without any samples.
+func newObjectSample() *objectSample {
+	return &objectSample{fields: map[string]*valueSample{}}
+}
+
+// add merges the provided object into the sample.
+func (o *objectSample) add(obj *orderedObject) {
+	o.count++
+	for i, key := range obj.keys {
+		v, ok := o.fields[key]
+		if !ok {
+			v = &valueSample{}
+			o.fields[key] = v
+			o.order = append(o.order, key)
+		}
+		v.count++
+		v.add(obj.values[i])
+	}
+}
+
+// valueSample is the merged structure of the sampled values of a field or of the
+// elements of an array.
+type valueSample struct {
+	count  int
+	nulls  int
+	kinds  valueKind
+	object *objectSample
+	elems  *valueSample
+}
+
+// add merges the provided value into the sample.
+func (v *valueSample) add(val interface{}) {
+	switch val := val.(type) {
+	case nil:
+		v.nulls++
+	case json.Number:
+		if strings.ContainsAny(val.String(), ".eE") {
+			v.kinds |= kindFloat
+		} else {
+			v.kinds |= kindInt
+		}
+	case string:
+		v.kinds |= kindString
+	case bool:
+		v.kinds |= kindBool
+	case *orderedObject:
+		v.kinds |= kindObject
+		if v.object == nil {
+			v.object = newObjectSample()
+		}
+		v.object.add(val)
+	case []interface{}:
+		v.kinds |= kindArray
+		if v.elems == nil {
+			v.elems = &valueSample{}
+		}
+		for _, e := range val {
+			v.elems.count++
+			v.elems.add(e)
+		}
+	}
+}
+
+// orderedObject is a JSON object whose keys keep the order they were written in.
+type orderedObject struct {
+	keys   []string
+	values []interface{}
+}
+
+// decodeOrdered decodes the next JSON value from the provided decoder. Objects are
+// decoded as *orderedObject, arrays as []interface{}, and numbers as json.Number.
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		obj := &orderedObject{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			val, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.keys = append(obj.keys, key.(string))
+			obj.values = append(obj.values, val)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	case json.Delim('['):
+		arr := []interface{}{}
+		for dec.More() {
+			val, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, val)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return arr, nil
+	}
+
+	return tok, nil
+}
@@ -0,0 +1,123 @@
+package apicalypse
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInference(t *testing.T) {
+	in := NewInference()
+
+	samples := []struct {
+		endpoint string
+		data     string
+	}{
+		{"games", `[
+			{"id": 1, "name": "Halo", "rating": 90, "cover": 7, "genres": [5, 12], "status": null, "tags": []},
+			{"id": 2, "name": "Myst", "rating": 81.5, "cover": {"id": 8, "url": "//img/8.jpg"}, "genres": [{"id": 5, "name": "Puzzle"}], "status": null, "free": true}
+		]`},
+		{"games", `{"id": 3, "name": "Doom", "cover": null, "mixed": "x", "alt": [["a"]]}`},
+		{"games", `[{"id": 4, "name": "Quake", "mixed": 4, "keywords": ["fps", "id"], "release": {"date": 1996, "region": 8}}]`},
+		{"platforms", `[{"id": 6, "name": "PC"}]`},
+	}
+	for _, s := range samples {
+		if err := in.Add(s.endpoint, []byte(s.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := in.Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]FieldSchema{
+		"games": {
+			{Name: "id", Type: TypeInt, Sortable: true},
+			{Name: "name", Type: TypeString, Sortable: true},
+			{Name: "rating", Type: TypeFloat, Nullable: true, Sortable: true},
+			{Name: "cover", Type: TypeInt, Ref: "games_cover", Nullable: true},
+			{Name: "genres", Type: TypeInt, Ref: "games_genres", Array: true, Nullable: true},
+			{Name: "free", Type: TypeBool, Nullable: true, Sortable: true},
+			{Name: "keywords", Type: TypeString, Array: true, Nullable: true},
+			{Name: "release", Type: TypeInt, Ref: "games_release", Nullable: true},
+		},
+		"games_cover": {
+			{Name: "id", Type: TypeInt, Sortable: true},
+			{Name: "url", Type: TypeString, Sortable: true},
+		},
+		"games_genres": {
+			{Name: "id", Type: TypeInt, Sortable: true},
+			{Name: "name", Type: TypeString, Sortable: true},
+		},
+		"games_release": {
+			{Name: "date", Type: TypeInt, Sortable: true},
+			{Name: "region", Type: TypeInt, Sortable: true},
+		},
+		"platforms": {
+			{Name: "id", Type: TypeInt, Sortable: true},
+			{Name: "name", Type: TypeString, Sortable: true},
+		},
+	}
+
+	if got := s.Endpoints(); len(got) != len(want) {
+		t.Fatalf("got: <%v>, want: <%v> endpoints", got, len(want))
+	}
+	for name, fields := range want {
+		e, ok := s.Endpoint(name)
+		if !ok {
+			t.Errorf("got: <%v>, want: <%v>", s.Endpoints(), name)
+			continue
+		}
+
+		if !reflect.DeepEqual(e.Fields, fields) {
+			t.Errorf("got: <%v>, want: <%v>", e.Fields, fields)
+		}
+	}
+
+	wantSkipped := []string{
+		"games.status: only null values",
+		"games.tags: only empty arrays",
+		"games.mixed: conflicting types int and string",
+		"games.alt: nested arrays",
+	}
+	if got := in.Skipped(); !reflect.DeepEqual(got, wantSkipped) {
+		t.Errorf("got: <%v>, want: <%v>", got, wantSkipped)
+	}
+}
+
+func TestInferenceAddErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		data     string
+		wantErr  string
+	}{
+		{"Blank endpoint", " ", `[]`, "blank"},
+		{"Malformed JSON", "games", `[{"id": 1`, "cannot decode sample"},
+		{"Scalar sample", "games", `5`, "is not an object or an array of objects"},
+		{"Scalar item", "games", `[{"id": 1}, 2]`, "item 1 is not an object"},
+		{"Trailing data", "games", `[] []`, "unexpected data after the sample"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := NewInference().Add(test.endpoint, []byte(test.data))
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestInferenceConflictingArray(t *testing.T) {
+	in := NewInference()
+	if err := in.Add("games", []byte(`[{"tags": [1]}, {"tags": "a"}]`)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"games.tags: conflicting types string and array"}
+	if got := in.Skipped(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got: <%v>, want: <%v>", got, want)
+	}
+}
@@ -0,0 +1,368 @@
+package apicalypse
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Names of the metrics recorded by Instrument.
+const (
+	// MetricRequestDuration is the histogram of the seconds each request took,
+	// from sending it to closing its response body.
+	MetricRequestDuration = "apicalypse.client.request.duration"
+	// MetricRequests is the counter of requests sent.
+	MetricRequests = "apicalypse.client.requests"
+	// MetricErrors is the counter of requests that failed or whose response has a
+	// status code of 400 or more.
+	MetricErrors = "apicalypse.client.errors"
+)
+
+// Attribute is a key and value describing a span or measurement. Values are of
+// type string, int64, bool, or []string.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Tracer starts spans. It is implemented by NopTracer and MemoryTracer. The
+// otelapicalypse module implements it on top of an OpenTelemetry trace.Tracer.
+type Tracer interface {
+	// Start starts a span with the provided name as a child of any span in the
+	// provided context and returns a context holding the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a traced operation started by a Tracer.
+type Span interface {
+	// SetAttributes sets attributes of the span.
+	SetAttributes(attrs ...Attribute)
+	// RecordError records that the operation failed with the provided error.
+	RecordError(err error)
+	// End ends the span.
+	End()
+}
+
+// Meter creates the instruments metrics are recorded with. It is implemented by
+// NopMeter and MemoryMeter. The otelapicalypse module implements it on top of an
+// OpenTelemetry metric.Meter.
+type Meter interface {
+	// Counter returns the counter with the provided name.
+	Counter(name string) Counter
+	// Histogram returns the histogram with the provided name.
+	Histogram(name string) Histogram
+}
+
+// Counter records values that are summed.
+type Counter interface {
+	Add(ctx context.Context, n int64, attrs ...Attribute)
+}
+
+// Histogram records values whose distribution is tracked.
+type Histogram interface {
+	Record(ctx context.Context, v float64, attrs ...Attribute)
+}
+
+// Instrument returns Middleware that traces every request and records metrics
+// about it. Each request gets a span named after its endpoint, such as
+// "apicalypse games", with the following attributes:
+//
+//	apicalypse.endpoint        the last segment of the url's path, such as "games"
+//	apicalypse.clauses         the keywords of the query's clauses
+//	apicalypse.limit           the query's limit, if any
+//	apicalypse.offset          the query's offset, if any
+//	http.request.method        the request's method
+//	url.full                   the request's url
+//	http.response.status_code  the response's status code
+//	http.response.body.size    the number of bytes of the response body read
+//
+// The span ends when the response body is closed. The duration of each request is
+// recorded in the MetricRequestDuration histogram, and each request and each
+// failure increment the MetricRequests and MetricErrors counters, all with the
+// endpoint, method, and status code as attributes. A nil Tracer or Meter disables
+// tracing or metrics.
+func Instrument(t Tracer, m Meter) Middleware {
+	if t == nil {
+		t = NopTracer{}
+	}
+	if m == nil {
+		m = NopMeter{}
+	}
+	duration := m.Histogram(MetricRequestDuration)
+	requests := m.Counter(MetricRequests)
+	failures := m.Counter(MetricErrors)
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			endpoint := strings.TrimSuffix(endpointName(req.URL.String()), protobufSuffix)
+			ctx, span := t.Start(req.Context(), "apicalypse "+endpoint)
+			span.SetAttributes(queryAttributes(req, endpoint)...)
+
+			start := time.Now()
+			resp, err := next.RoundTrip(req.WithContext(ctx))
+
+			attrs := []Attribute{{"apicalypse.endpoint", endpoint}, {"http.request.method", req.Method}}
+			if err != nil {
+				span.RecordError(err)
+				span.End()
+				requests.Add(ctx, 1, attrs...)
+				failures.Add(ctx, 1, attrs...)
+				duration.Record(ctx, time.Since(start).Seconds(), attrs...)
+				return nil, err
+			}
+
+			status := Attribute{"http.response.status_code", int64(resp.StatusCode)}
+			span.SetAttributes(status)
+			attrs = append(attrs, status)
+			requests.Add(ctx, 1, attrs...)
+			if resp.StatusCode >= 400 {
+				failures.Add(ctx, 1, attrs...)
+			}
+
+			resp.Body = &instrumentedBody{
+				ReadCloser: resp.Body,
+				end: func(size int64) {
+					span.SetAttributes(Attribute{"http.response.body.size", size})
+					span.End()
+					duration.Record(ctx, time.Since(start).Seconds(), attrs...)
+				},
+			}
+
+			return resp, nil
+		})
+	}
+}
+
+// queryAttributes returns the span attributes describing the provided request and
+// its query.
+func queryAttributes(req *http.Request, endpoint string) []Attribute {
+	attrs := []Attribute{
+		{"apicalypse.endpoint", endpoint},
+		{"http.request.method", req.Method},
+		{"url.full", req.URL.String()},
+	}
+
+	filters, err := RequestClauses(req)
+	if err != nil {
+		return attrs
+	}
+	attrs = append(attrs, Attribute{"apicalypse.clauses", clauseKeys(filters)})
+
+	for _, k := range []string{"limit", "offset"} {
+		if n, err := strconv.ParseInt(strings.TrimSpace(filters[k]), 10, 64); err == nil {
+			attrs = append(attrs, Attribute{"apicalypse." + k, n})
+		}
+	}
+
+	return attrs
+}
+
+// instrumentedBody wraps a response body, counting the bytes read from it and
+// calling end with the count once the body is closed.
+type instrumentedBody struct {
+	io.ReadCloser
+	size int64
+	once sync.Once
+	end  func(size int64)
+}
+
+// Read reads from the underlying body and counts the bytes read.
+func (b *instrumentedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	return n, err
+}
+
+// Close closes the underlying body and calls end.
+func (b *instrumentedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.end(b.size) })
+	return err
+}
+
+// NopTracer is a Tracer whose spans do nothing.
+type NopTracer struct{}
+
+// Start returns the provided context and a span that does nothing.
+func (NopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+// nopSpan is a Span that does nothing.
+type nopSpan struct{}
+
+// SetAttributes does nothing.
+func (nopSpan) SetAttributes(attrs ...Attribute) {}
+
+// RecordError does nothing.
+func (nopSpan) RecordError(err error) {}
+
+// End does nothing.
+func (nopSpan) End() {}
+
+// NopMeter is a Meter whose instruments do nothing.
+type NopMeter struct{}
+
+// Counter returns a counter that does nothing.
+func (NopMeter) Counter(name string) Counter { return nopInstrument{} }
+
+// Histogram returns a histogram that does nothing.
+func (NopMeter) Histogram(name string) Histogram { return nopInstrument{} }
+
+// nopInstrument is a Counter and Histogram that does nothing.
+type nopInstrument struct{}
+
+// Add does nothing.
+func (nopInstrument) Add(ctx context.Context, n int64, attrs ...Attribute) {}
+
+// Record does nothing.
+func (nopInstrument) Record(ctx context.Context, v float64, attrs ...Attribute) {}
+
+// MemoryTracer is a Tracer that keeps its spans in memory so that tests can
+// inspect them. A MemoryTracer is safe for concurrent use by multiple goroutines.
+type MemoryTracer struct {
+	mu    sync.Mutex
+	spans []*MemorySpan
+}
+
+// Start starts a MemorySpan.
+func (t *MemoryTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	s := &MemorySpan{tracer: t, Name: name}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = append(t.spans, s)
+
+	return ctx, s
+}
+
+// Spans returns the spans started so far, in the order they were started.
+func (t *MemoryTracer) Spans() []MemorySpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	spans := make([]MemorySpan, len(t.spans))
+	for i, s := range t.spans {
+		spans[i] = MemorySpan{Name: s.Name, Attributes: append([]Attribute{}, s.Attributes...), Err: s.Err, Ended: s.Ended}
+	}
+
+	return spans
+}
+
+// MemorySpan is a Span kept in memory by a MemoryTracer.
+type MemorySpan struct {
+	tracer *MemoryTracer
+
+	// Name is the name of the span.
+	Name string
+	// Attributes are the attributes set on the span, in the order they were set.
+	Attributes []Attribute
+	// Err is the error recorded on the span, if any.
+	Err error
+	// Ended reports whether the span has ended.
+	Ended bool
+}
+
+// SetAttributes appends the provided attributes to the span's attributes.
+func (s *MemorySpan) SetAttributes(attrs ...Attribute) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.Attributes = append(s.Attributes, attrs...)
+}
+
+// RecordError records the provided error on the span.
+func (s *MemorySpan) RecordError(err error) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.Err = err
+}
+
+// End ends the span.
+func (s *MemorySpan) End() {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.Ended = true
+}
+
+// Attribute returns the value of the last attribute with the provided key set on
+// the span and whether there is one.
+func (s MemorySpan) Attribute(key string) (interface{}, bool) {
+	for i := len(s.Attributes) - 1; i >= 0; i-- {
+		if s.Attributes[i].Key == key {
+			return s.Attributes[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+// Measurement is a value recorded by an instrument of a MemoryMeter.
+type Measurement struct {
+	Value      float64
+	Attributes []Attribute
+}
+
+// MemoryMeter is a Meter that keeps its measurements in memory so that tests can
+// inspect them. A MemoryMeter is safe for concurrent use by multiple goroutines.
+type MemoryMeter struct {
+	mu           sync.Mutex
+	measurements map[string][]Measurement
+}
+
+// Counter returns a counter that keeps its values in the MemoryMeter.
+func (m *MemoryMeter) Counter(name string) Counter {
+	return memoryInstrument{meter: m, name: name}
+}
+
+// Histogram returns a histogram that keeps its values in the MemoryMeter.
+func (m *MemoryMeter) Histogram(name string) Histogram {
+	return memoryInstrument{meter: m, name: name}
+}
+
+// Measurements returns the values recorded by the instrument with the provided
+// name, in the order they were recorded.
+func (m *MemoryMeter) Measurements(name string) []Measurement {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Measurement{}, m.measurements[name]...)
+}
+
+// Sum returns the sum of the values recorded by the instrument with the provided
+// name, such as the total of a counter.
+func (m *MemoryMeter) Sum(name string) float64 {
+	var sum float64
+	for _, ms := range m.Measurements(name) {
+		sum += ms.Value
+	}
+	return sum
+}
+
+// record keeps a measurement of the named instrument.
+func (m *MemoryMeter) record(name string, v float64, attrs []Attribute) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.measurements == nil {
+		m.measurements = map[string][]Measurement{}
+	}
+	m.measurements[name] = append(m.measurements[name], Measurement{Value: v, Attributes: append([]Attribute{}, attrs...)})
+}
+
+// memoryInstrument is a Counter and Histogram of a MemoryMeter.
+type memoryInstrument struct {
+	meter *MemoryMeter
+	name  string
+}
+
+// Add keeps the provided value as a measurement of the instrument.
+func (i memoryInstrument) Add(ctx context.Context, n int64, attrs ...Attribute) {
+	i.meter.record(i.name, float64(n), attrs)
+}
+
+// Record keeps the provided value as a measurement of the instrument.
+func (i memoryInstrument) Record(ctx context.Context, v float64, attrs ...Attribute) {
+	i.meter.record(i.name, v, attrs)
+}
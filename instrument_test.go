@@ -0,0 +1,128 @@
+package apicalypse
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestInstrument(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`[{"id":1}]`))
+	}))
+	defer ts.Close()
+
+	tracer := &MemoryTracer{}
+	meter := &MemoryMeter{}
+	c, err := NewClient(WithMiddleware(Instrument(tracer, meter)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"/v4/games.pb", "/missing"} {
+		resp, err := c.Send(context.Background(), "POST", ts.URL+p, Fields("id"), Limit(5), Offset(10))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	spans := tracer.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("got: <%v>, want: <%v>", len(spans), 2)
+	}
+
+	tests := []struct {
+		key  string
+		want interface{}
+	}{
+		{"apicalypse.endpoint", "games"},
+		{"apicalypse.clauses", []string{"fields", "limit", "offset"}},
+		{"apicalypse.limit", int64(5)},
+		{"apicalypse.offset", int64(10)},
+		{"http.request.method", "POST"},
+		{"url.full", ts.URL + "/v4/games.pb"},
+		{"http.response.status_code", int64(200)},
+		{"http.response.body.size", int64(10)},
+	}
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			got, ok := spans[0].Attribute(test.key)
+			if !ok || !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got: <%v>, want: <%v>", got, test.want)
+			}
+		})
+	}
+
+	if spans[0].Name != "apicalypse games" || !spans[0].Ended || spans[0].Err != nil {
+		t.Errorf("got: <%v>, want: <%v>", spans[0], "ended span named 'apicalypse games'")
+	}
+
+	if got := meter.Sum(MetricRequests); got != 2 {
+		t.Errorf("got: <%v>, want: <%v>", got, 2)
+	}
+
+	errs := meter.Measurements(MetricErrors)
+	want := []Attribute{{"apicalypse.endpoint", "missing"}, {"http.request.method", "POST"}, {"http.response.status_code", int64(404)}}
+	if len(errs) != 1 || !reflect.DeepEqual(errs[0].Attributes, want) {
+		t.Errorf("got: <%v>, want: <%v>", errs, want)
+	}
+
+	if got := len(meter.Measurements(MetricRequestDuration)); got != 2 {
+		t.Errorf("got: <%v>, want: <%v>", got, 2)
+	}
+}
+
+func TestInstrumentError(t *testing.T) {
+	failure := errors.New("connection refused")
+	tracer := &MemoryTracer{}
+	meter := &MemoryMeter{}
+	rt := Chain(RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, failure
+	}), Instrument(tracer, meter))
+
+	req, err := NewRequest("POST", "http://fake.com/games", Limit(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rt.RoundTrip(req); err != failure {
+		t.Errorf("got: <%v>, want: <%v>", err, failure)
+	}
+
+	spans := tracer.Spans()
+	if len(spans) != 1 || spans[0].Err != failure || !spans[0].Ended {
+		t.Errorf("got: <%v>, want: <%v>", spans, "ended span with error")
+	}
+
+	if meter.Sum(MetricErrors) != 1 || meter.Sum(MetricRequests) != 1 {
+		t.Errorf("got: <%v> errors of <%v> requests, want: <%v> of <%v>", meter.Sum(MetricErrors), meter.Sum(MetricRequests), 1, 1)
+	}
+}
+
+func TestInstrumentNop(t *testing.T) {
+	rt := Chain(RoundTripFunc(echoTransport), Instrument(nil, nil))
+
+	req, err := NewRequest("POST", "http://fake.com/games", Limit(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readBody(t, resp); got != "POST|||limit 5; " {
+		t.Errorf("got: <%v>, want: <%v>", got, "POST|||limit 5; ")
+	}
+}
@@ -0,0 +1,497 @@
+package apicalypse
+
+import (
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ParseOpenAPI builds a Schema from the component schemas of an OpenAPI 3 document
+// written in JSON or YAML.
+//
+// Every component schema describing an object becomes an endpoint. The endpoint's
+// name is taken from the path whose operations respond with an array of the
+// component, such as "games" for "/games", and otherwise from the component's name
+// in snake case, such as "game_mode" for "GameMode". The x-endpoint extension on a
+// component overrides both.
+//
+// Properties of type integer, number, string, and boolean become fields of the
+// matching FieldType, and arrays of them become array fields. Properties that
+// reference another component, directly or through allOf, oneOf, or anyOf, become
+// references to the component's endpoint, so they can be expanded with a dot.
+// Inline object properties become references to an endpoint named after the
+// property, such as "games_release_info". Scalar fields that are not arrays are
+// sortable unless the x-sortable extension says otherwise. Properties that allow
+// null are nullable. Properties of any other type are ignored.
+func ParseOpenAPI(data []byte) (*Schema, error) {
+	var doc struct {
+		OpenAPI    string                          `yaml:"openapi"`
+		Paths      map[string]map[string]yaml.Node `yaml:"paths"`
+		Components struct {
+			Schemas namedSchemas `yaml:"schemas"`
+		} `yaml:"components"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrap(err, "cannot parse OpenAPI document")
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, errors.Errorf("unsupported OpenAPI version '%s'", doc.OpenAPI)
+	}
+
+	b := newSchemaBuilder(doc.Components.Schemas, "#/components/schemas/")
+	for name, comp := range responseComponents(doc.Paths, b.prefixes[0]) {
+		if _, ok := b.names[comp]; ok && b.defs[comp].Endpoint == "" {
+			b.names[comp] = name
+		}
+	}
+
+	return b.build()
+}
+
+// responseComponents maps the name of each path to the component its operations
+// respond with an array of. The name of a path is its last segment that is not a
+// parameter.
+func responseComponents(paths map[string]map[string]yaml.Node, prefix string) map[string]string {
+	keys := make([]string, 0, len(paths))
+	for p := range paths {
+		keys = append(keys, p)
+	}
+	sort.Strings(keys)
+
+	comps := map[string]string{}
+	for _, p := range keys {
+		name := ""
+		for _, seg := range strings.Split(p, "/") {
+			if seg != "" && !strings.HasPrefix(seg, "{") {
+				name = seg
+			}
+		}
+		if name == "" {
+			continue
+		}
+
+		for _, method := range []string{"get", "post"} {
+			node, ok := paths[p][method]
+			if !ok {
+				continue
+			}
+
+			var op struct {
+				Responses map[string]struct {
+					Content map[string]struct {
+						Schema jsonSchema `yaml:"schema"`
+					} `yaml:"content"`
+				} `yaml:"responses"`
+			}
+			if err := node.Decode(&op); err != nil {
+				continue
+			}
+
+			for code, resp := range op.Responses {
+				if !strings.HasPrefix(code, "2") {
+					continue
+				}
+				for _, c := range resp.Content {
+					items := c.Schema.Items
+					if items == nil || !strings.HasPrefix(items.Ref, prefix) {
+						continue
+					}
+					if _, ok := comps[name]; !ok {
+						comps[name] = strings.TrimPrefix(items.Ref, prefix)
+					}
+				}
+			}
+		}
+	}
+
+	return comps
+}
+
+// ParseJSONSchema builds a Schema from a JSON Schema document written in JSON or
+// YAML. Every definition under $defs or definitions that describes an object
+// becomes an endpoint named after the definition in snake case, and so does the
+// root schema if it has properties, named after its title. The x-endpoint
+// extension overrides either name. Fields are built from properties as described
+// by ParseOpenAPI.
+func ParseJSONSchema(data []byte) (*Schema, error) {
+	var doc struct {
+		jsonSchema  `yaml:",inline"`
+		Title       string       `yaml:"title"`
+		Defs        namedSchemas `yaml:"$defs"`
+		Definitions namedSchemas `yaml:"definitions"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrap(err, "cannot parse JSON Schema document")
+	}
+
+	defs := append(doc.Defs, doc.Definitions...)
+	b := newSchemaBuilder(defs, "#/$defs/", "#/definitions/")
+
+	if len(doc.Properties) > 0 || len(doc.AllOf) > 0 {
+		name := doc.Endpoint
+		if name == "" {
+			name = snakeCase(doc.Title)
+		}
+		if name == "" {
+			return nil, errors.New("root schema has properties but no title or x-endpoint")
+		}
+
+		root := doc.jsonSchema
+		b.defs["#"] = &root
+		b.names["#"] = name
+		b.order = append(b.order, "#")
+	}
+
+	return b.build()
+}
+
+// jsonSchema is the subset of a JSON Schema used to build a Schema.
+type jsonSchema struct {
+	Ref        string        `yaml:"$ref"`
+	Type       schemaTypes   `yaml:"type"`
+	Properties namedSchemas  `yaml:"properties"`
+	Items      *jsonSchema   `yaml:"items"`
+	AllOf      []*jsonSchema `yaml:"allOf"`
+	OneOf      []*jsonSchema `yaml:"oneOf"`
+	AnyOf      []*jsonSchema `yaml:"anyOf"`
+	Nullable   bool          `yaml:"nullable"`
+	Endpoint   string        `yaml:"x-endpoint"`
+	Sortable   *bool         `yaml:"x-sortable"`
+}
+
+// schemaTypes are the types of a JSON Schema, which may be written as a single
+// type or a list of types.
+type schemaTypes []string
+
+// UnmarshalYAML decodes a single type or a list of types.
+func (t *schemaTypes) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*t = schemaTypes{n.Value}
+		return nil
+	}
+
+	var types []string
+	if err := n.Decode(&types); err != nil {
+		return err
+	}
+	*t = types
+	return nil
+}
+
+// namedSchema is a schema with the name it was declared under.
+type namedSchema struct {
+	name   string
+	schema *jsonSchema
+}
+
+// namedSchemas are schemas in the order they were declared.
+type namedSchemas []namedSchema
+
+// UnmarshalYAML decodes a mapping of names to schemas, keeping their order.
+func (s *namedSchemas) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind != yaml.MappingNode {
+		return errors.Errorf("line %d: expected a mapping of names to schemas", n.Line)
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		var js jsonSchema
+		if err := n.Content[i+1].Decode(&js); err != nil {
+			return err
+		}
+		*s = append(*s, namedSchema{n.Content[i].Value, &js})
+	}
+
+	return nil
+}
+
+// schemaBuilder builds the endpoints of a Schema from named JSON Schemas.
+type schemaBuilder struct {
+	// prefixes are the prefixes of local references to the named schemas.
+	prefixes []string
+	defs     map[string]*jsonSchema
+	// names maps the names of the schemas that describe objects to the names of
+	// their endpoints.
+	names     map[string]string
+	order     []string
+	endpoints []EndpointSchema
+	// resolving holds the names of the schemas that are not objects whose
+	// references are being resolved, to detect references that come back around.
+	resolving map[string]bool
+}
+
+// newSchemaBuilder returns a builder for the provided named schemas.
+func newSchemaBuilder(defs namedSchemas, prefixes ...string) *schemaBuilder {
+	b := &schemaBuilder{
+		prefixes:  prefixes,
+		defs:      map[string]*jsonSchema{},
+		names:     map[string]string{},
+		resolving: map[string]bool{},
+	}
+
+	for _, d := range defs {
+		b.defs[d.name] = d.schema
+		if !d.schema.isObject() {
+			continue
+		}
+
+		b.order = append(b.order, d.name)
+		b.names[d.name] = d.schema.Endpoint
+		if b.names[d.name] == "" {
+			b.names[d.name] = snakeCase(d.name)
+		}
+	}
+
+	return b
+}
+
+// build returns a Schema with an endpoint for each named schema that describes
+// an object.
+func (b *schemaBuilder) build() (*Schema, error) {
+	for _, name := range b.order {
+		if err := b.endpoint(b.names[name], b.defs[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	s := NewSchema()
+	if err := s.Register(b.endpoints...); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// endpoint adds the endpoint described by the provided object schema.
+func (b *schemaBuilder) endpoint(name string, js *jsonSchema) error {
+	props, err := b.properties(js, map[*jsonSchema]bool{})
+	if err != nil {
+		return errors.Wrapf(err, "endpoint '%s'", name)
+	}
+
+	e := EndpointSchema{Name: name}
+	for _, p := range props {
+		f, ok, err := b.field(name, p.name, p.schema)
+		if err != nil {
+			return errors.Wrapf(err, "endpoint '%s': property '%s'", name, p.name)
+		}
+		if ok {
+			f.Nullable = p.schema.nullable()
+			e.Fields = append(e.Fields, f)
+		}
+	}
+	b.endpoints = append(b.endpoints, e)
+
+	return nil
+}
+
+// properties returns the properties of the provided object schema, including
+// those of the schemas it is composed of with allOf.
+func (b *schemaBuilder) properties(js *jsonSchema, seen map[*jsonSchema]bool) (namedSchemas, error) {
+	if seen[js] {
+		return nil, nil
+	}
+	seen[js] = true
+
+	props := js.Properties
+	for _, sub := range js.AllOf {
+		if sub.Ref != "" {
+			target, err := b.resolve(sub.Ref)
+			if err != nil {
+				return nil, err
+			}
+			sub = target
+		}
+
+		more, err := b.properties(sub, seen)
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, more...)
+	}
+
+	return props, nil
+}
+
+// field returns the field described by the provided property schema of the named
+// endpoint and whether the property can be represented as a field.
+func (b *schemaBuilder) field(endpoint, name string, js *jsonSchema) (FieldSchema, bool, error) {
+	f := FieldSchema{Name: name}
+
+	if ref := js.ref(); ref != "" {
+		target, err := b.resolve(ref)
+		if err != nil {
+			return f, false, err
+		}
+
+		def := b.defName(ref)
+		if _, ok := b.names[def]; !ok {
+			// References to schemas that are not objects, such as enums, are
+			// treated as if the referenced schema were written inline.
+			if b.resolving[def] {
+				return f, false, errors.Errorf("reference '%s' refers to itself", ref)
+			}
+			b.resolving[def] = true
+			defer delete(b.resolving, def)
+
+			inner, ok, err := b.field(endpoint, name, target)
+			inner.Sortable = sortable(js, inner)
+			return inner, ok, err
+		}
+
+		f.Ref = b.names[def]
+		return f, true, nil
+	}
+
+	switch t := js.scalarType(); t {
+	case "array":
+		if js.Items == nil {
+			return f, false, nil
+		}
+		inner, ok, err := b.field(endpoint, name, js.Items)
+		if err != nil || !ok || inner.Array {
+			return f, false, err
+		}
+		inner.Array, inner.Sortable = true, false
+		if js.Sortable != nil {
+			inner.Sortable = *js.Sortable
+		}
+		return inner, true, nil
+	case "object":
+		if !js.isObject() {
+			return f, false, nil
+		}
+		f.Ref = endpoint + "_" + name
+		if err := b.endpoint(f.Ref, js); err != nil {
+			return f, false, err
+		}
+		return f, true, nil
+	case "integer":
+		f.Type = TypeInt
+	case "number":
+		f.Type = TypeFloat
+	case "string":
+		f.Type = TypeString
+	case "boolean":
+		f.Type = TypeBool
+	default:
+		return f, false, nil
+	}
+
+	f.Sortable = sortable(js, f)
+	return f, true, nil
+}
+
+// sortable reports whether the provided field described by the provided schema
+// is sortable.
+func sortable(js *jsonSchema, f FieldSchema) bool {
+	if js.Sortable != nil {
+		return *js.Sortable
+	}
+	return f.Ref == "" && !f.Array
+}
+
+// resolve returns the schema referred to by the provided local reference.
+func (b *schemaBuilder) resolve(ref string) (*jsonSchema, error) {
+	js, ok := b.defs[b.defName(ref)]
+	if !ok {
+		return nil, errors.Errorf("cannot resolve reference '%s'", ref)
+	}
+	return js, nil
+}
+
+// defName returns the name of the schema referred to by the provided local
+// reference, or the reference itself if it does not have a known prefix.
+func (b *schemaBuilder) defName(ref string) string {
+	for _, p := range b.prefixes {
+		if strings.HasPrefix(ref, p) {
+			return strings.TrimPrefix(ref, p)
+		}
+	}
+	return ref
+}
+
+// ref returns the reference made by the schema, either directly or through
+// allOf, oneOf, or anyOf, or an empty string if it makes none.
+func (js *jsonSchema) ref() string {
+	if js.Ref != "" {
+		return js.Ref
+	}
+	for _, list := range [][]*jsonSchema{js.AllOf, js.OneOf, js.AnyOf} {
+		for _, sub := range list {
+			if sub.Ref != "" {
+				return sub.Ref
+			}
+		}
+	}
+	return ""
+}
+
+// scalarType returns the type of the schema other than null. The first type of
+// a oneOf or anyOf is used if the schema has no type of its own.
+func (js *jsonSchema) scalarType() string {
+	for _, t := range js.Type {
+		if t != "null" {
+			return t
+		}
+	}
+	for _, list := range [][]*jsonSchema{js.OneOf, js.AnyOf} {
+		for _, sub := range list {
+			if t := sub.scalarType(); t != "" {
+				return t
+			}
+		}
+	}
+	if len(js.Properties) > 0 {
+		return "object"
+	}
+	return ""
+}
+
+// nullable reports whether the schema allows null, either with the nullable
+// keyword of OpenAPI 3.0 or with the null type.
+func (js *jsonSchema) nullable() bool {
+	if js.Nullable {
+		return true
+	}
+	for _, t := range js.Type {
+		if t == "null" {
+			return true
+		}
+	}
+	for _, list := range [][]*jsonSchema{js.OneOf, js.AnyOf} {
+		for _, sub := range list {
+			if sub.nullable() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isObject reports whether the schema describes an object with properties.
+func (js *jsonSchema) isObject() bool {
+	return len(js.Properties) > 0 || len(js.AllOf) > 0
+}
+
+// snakeCase returns the provided camel case name in snake case, such as
+// "game_mode" for "GameMode" or "release_url" for "ReleaseURL".
+func snakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (nextLower && unicode.IsUpper(runes[i-1])) {
+				b.WriteRune('_')
+			}
+		}
+		if r == '-' || r == ' ' {
+			r = '_'
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,232 @@
+package apicalypse
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testOpenAPI = `
+openapi: 3.0.3
+info: {title: Games, version: "1"}
+paths:
+  /v4/games:
+    parameters: []
+    post:
+      responses:
+        "200":
+          description: OK
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: "#/components/schemas/Game"}
+  /v4/games/{id}:
+    get:
+      responses:
+        "200":
+          description: OK
+components:
+  schemas:
+    Game:
+      type: object
+      properties:
+        id: {type: integer, format: int64}
+        name: {type: string}
+        rating: {type: number, nullable: true}
+        free: {type: boolean, x-sortable: false}
+        tags: {type: array, items: {type: integer}}
+        category: {$ref: "#/components/schemas/Category"}
+        cover:
+          oneOf:
+            - {type: integer}
+            - {$ref: "#/components/schemas/Cover"}
+        genres: {type: array, items: {$ref: "#/components/schemas/GameGenre"}}
+        release:
+          type: object
+          properties:
+            date: {type: integer}
+        metadata: {type: object}
+    Cover:
+      allOf:
+        - {$ref: "#/components/schemas/Image"}
+        - properties:
+            game: {$ref: "#/components/schemas/Game"}
+    Image:
+      type: object
+      properties:
+        url: {type: string}
+    GameGenre:
+      type: object
+      x-endpoint: genres
+      properties:
+        name: {type: string}
+    Category:
+      type: string
+      enum: [main, dlc]
+`
+
+func TestParseOpenAPI(t *testing.T) {
+	s, err := ParseOpenAPI([]byte(testOpenAPI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]FieldSchema{
+		"games": {
+			{Name: "id", Type: TypeInt, Sortable: true},
+			{Name: "name", Type: TypeString, Sortable: true},
+			{Name: "rating", Type: TypeFloat, Nullable: true, Sortable: true},
+			{Name: "free", Type: TypeBool},
+			{Name: "tags", Type: TypeInt, Array: true},
+			{Name: "category", Type: TypeString, Sortable: true},
+			{Name: "cover", Type: TypeInt, Ref: "cover"},
+			{Name: "genres", Type: TypeInt, Ref: "genres", Array: true},
+			{Name: "release", Type: TypeInt, Ref: "games_release"},
+		},
+		"games_release": {{Name: "date", Type: TypeInt, Sortable: true}},
+		"cover": {
+			{Name: "url", Type: TypeString, Sortable: true},
+			{Name: "game", Type: TypeInt, Ref: "games"},
+		},
+		"image":  {{Name: "url", Type: TypeString, Sortable: true}},
+		"genres": {{Name: "name", Type: TypeString, Sortable: true}},
+	}
+
+	if got := s.Endpoints(); len(got) != len(want) {
+		t.Fatalf("got: <%v>, want: <%v> endpoints", got, len(want))
+	}
+	for name, fields := range want {
+		e, ok := s.Endpoint(name)
+		if !ok {
+			t.Errorf("got: <%v>, want: <%v>", s.Endpoints(), name)
+			continue
+		}
+
+		if !reflect.DeepEqual(e.Fields, fields) {
+			t.Errorf("got: <%v>, want: <%v>", e.Fields, fields)
+		}
+	}
+
+	if _, err := QueryFor(s, "games", Fields("name", "cover.url", "cover.game.genres.name", "release.date"), Where(`category = "dlc"`)); err != nil {
+		t.Errorf("got: <%v>, want: <%v>", err, nil)
+	}
+}
+
+func TestParseOpenAPIErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr string
+	}{
+		{"Malformed", "openapi: [", "cannot parse OpenAPI document"},
+		{"Swagger 2", "swagger: '2.0'", "unsupported OpenAPI version ''"},
+		{"Unresolved reference", "openapi: 3.1.0\ncomponents:\n  schemas:\n    Game:\n      properties:\n        cover: {$ref: '#/components/schemas/Cover'}\n", "cannot resolve reference '#/components/schemas/Cover'"},
+		{"External reference", "openapi: 3.1.0\ncomponents:\n  schemas:\n    Game:\n      properties:\n        cover: {$ref: 'other.yaml#/Cover'}\n", "cannot resolve reference 'other.yaml#/Cover'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseOpenAPI([]byte(test.doc))
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseJSONSchema(t *testing.T) {
+	doc := `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title": "Game",
+		"x-endpoint": "games",
+		"type": "object",
+		"properties": {
+			"name": {"type": ["string", "null"]},
+			"platforms": {"type": "array", "items": {"$ref": "#/$defs/Platform"}},
+			"parent": {"$ref": "#"}
+		},
+		"$defs": {
+			"Platform": {
+				"type": "object",
+				"properties": {
+					"name": {"type": "string"},
+					"family": {"$ref": "#/definitions/PlatformFamily"}
+				}
+			}
+		},
+		"definitions": {
+			"PlatformFamily": {"properties": {"slug": {"type": "string", "x-sortable": false}}}
+		}
+	}`
+
+	s, err := ParseJSONSchema([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]FieldSchema{
+		"games": {
+			{Name: "name", Type: TypeString, Nullable: true, Sortable: true},
+			{Name: "platforms", Type: TypeInt, Ref: "platform", Array: true},
+			{Name: "parent", Type: TypeInt, Ref: "games"},
+		},
+		"platform": {
+			{Name: "name", Type: TypeString, Sortable: true},
+			{Name: "family", Type: TypeInt, Ref: "platform_family"},
+		},
+		"platform_family": {{Name: "slug", Type: TypeString}},
+	}
+
+	if got := s.Endpoints(); len(got) != len(want) {
+		t.Fatalf("got: <%v>, want: <%v> endpoints", got, len(want))
+	}
+	for name, fields := range want {
+		e, ok := s.Endpoint(name)
+		if !ok {
+			t.Errorf("got: <%v>, want: <%v>", s.Endpoints(), name)
+			continue
+		}
+
+		if !reflect.DeepEqual(e.Fields, fields) {
+			t.Errorf("got: <%v>, want: <%v>", e.Fields, fields)
+		}
+	}
+}
+
+func TestParseJSONSchemaUntitledRoot(t *testing.T) {
+	_, err := ParseJSONSchema([]byte(`{"properties": {"name": {"type": "string"}}}`))
+	want := "root schema has properties but no title or x-endpoint"
+	if err == nil || err.Error() != want {
+		t.Errorf("got: <%v>, want: <%v>", err, want)
+	}
+}
+
+func TestParseJSONSchemaCyclicReference(t *testing.T) {
+	doc := `{"$defs":{"Game":{"type":"object","properties":{"tags":{"$ref":"#/$defs/Tags"}}},"Tags":{"type":"array","items":{"$ref":"#/$defs/Tags"}}}}`
+
+	_, err := ParseJSONSchema([]byte(doc))
+	want := "reference '#/$defs/Tags' refers to itself"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got: <%v>, want: <%v>", err, want)
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Game", "game"},
+		{"GameMode", "game_mode"},
+		{"ReleaseURL", "release_url"},
+		{"URLShortener", "url_shortener"},
+		{"Platform2Family", "platform2_family"},
+		{"game-engine", "game_engine"},
+		{"already_snake", "already_snake"},
+	}
+	for _, test := range tests {
+		if got := snakeCase(test.name); got != test.want {
+			t.Errorf("got: <%v>, want: <%v>", got, test.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package apicalypse
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter restricts the rate and concurrency of requests sent by a Client.
+// The rate is enforced with a token bucket and the concurrency with a semaphore.
+// A single Limiter is safe for concurrent use and may be shared across goroutines
+// and Clients so that every request counts against the same API quota.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	sem    chan struct{}
+}
+
+// NewLimiter returns a Limiter that allows up to rate requests per second, bursts
+// of up to burst requests, and up to concurrent requests in flight at once.
+// A rate or concurrent value of zero disables the respective limit.
+func NewLimiter(rate float64, burst, concurrent int) (*Limiter, error) {
+	if rate < 0 || burst < 0 || concurrent < 0 {
+		return nil, ErrNegativeInput
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	l := &Limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+	if concurrent > 0 {
+		l.sem = make(chan struct{}, concurrent)
+	}
+
+	return l, nil
+}
+
+// Acquire blocks until a request may be sent or the provided context is done.
+// On success, the returned release function must be called once the request
+// has completed to free its concurrency slot. Calling release more than once
+// has no additional effect.
+func (l *Limiter) Acquire(ctx context.Context) (release func(), err error) {
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			if l.sem != nil {
+				<-l.sem
+			}
+		})
+	}
+
+	if err := l.wait(ctx); err != nil {
+		release()
+		return nil, err
+	}
+
+	return release, nil
+}
+
+// wait reserves a token from the bucket and blocks until the reservation is due.
+// If the context is done before then, the token is returned to the bucket.
+func (l *Limiter) wait(ctx context.Context) error {
+	if l.rate == 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,125 @@
+package apicalypse
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewLimiter(t *testing.T) {
+	tests := []struct {
+		name       string
+		rate       float64
+		burst      int
+		concurrent int
+		wantErr    error
+	}{
+		{"Positive arguments", 10, 5, 2, nil},
+		{"Zero arguments", 0, 0, 0, nil},
+		{"Negative rate", -1, 5, 2, ErrNegativeInput},
+		{"Negative burst", 10, -5, 2, ErrNegativeInput},
+		{"Negative concurrent", 10, 5, -2, ErrNegativeInput},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewLimiter(test.rate, test.burst, test.concurrent)
+			if !reflect.DeepEqual(err, test.wantErr) {
+				t.Errorf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestLimiterConcurrency(t *testing.T) {
+	l, err := NewLimiter(0, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cur, max int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := l.Acquire(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer release()
+
+			n := atomic.AddInt32(&cur, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&cur, -1)
+		}()
+	}
+	wg.Wait()
+
+	if max > 2 {
+		t.Errorf("got: <%v>, want: <%v>", max, 2)
+	}
+}
+
+func TestLimiterRate(t *testing.T) {
+	l, err := NewLimiter(100, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		release, err := l.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+
+	// The first token is available immediately and the remaining four are
+	// released every 10ms.
+	if got, want := time.Since(start), 40*time.Millisecond; got < want {
+		t.Errorf("got: <%v>, want at least: <%v>", got, want)
+	}
+}
+
+func TestLimiterCancel(t *testing.T) {
+	tests := []struct {
+		name       string
+		rate       float64
+		concurrent int
+	}{
+		{"Waiting for token", 0.001, 0},
+		{"Waiting for slot", 0, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l, err := NewLimiter(test.rate, 1, test.concurrent)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Use up the only available token or slot.
+			if _, err := l.Acquire(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			_, err = l.Acquire(ctx)
+			if err != context.DeadlineExceeded {
+				t.Errorf("got: <%v>, want: <%v>", err, context.DeadlineExceeded)
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package apicalypse
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"sort"
+	"strings"
+)
+
+// Position is a location within a query string.
+type Position struct {
+	// Offset is the byte offset, starting at 0.
+	Offset int
+	// Line is the line number, starting at 1.
+	Line int
+	// Column is the column number in bytes, starting at 1.
+	Column int
+}
+
+// String returns the position in the form "line:column".
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Issue is a problem found in a query by Lint.
+type Issue struct {
+	// Pos is where the problem begins.
+	Pos Position
+	// Message describes the problem.
+	Message string
+}
+
+// String returns the issue in the form "line:column: message".
+func (i Issue) String() string {
+	return i.Pos.String() + ": " + i.Message
+}
+
+// Lint checks a query string for problems and returns the issues it found in the
+// order they appear. It reports syntax errors such as unbalanced parentheses,
+// unterminated strings, and missing semicolons, as well as unknown or repeated
+// clauses. The value of every known clause is checked against the same rules its
+// functional option enforces, so Lint reports negative limits, blank fields, and
+// any other input the options would reject. The clauses of a multiquery's
+// subqueries are checked as well.
+func Lint(query string) []Issue {
+	var issues []Issue
+	for _, p := range lintQuery(query, 0) {
+		issues = append(issues, Issue{Pos: position(query, p.offset), Message: p.message})
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Pos.Offset < issues[j].Pos.Offset
+	})
+
+	return issues
+}
+
+// Format rewrites a query string into its canonical form. Clauses are written in
+// a fixed order, whitespace outside of quotes is collapsed, the fields of the
+// fields and exclude clauses are sorted and deduplicated, and sort keys without
+// an order are written with "asc", as ParseSort reads them. The subqueries of a
+// multiquery are formatted individually and keep their order. Format returns an
+// error if the query has syntax errors or repeated clauses; it does not otherwise
+// validate the clauses. A missing semicolon after the last clause is not an error.
+func Format(query string) (string, error) {
+	scan := scanQuery(query, 0)
+	if len(scan.problems) > 0 {
+		p := scan.problems[0]
+		return "", errors.Errorf("%v: %s", position(query, p.offset), p.message)
+	}
+
+	filters := map[string]string{}
+	var subs []string
+	for _, c := range scan.clauses(query) {
+		if c.keyword == "" {
+			continue
+		}
+		if c.value == "" {
+			return "", errors.Errorf("%v: clause '%s' is missing a value", position(query, c.offset), c.keyword)
+		}
+
+		if c.keyword == "query" {
+			s, err := formatSubquery(c.value)
+			if err != nil {
+				return "", errors.Wrapf(err, "%v: cannot format subquery", position(query, c.valueOffset))
+			}
+			subs = append(subs, "query "+s+";")
+			continue
+		}
+
+		if _, ok := filters[c.keyword]; ok {
+			return "", errors.Errorf("%v: clause '%s' is repeated", position(query, c.offset), c.keyword)
+		}
+		filters[c.keyword] = collapseSpace(c.value)
+	}
+
+	s := strings.TrimSpace(toString(canonicalFilters(filters)))
+	if len(subs) > 0 {
+		s = strings.TrimSpace(s + " " + strings.Join(subs, " "))
+	}
+
+	return s, nil
+}
+
+// formatSubquery formats the value of a multiquery's query clause.
+func formatSubquery(v string) (string, error) {
+	open, close := strings.Index(v, "{"), strings.LastIndex(v, "}")
+	if open < 0 || close < open {
+		return "", errors.New("subquery is missing its body")
+	}
+
+	body, err := Format(v[open+1 : close])
+	if err != nil {
+		return "", err
+	}
+	if body != "" {
+		body += " "
+	}
+
+	return collapseSpace(strings.TrimSpace(v[:open])) + " { " + body + "}", nil
+}
+
+// problem is an issue found at a byte offset within a query.
+type problem struct {
+	offset  int
+	message string
+}
+
+// lintQuery returns the problems found in the provided query, whose offsets are
+// shifted by base.
+func lintQuery(q string, base int) []problem {
+	scan := scanQuery(q, base)
+	problems := scan.problems
+	if scan.unterminated >= 0 {
+		problems = append(problems, problem{scan.unterminated, "missing ';' at end of clause"})
+	}
+	seen := map[string]bool{}
+
+	for _, c := range scan.clauses(q) {
+		if c.keyword == "" {
+			continue
+		}
+
+		if c.keyword == "query" {
+			problems = append(problems, lintSubquery(c)...)
+			continue
+		}
+
+		if clauseIndex(c.keyword) < 0 {
+			problems = append(problems, problem{c.offset, fmt.Sprintf("unknown clause '%s'", c.keyword)})
+			continue
+		}
+		if seen[c.keyword] {
+			problems = append(problems, problem{c.offset, fmt.Sprintf("clause '%s' is repeated", c.keyword)})
+			continue
+		}
+		seen[c.keyword] = true
+
+		if c.value == "" {
+			problems = append(problems, problem{c.offset, fmt.Sprintf("clause '%s' is missing a value", c.keyword)})
+			continue
+		}
+
+		// A clause with a syntax problem cannot be meaningfully validated.
+		if hasProblem(scan.problems, c.offset, c.end) {
+			continue
+		}
+
+		opt, err := ParseClause(c.keyword, c.value)
+		if err == nil {
+			err = opt(map[string]string{})
+		}
+		if err != nil {
+			problems = append(problems, problem{c.valueOffset, fmt.Sprintf("invalid %s: %v", c.keyword, err)})
+		}
+	}
+
+	return problems
+}
+
+// hasProblem reports whether any of the provided problems lies between the
+// provided offsets.
+func hasProblem(problems []problem, start, end int) bool {
+	for _, p := range problems {
+		if p.offset >= start && p.offset <= end {
+			return true
+		}
+	}
+	return false
+}
+
+// lintSubquery returns the problems found in a multiquery's query clause.
+func lintSubquery(c clause) []problem {
+	open, close := strings.Index(c.value, "{"), strings.LastIndex(c.value, "}")
+	if open < 0 || close < open {
+		return []problem{{c.valueOffset, "subquery is missing its body"}}
+	}
+
+	var problems []problem
+	head := strings.Fields(c.value[:open])
+	if len(head) != 2 || !strings.HasPrefix(head[1], `"`) || !strings.HasSuffix(head[1], `"`) {
+		problems = append(problems, problem{c.valueOffset, `subquery must have the form: query <endpoint> "<name>" { ... }`})
+	}
+
+	return append(problems, lintQuery(c.value[open+1:close], c.valueOffset+open+1)...)
+}
+
+// clause is a single clause within a query.
+type clause struct {
+	keyword     string
+	value       string
+	offset      int
+	valueOffset int
+	end         int
+}
+
+// span is the byte range of a clause within a query, excluding its semicolon.
+type span struct {
+	start int
+	end   int
+}
+
+// scan is the result of scanning a query for its clauses.
+type scan struct {
+	spans    []span
+	problems []problem
+	base     int
+	// unterminated is the offset at which the last clause is missing its
+	// semicolon or -1 if it is not missing.
+	unterminated int
+}
+
+// clauses returns the clauses of the scanned query q. Clauses that hold nothing
+// but whitespace have an empty keyword.
+func (s scan) clauses(q string) []clause {
+	cs := make([]clause, len(s.spans))
+	for i, sp := range s.spans {
+		text := q[sp.start:sp.end]
+		trimmed := strings.TrimLeft(text, " \t\r\n")
+		offset := sp.start + len(text) - len(trimmed)
+		trimmed = strings.TrimRight(trimmed, " \t\r\n")
+
+		c := clause{offset: s.base + offset, valueOffset: s.base + offset, end: s.base + sp.end}
+		if j := strings.IndexAny(trimmed, " \t\r\n"); j >= 0 {
+			value := strings.TrimLeft(trimmed[j:], " \t\r\n")
+			c.keyword = trimmed[:j]
+			c.value = value
+			c.valueOffset = s.base + offset + len(trimmed) - len(value)
+		} else {
+			c.keyword = trimmed
+		}
+		cs[i] = c
+	}
+
+	return cs
+}
+
+// closers maps each opening delimiter to its closing delimiter.
+var closers = map[rune]rune{'(': ')', '[': ']', '{': '}'}
+
+// scanQuery splits a query string on the semicolons that end its clauses and
+// reports any syntax problems found along the way. Semicolons inside quotes,
+// parentheses, brackets, or braces do not end a clause. Problem offsets are
+// shifted by base.
+func scanQuery(q string, base int) scan {
+	type opener struct {
+		r      rune
+		offset int
+	}
+
+	s := scan{base: base, unterminated: -1}
+	var stack []opener
+	start, quoteStart := 0, -1
+	escaped := false
+
+	for i, r := range q {
+		switch {
+		case escaped:
+			escaped = false
+		case quoteStart >= 0 && r == '\\':
+			escaped = true
+		case r == '"':
+			if quoteStart >= 0 {
+				quoteStart = -1
+			} else {
+				quoteStart = i
+			}
+		case quoteStart >= 0:
+		case closers[r] != 0:
+			stack = append(stack, opener{r, i})
+		case r == ')' || r == ']' || r == '}':
+			if len(stack) == 0 || closers[stack[len(stack)-1].r] != r {
+				s.problems = append(s.problems, problem{base + i, fmt.Sprintf("unbalanced '%c'", r)})
+				continue
+			}
+			stack = stack[:len(stack)-1]
+		case r == ';' && len(stack) == 0:
+			s.spans = append(s.spans, span{start, i})
+			start = i + 1
+		}
+	}
+
+	if quoteStart >= 0 {
+		s.problems = append(s.problems, problem{base + quoteStart, "unterminated string"})
+	}
+	for _, o := range stack {
+		s.problems = append(s.problems, problem{base + o.offset, fmt.Sprintf("unbalanced '%c'", o.r)})
+	}
+
+	if strings.TrimSpace(q[start:]) != "" {
+		s.spans = append(s.spans, span{start, len(q)})
+		if quoteStart < 0 && len(stack) == 0 {
+			s.unterminated = base + len(strings.TrimRight(q, " \t\r\n"))
+		}
+	}
+
+	return s
+}
+
+// position returns the position of the provided byte offset within q.
+func position(q string, offset int) Position {
+	if offset > len(q) {
+		offset = len(q)
+	}
+
+	line := 1 + strings.Count(q[:offset], "\n")
+	col := offset + 1
+	if i := strings.LastIndex(q[:offset], "\n"); i >= 0 {
+		col = offset - i
+	}
+
+	return Position{Offset: offset, Line: line, Column: col}
+}
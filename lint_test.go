@@ -0,0 +1,114 @@
+package apicalypse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"Empty query", "", nil},
+		{"Valid query", `fields name,rating; where rating > 80 & genres = (5, 12); sort rating desc; limit 10; offset 0; search "halo";`, nil},
+		{"Missing semicolon", "fields name; limit 5", []string{"1:21: missing ';' at end of clause"}},
+		{"Unknown clause", "fields name; order rating;", []string{"1:14: unknown clause 'order'"}},
+		{"Repeated clause", "limit 5; limit 6;", []string{"1:10: clause 'limit' is repeated"}},
+		{"Missing value", "fields name; limit;", []string{"1:14: clause 'limit' is missing a value"}},
+		{"Negative limit", "limit -5;", []string{"1:7: invalid limit: input cannot be a negative number"}},
+		{"Non-integer offset", "offset ten;", []string{"1:8: invalid offset: 'ten' is not an integer"}},
+		{"Blank field", "fields name,,rating;", []string{"1:8: invalid fields: a provided argument is blank or empty"}},
+		{"Blank excluded field", "exclude ,name;", []string{"1:9: invalid exclude: a provided argument is blank or empty"}},
+		{"Sort without order", "sort rating;", nil},
+		{"Invalid sort", "sort rating desc extra;", []string{"1:6: invalid sort: invalid sort key 'rating desc extra': want a field optionally followed by an order"}},
+		{"Unquoted search", "search halo;", []string{"1:8: invalid search: search term must be quoted"}},
+		{"Unbalanced opening paren", "where id = (1, 2;", []string{"1:12: unbalanced '('"}},
+		{"Unbalanced closing paren", "where id = 1, 2);", []string{"1:16: unbalanced ')'"}},
+		{"Mismatched delimiters", "where id = (1, 2];", []string{"1:17: unbalanced ']'", "1:12: unbalanced '('"}},
+		{"Unterminated string", `search "halo;`, []string{`1:8: unterminated string`}},
+		{"Multiple lines", "fields name;\nlimit -1;\nfoo bar;", []string{"2:7: invalid limit: input cannot be a negative number", "3:1: unknown clause 'foo'"}},
+		{"Valid multiquery", `query games "A" { fields name; limit 5; }; query platforms "B" { fields *; };`, nil},
+		{"Invalid subquery", `query games "A" { fields name; limit -5; };`, []string{"1:38: invalid limit: input cannot be a negative number"}},
+		{"Malformed subquery", `query games { fields name; };`, []string{`1:7: subquery must have the form: query <endpoint> "<name>" { ... }`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, i := range Lint(test.query) {
+				got = append(got, i.String())
+			}
+
+			// Issues at the same position keep the order they were found in,
+			// so compare as sets when several issues are expected.
+			if len(got) != len(test.want) {
+				t.Fatalf("got: <%v>, want: <%v>", got, test.want)
+			}
+			for _, w := range test.want {
+				found := false
+				for _, g := range got {
+					found = found || g == w
+				}
+				if !found {
+					t.Errorf("got: <%v>, want: <%v>", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		want      string
+		wantError bool
+	}{
+		{"Empty query", "  ", "", false},
+		{"Canonical query", "fields name; limit 5;", "fields name; limit 5;", false},
+		{"Ordered clauses", "limit 5; where a = 1; fields name;", "fields name; where a = 1; limit 5;", false},
+		{"Normalized whitespace", "  fields   name ;\n\twhere  a =  1 &\n b = \"x  y\";", `fields name; where a = 1 & b = "x  y";`, false},
+		{"Sorted and deduplicated fields", "fields rating, name, id, name; exclude url,cover;", "fields id,name,rating; exclude cover,url;", false},
+		{"Missing final semicolon", "limit 5", "limit 5;", false},
+		{"Multiquery", `query games "A" {limit 5;fields b,a;}; query games/count "B" { where x=1; };`, `query games "A" { fields a,b; limit 5; }; query games/count "B" { where x=1; };`, false},
+		{"Unbalanced paren", "where id = (1;", "", true},
+		{"Repeated clause", "limit 1; limit 2;", "", true},
+		{"Missing value", "limit;", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Format(test.query)
+			if (err != nil) != test.wantError {
+				t.Fatalf("got: <%v>, want error: <%v>", err, test.wantError)
+			}
+
+			if got != test.want {
+				t.Errorf("got: <%v>, want: <%v>", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFormatIdempotent(t *testing.T) {
+	queries := []string{
+		"limit 5; fields b,a;",
+		`search "halo"; where rating > 80;`,
+		`query games "A" { fields name; };`,
+	}
+	for _, q := range queries {
+		once, err := Format(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		twice, err := Format(once)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(once, twice) {
+			t.Errorf("got: <%v>, want: <%v>", twice, once)
+		}
+	}
+}
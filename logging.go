@@ -0,0 +1,150 @@
+package apicalypse
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maskedValue replaces masked values in logged queries.
+const maskedValue = "?"
+
+// LogOptions configure the Middleware returned by Log.
+type LogOptions struct {
+	// Level is the level requests are logged at. Defaults to slog.LevelInfo.
+	Level slog.Level
+	// ErrorLevel is the level requests that fail or whose response has a status
+	// code of 400 or more are logged at, if higher than Level.
+	ErrorLevel slog.Level
+	// MaskWhere replaces every value compared in the where clause of logged queries
+	// with "?", so that "where email = \"a@b.com\" & age > 30" is logged as
+	// "where email = ? & age > ?". Where clauses that cannot be parsed are replaced
+	// entirely.
+	MaskWhere bool
+}
+
+// WithLogger is a functional option for logging every request the Client sends
+// to the provided logger. It is equivalent to WithMiddleware with Log.
+func WithLogger(logger *slog.Logger, opts LogOptions) ClientOption {
+	return func(c *Client) error {
+		if logger == nil {
+			return ErrMissingInput
+		}
+
+		return WithMiddleware(Log(logger, opts))(c)
+	}
+}
+
+// Log returns Middleware that logs every request to the provided logger once its
+// response headers are received or it fails. Each record has the message
+// "apicalypse request" and the following attributes:
+//
+//	endpoint  the last segment of the url's path, such as "games"
+//	method    the request's method
+//	query     the request's query in canonical form
+//	status    the response's status code, if any
+//	duration  how long the request took
+//	retry     the number of retries that preceded the request
+//	error     the error the request failed with, if any
+func Log(logger *slog.Logger, opts LogOptions) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			ctx := req.Context()
+			level := opts.Level
+			if !logger.Enabled(ctx, level) && !logger.Enabled(ctx, opts.ErrorLevel) {
+				return next.RoundTrip(req)
+			}
+			query := logQuery(req, opts.MaskWhere)
+
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+
+			attrs := []slog.Attr{
+				slog.String("endpoint", strings.TrimSuffix(endpointName(req.URL.String()), protobufSuffix)),
+				slog.String("method", req.Method),
+				slog.String("query", query),
+			}
+			if resp != nil {
+				attrs = append(attrs, slog.Int("status", resp.StatusCode))
+			}
+			attrs = append(attrs, slog.Duration("duration", time.Since(start)))
+			attrs = append(attrs, slog.Int("retry", retries(ctx)))
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+			}
+
+			if (err != nil || resp.StatusCode >= 400) && opts.ErrorLevel > level {
+				level = opts.ErrorLevel
+			}
+			logger.LogAttrs(ctx, level, "apicalypse request", attrs...)
+
+			return resp, err
+		})
+	}
+}
+
+// logQuery returns the query of the provided request in canonical form for logging,
+// with the values of its where clause masked if requested.
+func logQuery(req *http.Request, mask bool) string {
+	filters, err := RequestClauses(req)
+	if err != nil {
+		return ""
+	}
+
+	for k := range filters {
+		if clauseIndex(k) < 0 {
+			q, _ := queryText(req)
+			if mask {
+				return maskedValue
+			}
+			return collapseSpace(strings.TrimSpace(q))
+		}
+	}
+
+	filters = canonicalFilters(filters)
+	if w, ok := filters["where"]; ok && mask {
+		filters["where"] = maskWhere(w)
+	}
+
+	return strings.TrimSpace(toString(filters))
+}
+
+// maskWhere returns the provided where clause with every compared value other
+// than null replaced with "?".
+func maskWhere(where string) string {
+	comps, err := parseWhere(where)
+	if err != nil {
+		return maskedValue
+	}
+
+	var lits []literal
+	for _, c := range comps {
+		for _, v := range c.values {
+			if v.kind != literalNull {
+				lits = append(lits, v)
+			}
+		}
+	}
+
+	b := strings.Builder{}
+	prev := 0
+	for _, l := range lits {
+		b.WriteString(where[prev:l.start])
+		b.WriteString(maskedValue)
+		prev = l.end
+	}
+	b.WriteString(where[prev:])
+
+	return b.String()
+}
+
+// retries returns the number of retries that preceded a request sent with the
+// provided context.
+func retries(ctx context.Context) int {
+	if n := RetryAttempt(ctx); n > 1 {
+		return n - 1
+	}
+	return 0
+}
@@ -0,0 +1,174 @@
+package apicalypse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMaskWhere(t *testing.T) {
+	tests := []struct {
+		name  string
+		where string
+		want  string
+	}{
+		{"Single value", `email = "a@b.com"`, `email = ?`},
+		{"Multiple comparisons", `email = "a@b.com" & age > 30`, `email = ? & age > ?`},
+		{"Null kept", `cover != null & rating >= 80.5`, `cover != null & rating >= ?`},
+		{"Wildcards", `name ~ *"halo"*`, `name ~ ?`},
+		{"Value list", `id = (1, 2, 3)`, `id = (?, ?, ?)`},
+		{"Unparseable", `name = "halo`, `?`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := maskWhere(test.where)
+			if got != test.want {
+				t.Errorf("got: <%v>, want: <%v>", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	if _, err := NewClient(WithLogger(nil, LogOptions{})); errors.Cause(err) != ErrMissingInput {
+		t.Errorf("got: <%v>, want: <%v>", err, ErrMissingInput)
+	}
+}
+
+func TestLog(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		opts      LogOptions
+		wantLevel []string
+		wantQuery string
+	}{
+		{"Default options", LogOptions{}, []string{"INFO", "INFO"}, `fields id,name; where email = "a@b.com" & cover != null; limit 5;`},
+		{"Masked where", LogOptions{MaskWhere: true}, []string{"INFO", "INFO"}, `fields id,name; where email = ? & cover != null; limit 5;`},
+		{"Debug with error level", LogOptions{Level: slog.LevelDebug, ErrorLevel: slog.LevelWarn}, []string{"WARN", "DEBUG"}, `fields id,name; where email = "a@b.com" & cover != null; limit 5;`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+			c, err := NewClient(WithLogger(logger, test.opts), WithRetry(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := c.Send(context.Background(), "POST", ts.URL+"/v4/games.pb", Fields("name", "id"), Where(`email = "a@b.com" & cover != null`), Limit(5))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if len(lines) != 2 {
+				t.Fatalf("got: <%v>, want: <%v>", len(lines), 2)
+			}
+
+			wantStatus := []float64{503, 200}
+			for i, line := range lines {
+				var rec map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &rec); err != nil {
+					t.Fatal(err)
+				}
+
+				if rec["level"] != test.wantLevel[i] {
+					t.Errorf("got: <%v>, want: <%v>", rec["level"], test.wantLevel[i])
+				}
+
+				if rec["msg"] != "apicalypse request" || rec["endpoint"] != "games" || rec["method"] != "POST" {
+					t.Errorf("got: <%v>, want: <%v>", rec, "POST request to games")
+				}
+
+				if rec["query"] != test.wantQuery {
+					t.Errorf("got: <%v>, want: <%v>", rec["query"], test.wantQuery)
+				}
+
+				if rec["status"] != wantStatus[i] {
+					t.Errorf("got: <%v>, want: <%v>", rec["status"], wantStatus[i])
+				}
+
+				if rec["retry"] != float64(i) {
+					t.Errorf("got: <%v>, want: <%v>", rec["retry"], i)
+				}
+
+				if _, ok := rec["duration"]; !ok {
+					t.Errorf("got: <%v>, want: <%v>", rec, "duration")
+				}
+			}
+		})
+	}
+}
+
+func TestLogError(t *testing.T) {
+	failure := errors.New("connection refused")
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	rt := Chain(RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, failure
+	}), Log(logger, LogOptions{ErrorLevel: slog.LevelError}))
+
+	req, err := NewRequest("GET", "http://fake.com/games", Limit(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rt.RoundTrip(req); err != failure {
+		t.Errorf("got: <%v>, want: <%v>", err, failure)
+	}
+
+	for _, want := range []string{"level=ERROR", "endpoint=games", `query="limit 5;"`, "retry=0", `error="connection refused"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("got: <%v>, want: <%v>", buf.String(), want)
+		}
+	}
+
+	if strings.Contains(buf.String(), "status=") {
+		t.Errorf("got: <%v>, want no: <%v>", buf.String(), "status")
+	}
+}
+
+func TestLogDisabled(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	rt := Chain(RoundTripFunc(echoTransport), Log(logger, LogOptions{Level: slog.LevelDebug}))
+
+	req, err := NewRequest("POST", "http://fake.com/games", Limit(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readBody(t, resp); got != "POST|||limit 5; " {
+		t.Errorf("got: <%v>, want: <%v>", got, "POST|||limit 5; ")
+	}
+
+	if buf.Len() != 0 {
+		t.Errorf("got: <%v>, want: <%v>", buf.String(), "")
+	}
+}
@@ -0,0 +1,380 @@
+package apicalypse
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Henry-Sarabia/blank"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultChunkSize      = 500
+	defaultConcurrency    = 4
+	defaultMultiquerySize = 10
+)
+
+// LookupPolicy configures how Lookup splits a large ID lookup into chunks.
+type LookupPolicy struct {
+	// ChunkSize is the number of IDs looked up by a single query. Defaults to the
+	// limit set by the base options or, if there is none, to 500.
+	ChunkSize int
+	// Concurrency is the maximum number of requests in flight at once.
+	// Defaults to 4.
+	Concurrency int
+	// MultiqueryURL, if set, is the URL of the API's multiquery endpoint. Chunks
+	// are then packed into multiqueries sent to this URL instead of being sent
+	// to the lookup URL one by one.
+	MultiqueryURL string
+	// MultiquerySize is the number of chunks packed into a single multiquery.
+	// Defaults to 10.
+	MultiquerySize int
+}
+
+// LookupResult is the merged result of a Lookup.
+type LookupResult struct {
+	// Items are the returned JSON objects in the order of the requested IDs.
+	// An ID requested more than once has its item repeated.
+	Items []json.RawMessage
+	// Missing are the requested IDs for which no item was returned, in the order
+	// they were requested.
+	Missing []int
+}
+
+// Lookup retrieves the items with the provided IDs from the provided url using
+// the provided method. The IDs are split into chunks that are each looked up with
+// a query built from the provided base options, a limit equal to the chunk size,
+// and a where filter matching the IDs of the chunk. The chunks are sent with bounded
+// concurrency, optionally packed into multiqueries, and their results are merged.
+// Each request is sent like Send would, following the Client's MethodPolicy and
+// Encoding, except that multiqueries are always sent in the request body.
+//
+// Items are matched to IDs by their "id" field, which is added to the fields of
+// the base options if necessary. The base options cannot set an offset.
+func (c *Client) Lookup(ctx context.Context, method, url string, ids []int, p LookupPolicy, opts ...Option) (*LookupResult, error) {
+	if blank.Is(url) {
+		return nil, ErrBlankArgument
+	}
+	if len(ids) <= 0 {
+		return nil, ErrMissingInput
+	}
+	if p.ChunkSize < 0 || p.Concurrency < 0 || p.MultiquerySize < 0 {
+		return nil, ErrNegativeInput
+	}
+
+	base, err := queryFilters(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := base["offset"]; ok {
+		return nil, errors.New("lookup base options cannot set an offset")
+	}
+
+	p, err = p.withDefaults(base)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := chunkIDs(uniqueIDs(ids), p.ChunkSize)
+	items, err := c.lookupChunks(ctx, method, url, base, chunks, p)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &LookupResult{}
+	missing := map[int]bool{}
+	for _, id := range ids {
+		if item, ok := items[id]; ok {
+			res.Items = append(res.Items, item)
+			continue
+		}
+		if !missing[id] {
+			missing[id] = true
+			res.Missing = append(res.Missing, id)
+		}
+	}
+
+	return res, nil
+}
+
+// withDefaults returns a copy of the policy with its unset values replaced by
+// their defaults.
+func (p LookupPolicy) withDefaults(base map[string]string) (LookupPolicy, error) {
+	if p.ChunkSize == 0 {
+		p.ChunkSize = defaultChunkSize
+		if l, ok := base["limit"]; ok {
+			n, err := strconv.Atoi(l)
+			if err != nil {
+				return p, errors.Wrapf(err, "cannot parse limit '%s'", l)
+			}
+			if n > 0 {
+				p.ChunkSize = n
+			}
+		}
+	}
+	if p.Concurrency == 0 {
+		p.Concurrency = defaultConcurrency
+	}
+	if p.MultiquerySize == 0 {
+		p.MultiquerySize = defaultMultiquerySize
+	}
+
+	return p, nil
+}
+
+// lookupChunks looks up every chunk of IDs and returns the returned items by ID.
+// The first error encountered cancels the remaining requests.
+func (c *Client) lookupChunks(ctx context.Context, method, url string, base map[string]string, chunks [][]int, p LookupPolicy) (map[int]json.RawMessage, error) {
+	batchSize := 1
+	if p.MultiqueryURL != "" {
+		batchSize = p.MultiquerySize
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		items    = map[int]json.RawMessage{}
+		sem      = make(chan struct{}, p.Concurrency)
+	)
+
+	for start := 0; start < len(chunks); start += batchSize {
+		end := start + batchSize
+		if end > len(chunks) {
+			end = len(chunks)
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(batch [][]int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var found []json.RawMessage
+			var err error
+			if p.MultiqueryURL != "" {
+				found, err = c.lookupMultiquery(ctx, method, p.MultiqueryURL, endpointName(url), base, batch)
+			} else {
+				found, err = c.lookupQuery(ctx, method, url, chunkFilters(base, batch[0]))
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				return
+			}
+			err = indexItems(items, found)
+			if err != nil && firstErr == nil {
+				firstErr = err
+				cancel()
+			}
+		}(chunks[start:end])
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if ctx.Err() != nil {
+		return nil, errors.Wrap(ctx.Err(), "cannot look up IDs")
+	}
+
+	return items, nil
+}
+
+// lookupQuery sends a single query and returns the items it returned. The query
+// is sent like Send would, using the Client's MethodPolicy and Encoding.
+func (c *Client) lookupQuery(ctx context.Context, method, url string, filters map[string]string) ([]json.RawMessage, error) {
+	resp, err := c.Send(ctx, method, url, withFilters(filters))
+	if err != nil {
+		return nil, err
+	}
+
+	var items []json.RawMessage
+	if err := decodeJSON(resp, &items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// lookupMultiquery packs the provided chunks into a single multiquery, sends it,
+// and returns the items returned by every subquery.
+func (c *Client) lookupMultiquery(ctx context.Context, method, url, endpoint string, base map[string]string, chunks [][]int) ([]json.RawMessage, error) {
+	subs := make([]subquery, len(chunks))
+	for i, ch := range chunks {
+		subs[i] = subquery{
+			endpoint: endpoint,
+			name:     "chunk " + strconv.Itoa(i),
+			filters:  chunkFilters(base, ch),
+		}
+	}
+
+	q, err := multiqueryString(subs)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create multiquery")
+	}
+
+	req, err := c.newMultiqueryRequest(method, url, q)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []struct {
+		Name   string            `json:"name"`
+		Result []json.RawMessage `json:"result"`
+	}
+	if err := decodeJSON(resp, &results); err != nil {
+		return nil, err
+	}
+
+	var items []json.RawMessage
+	for _, r := range results {
+		items = append(items, r.Result...)
+	}
+
+	return items, nil
+}
+
+// newMultiqueryRequest returns a request for the provided multiquery with its
+// method resolved by the Client's MethodPolicy, as Send does. A multiquery cannot
+// be written into url parameters, so it is always sent in the request body.
+func (c *Client) newMultiqueryRequest(method, url, query string) (*http.Request, error) {
+	method, enc, override, err := c.methods.method(method, BodyEncoding)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create request")
+	}
+	if enc != BodyEncoding {
+		return nil, errors.Errorf("cannot create request: multiquery cannot be sent with method '%s' without a body", method)
+	}
+
+	req, err := http.NewRequest(method, url, strings.NewReader(query))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create request with method '%s' for url '%s'", method, url)
+	}
+	if override {
+		req.Header.Set(methodOverrideHeader, http.MethodGet)
+	}
+
+	return req, nil
+}
+
+// withFilters returns an option that adds the provided filters to a query.
+func withFilters(filters map[string]string) Option {
+	return func(f map[string]string) error {
+		for k, v := range filters {
+			f[k] = v
+		}
+		return nil
+	}
+}
+
+// chunkFilters returns a copy of the base filters that looks up the provided IDs.
+func chunkFilters(base map[string]string, ids []int) map[string]string {
+	f := make(map[string]string, len(base)+2)
+	for k, v := range base {
+		f[k] = v
+	}
+
+	if fields, ok := f["fields"]; ok && !hasField(fields, "id") && !hasField(fields, "*") {
+		f["fields"] = fields + ",id"
+	}
+
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.Itoa(id)
+	}
+	w := "id = (" + strings.Join(s, ",") + ")"
+	if v, ok := f["where"]; ok {
+		w += " & (" + v + ")"
+	}
+	f["where"] = w
+	f["limit"] = strconv.Itoa(len(ids))
+
+	return f
+}
+
+// hasField reports whether the provided comma separated list contains field.
+func hasField(list, field string) bool {
+	for _, f := range strings.Split(list, ",") {
+		if strings.TrimSpace(f) == field {
+			return true
+		}
+	}
+	return false
+}
+
+// indexItems adds the provided items to the index by their "id" field.
+func indexItems(index map[int]json.RawMessage, items []json.RawMessage) error {
+	for _, item := range items {
+		var v struct {
+			ID *int `json:"id"`
+		}
+		if err := json.Unmarshal(item, &v); err != nil {
+			return errors.Wrap(err, "cannot decode item")
+		}
+		if v.ID == nil {
+			return errors.New("returned item is missing an id field")
+		}
+		index[*v.ID] = item
+	}
+
+	return nil
+}
+
+// uniqueIDs returns the provided IDs without duplicates in their original order.
+func uniqueIDs(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	var u []int
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			u = append(u, id)
+		}
+	}
+	return u
+}
+
+// chunkIDs splits the provided IDs into chunks of up to size IDs.
+func chunkIDs(ids []int, size int) [][]int {
+	var chunks [][]int
+	for len(ids) > size {
+		chunks = append(chunks, ids[:size])
+		ids = ids[size:]
+	}
+	return append(chunks, ids)
+}
+
+// endpointName returns the name of the endpoint at the provided url, which is
+// the last element of its path.
+func endpointName(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return path.Base(rawurl)
+	}
+	return path.Base(u.Path)
+}
@@ -0,0 +1,273 @@
+package apicalypse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// lookupServer returns a server that responds to lookup queries with an item for
+// every requested ID that is not listed in absent. It records each query it receives.
+func lookupServer(t *testing.T, absent map[int]bool) (*httptest.Server, *[]string) {
+	var mu sync.Mutex
+	var queries []string
+	idsPattern := regexp.MustCompile(`id = \(([0-9,]+)\)`)
+
+	items := func(q string) []map[string]int {
+		m := idsPattern.FindStringSubmatch(q)
+		if m == nil {
+			t.Errorf("got: <%v>, want: <%v>", q, "where id = (...)")
+			return nil
+		}
+		var found []map[string]int
+		for _, s := range strings.Split(m[1], ",") {
+			id, _ := strconv.Atoi(s)
+			if !absent[id] {
+				found = append(found, map[string]int{"id": id, "value": id * 10})
+			}
+		}
+		return found
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		q := string(b)
+		mu.Lock()
+		queries = append(queries, q)
+		mu.Unlock()
+
+		if !strings.HasPrefix(r.URL.Path, "/multiquery") {
+			json.NewEncoder(w).Encode(items(q))
+			return
+		}
+
+		var results []interface{}
+		for _, sub := range regexp.MustCompile(`query games "([^"]+)" \{([^}]*)\}`).FindAllStringSubmatch(q, -1) {
+			results = append(results, map[string]interface{}{"name": sub[1], "result": items(sub[2])})
+		}
+		json.NewEncoder(w).Encode(results)
+	}))
+
+	return ts, &queries
+}
+
+func TestClientLookup(t *testing.T) {
+	tests := []struct {
+		name        string
+		ids         []int
+		policy      LookupPolicy
+		opts        []Option
+		absent      map[int]bool
+		wantValues  []int
+		wantMissing []int
+		wantQueries int
+	}{
+		{"Single chunk", []int{3, 1, 2}, LookupPolicy{}, nil, nil, []int{30, 10, 20}, nil, 1},
+		{"Multiple chunks", []int{5, 4, 3, 2, 1}, LookupPolicy{ChunkSize: 2}, nil, nil, []int{50, 40, 30, 20, 10}, nil, 3},
+		{"Chunk size from limit", []int{5, 4, 3, 2, 1}, LookupPolicy{}, []Option{Limit(2)}, nil, []int{50, 40, 30, 20, 10}, nil, 3},
+		{"Missing IDs", []int{1, 2, 3, 4}, LookupPolicy{ChunkSize: 3}, nil, map[int]bool{2: true, 4: true}, []int{10, 30}, []int{2, 4}, 2},
+		{"Repeated IDs", []int{1, 2, 1, 9, 9}, LookupPolicy{ChunkSize: 2}, nil, map[int]bool{9: true}, []int{10, 20, 10}, []int{9}, 2},
+		{"Multiqueries", []int{1, 2, 3, 4, 5, 6, 7}, LookupPolicy{ChunkSize: 2, MultiquerySize: 3, MultiqueryURL: "/multiquery"}, nil, map[int]bool{6: true}, []int{10, 20, 30, 40, 50, 70}, []int{6}, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts, queries := lookupServer(t, test.absent)
+			defer ts.Close()
+
+			if test.policy.MultiqueryURL != "" {
+				test.policy.MultiqueryURL = ts.URL + test.policy.MultiqueryURL
+			}
+
+			c, err := NewClient()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := c.Lookup(context.Background(), "POST", ts.URL+"/games", test.ids, test.policy, test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var values []int
+			for _, item := range res.Items {
+				var v struct{ Value int }
+				if err := json.Unmarshal(item, &v); err != nil {
+					t.Fatal(err)
+				}
+				values = append(values, v.Value)
+			}
+
+			if !reflect.DeepEqual(values, test.wantValues) {
+				t.Errorf("got: <%v>, want: <%v>", values, test.wantValues)
+			}
+
+			if !reflect.DeepEqual(res.Missing, test.wantMissing) {
+				t.Errorf("got: <%v>, want: <%v>", res.Missing, test.wantMissing)
+			}
+
+			if len(*queries) != test.wantQueries {
+				t.Errorf("got: <%v>, want: <%v>", len(*queries), test.wantQueries)
+			}
+		})
+	}
+}
+
+func TestClientLookupErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		ids     []int
+		policy  LookupPolicy
+		opts    []Option
+		wantErr error
+	}{
+		{"Blank url", "", []int{1}, LookupPolicy{}, nil, ErrBlankArgument},
+		{"Zero IDs", ts.URL, nil, LookupPolicy{}, nil, ErrMissingInput},
+		{"Negative chunk size", ts.URL, []int{1}, LookupPolicy{ChunkSize: -1}, nil, ErrNegativeInput},
+		{"Error option", ts.URL, []int{1}, LookupPolicy{}, []Option{Limit(-1)}, ErrNegativeInput},
+		{"Offset option", ts.URL, []int{1}, LookupPolicy{}, []Option{Offset(5)}, nil},
+		{"Unsuccessful response", ts.URL, []int{1, 2, 3}, LookupPolicy{ChunkSize: 1}, nil, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := c.Lookup(context.Background(), "POST", test.url, test.ids, test.policy, test.opts...)
+			if err == nil {
+				t.Fatalf("got: <%v>, want: <%v>", err, "an error")
+			}
+
+			if test.wantErr != nil && errors.Cause(err) != test.wantErr {
+				t.Errorf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientLookupMethodPolicy(t *testing.T) {
+	var mu sync.Mutex
+	var got string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		got = r.Method + " " + r.Header.Get("X-HTTP-Method-Override") + " " + r.URL.RawQuery + " " + string(b)
+		mu.Unlock()
+		if strings.HasPrefix(r.URL.Path, "/multiquery") {
+			w.Write([]byte(`[{"name": "chunk 0", "result": [{"id": 1}]}]`))
+			return
+		}
+		w.Write([]byte(`[{"id": 1}]`))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		policy      *MethodPolicy
+		method      string
+		lookup      LookupPolicy
+		wantRequest string
+		wantErr     bool
+	}{
+		{"No policy", nil, "get", LookupPolicy{}, "GET   where id = (1); limit 1; ", false},
+		{"Method not allowed", &MethodPolicy{Allowed: []string{"POST"}}, "GET", LookupPolicy{}, "", true},
+		{"Method not allowed by specifications", nil, "delete", LookupPolicy{}, "", true},
+		{"Override fallback", &MethodPolicy{Fallback: OverrideFallback}, "GET", LookupPolicy{}, "POST GET  where id = (1); limit 1; ", false},
+		{"URL fallback", &MethodPolicy{Fallback: URLFallback}, "GET", LookupPolicy{}, "GET  where=id%20%3D%20%281%29&limit=1 ", false},
+		{"Multiquery with override fallback", &MethodPolicy{Fallback: OverrideFallback}, "GET", LookupPolicy{MultiqueryURL: "/multiquery"}, `POST GET  query games "chunk 0" { where id = (1); limit 1; }; `, false},
+		{"Multiquery with URL fallback", &MethodPolicy{Fallback: URLFallback}, "GET", LookupPolicy{MultiqueryURL: "/multiquery"}, "", true},
+		{"Multiquery method not allowed", &MethodPolicy{Allowed: []string{"POST"}}, "GET", LookupPolicy{MultiqueryURL: "/multiquery"}, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := []ClientOption{WithMethod("POST")}
+			if test.policy != nil {
+				opts = append(opts, WithMethodPolicy(*test.policy))
+			}
+
+			c, err := NewClient(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.lookup.MultiqueryURL != "" {
+				test.lookup.MultiqueryURL = ts.URL + test.lookup.MultiqueryURL
+			}
+
+			mu.Lock()
+			got = ""
+			mu.Unlock()
+
+			_, err = c.Lookup(context.Background(), test.method, ts.URL+"/games", []int{1}, test.lookup)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got: <%v>, want error: <%v>", err, test.wantErr)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if got != test.wantRequest {
+				t.Errorf("got: <%v>, want: <%v>", got, test.wantRequest)
+			}
+		})
+	}
+}
+
+func TestChunkFilters(t *testing.T) {
+	tests := []struct {
+		name string
+		base map[string]string
+		ids  []int
+		want map[string]string
+	}{
+		{"Empty base", map[string]string{}, []int{1, 2}, map[string]string{"where": "id = (1,2)", "limit": "2"}},
+		{"Fields without id", map[string]string{"fields": "name"}, []int{1}, map[string]string{"fields": "name,id", "where": "id = (1)", "limit": "1"}},
+		{"Fields with id", map[string]string{"fields": "id,name"}, []int{1}, map[string]string{"fields": "id,name", "where": "id = (1)", "limit": "1"}},
+		{"Fields with wildcard", map[string]string{"fields": "*"}, []int{1}, map[string]string{"fields": "*", "where": "id = (1)", "limit": "1"}},
+		{"Existing where", map[string]string{"where": "a = 1 | b = 2"}, []int{1}, map[string]string{"where": "id = (1) & (a = 1 | b = 2)", "limit": "1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := chunkFilters(test.base, test.ids)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got: <%v>, want: <%v>", got, test.want)
+			}
+		})
+	}
+}
+
+func TestChunkIDs(t *testing.T) {
+	tests := []struct {
+		ids  []int
+		size int
+		want [][]int
+	}{
+		{[]int{1}, 2, [][]int{{1}}},
+		{[]int{1, 2}, 2, [][]int{{1, 2}}},
+		{[]int{1, 2, 3, 4, 5}, 2, [][]int{{1, 2}, {3, 4}, {5}}},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.ids), func(t *testing.T) {
+			got := chunkIDs(test.ids, test.size)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got: <%v>, want: <%v>", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package apicalypse
+
+import (
+	"github.com/pkg/errors"
+	"net/http"
+	"strings"
+)
+
+// ErrInvalidMethod occurs when a query would be sent with an HTTP method that is
+// not allowed, either by the Apicalypse specifications or by a Client's MethodPolicy.
+var ErrInvalidMethod = errors.New("method is not allowed for queries")
+
+// methodOverrideHeader is the header that tells an API which method a request
+// sent with a different method stands for.
+const methodOverrideHeader = "X-HTTP-Method-Override"
+
+// specMethods are the methods the Apicalypse specifications allow queries to be
+// sent with. GET is recommended for the majority of cases.
+var specMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut}
+
+// normalizeMethod returns the provided method in upper case, or GET if it is
+// blank, and reports an error if the specifications do not allow it.
+func normalizeMethod(method string) (string, error) {
+	m := strings.ToUpper(strings.TrimSpace(method))
+	if m == "" {
+		return http.MethodGet, nil
+	}
+
+	if !contains(specMethods, m) {
+		return "", errors.Wrapf(ErrInvalidMethod, "cannot use method '%s'", method)
+	}
+
+	return m, nil
+}
+
+// GetFallback is the way a Client sends a query meant for GET when the body of a
+// GET request cannot be sent, as many proxies, CDNs, and HTTP libraries drop it.
+type GetFallback int
+
+const (
+	// NoFallback sends GET requests with the query in their body.
+	NoFallback GetFallback = iota
+	// OverrideFallback sends the request with POST instead, keeping the query in
+	// the body, and sets the X-HTTP-Method-Override header to GET. Use it with APIs
+	// that honor the header. A MethodPolicy with this fallback must allow POST.
+	OverrideFallback
+	// URLFallback sends the request with GET and writes the query into the url
+	// parameters as URLEncoding does.
+	URLFallback
+)
+
+// MethodPolicy configures the HTTP methods a Client sends queries with.
+type MethodPolicy struct {
+	// Allowed lists the methods the Client may send queries with. Requests with
+	// any other method fail with ErrInvalidMethod before they are sent. Defaults
+	// to GET, POST, and PUT, the methods allowed by the Apicalypse specifications;
+	// other methods cannot be allowed.
+	Allowed []string
+	// Fallback is how queries are sent with GET when the Client's transport cannot
+	// send the body of a GET request. Defaults to NoFallback.
+	Fallback GetFallback
+}
+
+// WithMethodPolicy is a functional option for setting the MethodPolicy the Client
+// uses to validate and rewrite the methods of the queries it sends.
+func WithMethodPolicy(p MethodPolicy) ClientOption {
+	return func(c *Client) error {
+		if p.Fallback < NoFallback || p.Fallback > URLFallback {
+			return errors.Errorf("unknown fallback %d", p.Fallback)
+		}
+
+		allowed := make([]string, 0, len(p.Allowed))
+		for _, m := range p.Allowed {
+			n, err := normalizeMethod(m)
+			if err != nil || strings.TrimSpace(m) == "" {
+				return errors.Wrapf(ErrInvalidMethod, "cannot allow method '%s'", m)
+			}
+			allowed = append(allowed, n)
+		}
+		if p.Fallback == OverrideFallback && len(allowed) > 0 && !contains(allowed, http.MethodPost) {
+			return errors.Wrap(ErrInvalidMethod, "cannot fall back to method 'POST' that is not allowed")
+		}
+		p.Allowed = allowed
+		c.methods = &p
+
+		return nil
+	}
+}
+
+// method returns the method a query sent with the provided method is actually
+// sent with and the encoding it is sent in, and whether the request must carry
+// the method override header.
+func (p *MethodPolicy) method(method string, enc Encoding) (string, Encoding, bool, error) {
+	m, err := normalizeMethod(method)
+	if err != nil {
+		return "", enc, false, err
+	}
+
+	if p == nil {
+		return m, enc, false, nil
+	}
+
+	if len(p.Allowed) > 0 && !contains(p.Allowed, m) {
+		return "", enc, false, errors.Wrapf(ErrInvalidMethod, "cannot use method '%s'", m)
+	}
+
+	if m != http.MethodGet || enc != BodyEncoding {
+		return m, enc, false, nil
+	}
+
+	switch p.Fallback {
+	case OverrideFallback:
+		return http.MethodPost, enc, true, nil
+	case URLFallback:
+		return m, URLEncoding, false, nil
+	}
+
+	return m, enc, false, nil
+}
+
+// contains reports whether the provided list contains s.
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
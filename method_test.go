@@ -0,0 +1,91 @@
+package apicalypse
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithMethodPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []ClientOption
+		wantErr error
+	}{
+		{"Zero policy", []ClientOption{WithMethodPolicy(MethodPolicy{})}, nil},
+		{"Allowed methods", []ClientOption{WithMethodPolicy(MethodPolicy{Allowed: []string{"post", "GET"}})}, nil},
+		{"Unknown method", []ClientOption{WithMethodPolicy(MethodPolicy{Allowed: []string{"DELETE"}})}, ErrInvalidMethod},
+		{"Blank method", []ClientOption{WithMethodPolicy(MethodPolicy{Allowed: []string{" "}})}, ErrInvalidMethod},
+		{"Default method not allowed", []ClientOption{WithMethodPolicy(MethodPolicy{Allowed: []string{"POST"}})}, ErrInvalidMethod},
+		{"Client method allowed", []ClientOption{WithMethod("POST"), WithMethodPolicy(MethodPolicy{Allowed: []string{"POST"}})}, nil},
+		{"Client method not allowed", []ClientOption{WithMethod("DELETE")}, ErrInvalidMethod},
+		{"Override fallback without POST", []ClientOption{WithMethodPolicy(MethodPolicy{Allowed: []string{"GET"}, Fallback: OverrideFallback})}, ErrInvalidMethod},
+		{"Override fallback with POST", []ClientOption{WithMethodPolicy(MethodPolicy{Allowed: []string{"GET", "POST"}, Fallback: OverrideFallback})}, nil},
+		{"URL fallback without POST", []ClientOption{WithMethodPolicy(MethodPolicy{Allowed: []string{"GET"}, Fallback: URLFallback})}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewClient(test.opts...)
+			if errors.Cause(err) != test.wantErr {
+				t.Errorf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientSendMethodPolicy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(r.Method + " " + r.Header.Get("X-HTTP-Method-Override") + " " + r.URL.RawQuery + " " + string(b)))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		policy   *MethodPolicy
+		method   string
+		wantBody string
+		wantErr  error
+	}{
+		{"No policy", nil, "", "GET   limit 5; ", nil},
+		{"No fallback", &MethodPolicy{}, "get", "GET   limit 5; ", nil},
+		{"Override fallback", &MethodPolicy{Fallback: OverrideFallback}, "GET", "POST GET  limit 5; ", nil},
+		{"Override fallback with POST", &MethodPolicy{Fallback: OverrideFallback}, "POST", "POST   limit 5; ", nil},
+		{"URL fallback", &MethodPolicy{Fallback: URLFallback}, "", "GET  limit=5 ", nil},
+		{"Method not allowed", &MethodPolicy{Allowed: []string{"GET"}}, "PUT", "", ErrInvalidMethod},
+		{"Method not allowed by specifications", nil, "PATCH", "", ErrInvalidMethod},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var opts []ClientOption
+			if test.policy != nil {
+				opts = append(opts, WithMethodPolicy(*test.policy))
+			}
+			c, err := NewClient(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := c.Send(context.Background(), test.method, ts.URL, Limit(5))
+			if errors.Cause(err) != test.wantErr {
+				t.Fatalf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+
+			b, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != test.wantBody {
+				t.Errorf("got: <%v>, want: <%v>", string(b), test.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package apicalypse
+
+import (
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Middleware wraps the http.RoundTripper a Client sends requests with, so that
+// every request and response passes through it. Middleware is where concerns
+// shared by every query belong, such as logging, authentication, metrics, header
+// injection, and query rewriting. Middleware must not modify the request it is
+// given; use Clone to send a modified copy instead.
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// RoundTripFunc is an adapter that allows an ordinary function to be used as an
+// http.RoundTripper, which is convenient when writing Middleware.
+type RoundTripFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req).
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// WithMiddleware is a functional option for adding Middleware to the transport of
+// the Client's HTTP client. The first Middleware is the outermost, so it sees each
+// request first and each response last. Middleware from repeated uses of this
+// option is chained in the order the options are provided. The HTTP client set by
+// WithHTTPClient is not modified.
+func WithMiddleware(mw ...Middleware) ClientOption {
+	return func(c *Client) error {
+		for _, m := range mw {
+			if m == nil {
+				return ErrMissingInput
+			}
+		}
+		c.middleware = append(c.middleware, mw...)
+
+		return nil
+	}
+}
+
+// Chain returns the provided transport wrapped in the provided Middleware, with
+// the first Middleware outermost. If the transport is nil, http.DefaultTransport
+// is used. Chain allows Middleware to be used with requests sent without a Client.
+func Chain(rt http.RoundTripper, mw ...Middleware) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	for i := len(mw) - 1; i >= 0; i-- {
+		rt = mw[i](rt)
+	}
+
+	return rt
+}
+
+// RequestClauses returns the clauses of the query carried by the provided request,
+// keyed by clause keyword as in "limit" to "10", whether the query is written in
+// the request's body or in its url parameters. The request's body is not consumed,
+// so it must be empty or rewindable with GetBody, as it is for requests created by
+// NewRequest.
+func RequestClauses(req *http.Request) (map[string]string, error) {
+	q, ok := queryText(req)
+	if !ok {
+		return nil, errors.New("cannot read query from request body")
+	}
+
+	if strings.TrimSpace(q) != "" {
+		return parseFilters(q)
+	}
+
+	filters := map[string]string{}
+	params := req.URL.Query()
+	for _, k := range clauseOrder {
+		vals, ok := params[k]
+		if !ok {
+			continue
+		}
+		if len(vals) > 1 {
+			return nil, errors.Errorf("clause '%s' is repeated", k)
+		}
+		filters[k] = vals[0]
+	}
+
+	return filters, nil
+}
+
+// Exchange is a single round trip of a query observed by Middleware created with
+// Observe.
+type Exchange struct {
+	// Request is the request that was sent.
+	Request *http.Request
+	// Clauses are the clauses of the request's query as returned by RequestClauses,
+	// or nil if they could not be read.
+	Clauses map[string]string
+	// Response is the response that was received, if any. Its body has not been
+	// read and must not be read by the observer.
+	Response *http.Response
+	// Err is the error that caused the round trip to fail, if any.
+	Err error
+	// Duration is how long the round trip took until the response headers were
+	// received.
+	Duration time.Duration
+}
+
+// Observe returns Middleware that calls fn after every round trip with the query
+// and the HTTP exchange. Use it for logging and metrics.
+func Observe(fn func(Exchange)) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			clauses, _ := RequestClauses(req)
+			start := time.Now()
+
+			resp, err := next.RoundTrip(req)
+			fn(Exchange{
+				Request:  req,
+				Clauses:  clauses,
+				Response: resp,
+				Err:      err,
+				Duration: time.Since(start),
+			})
+
+			return resp, err
+		})
+	}
+}
+
+// SetHeader returns Middleware that sets the provided header on every request,
+// replacing any value it already has.
+func SetHeader(key, value string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			r := req.Clone(req.Context())
+			r.Header.Set(key, value)
+			return next.RoundTrip(r)
+		})
+	}
+}
+
+// RewriteQuery returns Middleware that applies the provided options to the query
+// of every request before it is sent, such as to add default fields or to clamp
+// the limit. The options see the clauses already in the query and may change or
+// delete them. A query written in the request's url parameters is rewritten there;
+// any other query, including an empty one, is written to the request's body.
+// Requests whose body cannot be read or does not hold a plain query, such as a
+// multiquery, are sent unchanged.
+func RewriteQuery(opts ...Option) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			r, err := rewriteQuery(req, opts)
+			if err != nil {
+				if req.Body != nil {
+					req.Body.Close()
+				}
+				return nil, err
+			}
+
+			return next.RoundTrip(r)
+		})
+	}
+}
+
+// rewriteQuery returns a copy of the provided request with the provided options
+// applied to its query.
+func rewriteQuery(req *http.Request, opts []Option) (*http.Request, error) {
+	filters, err := RequestClauses(req)
+	if err != nil {
+		return req, nil
+	}
+	for k := range filters {
+		if clauseIndex(k) < 0 {
+			return req, nil
+		}
+	}
+
+	for _, opt := range opts {
+		if opt == nil {
+			return nil, errors.New("a provided option is nil")
+		}
+		if err := opt(filters); err != nil {
+			return nil, errors.Wrap(err, "cannot rewrite query")
+		}
+	}
+
+	r := req.Clone(req.Context())
+	if inURL(req) {
+		r.URL.RawQuery = encodeParams(req.URL.RawQuery, filters, func(k string) bool {
+			return clauseIndex(k) >= 0
+		})
+		return r, nil
+	}
+
+	q := toString(filters)
+	r.GetBody = func() (io.ReadCloser, error) {
+		if q == "" {
+			return http.NoBody, nil
+		}
+		return ioutil.NopCloser(strings.NewReader(q)), nil
+	}
+	r.Body, _ = r.GetBody()
+	r.ContentLength = int64(len(q))
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	return r, nil
+}
+
+// inURL reports whether the query of the provided request is written in its url
+// parameters rather than its body.
+func inURL(req *http.Request) bool {
+	if q, _ := queryText(req); strings.TrimSpace(q) != "" {
+		return false
+	}
+
+	params := req.URL.Query()
+	for _, k := range clauseOrder {
+		if _, ok := params[k]; ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,267 @@
+package apicalypse
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// echoTransport responds to every request with its method, url query, X-Trace
+// header, and body.
+func echoTransport(req *http.Request) (*http.Response, error) {
+	var b []byte
+	if req.Body != nil {
+		b, _ = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+	}
+
+	s := req.Method + "|" + req.URL.RawQuery + "|" + req.Header.Get("X-Trace") + "|" + string(b)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader(s)),
+		Request:    req,
+	}, nil
+}
+
+// readBody reads and closes the body of the provided response.
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(b)
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, name+" request")
+				resp, err := next.RoundTrip(req)
+				calls = append(calls, name+" response")
+				return resp, err
+			})
+		}
+	}
+
+	rt := Chain(RoundTripFunc(echoTransport), trace("first"), trace("second"))
+
+	req, err := NewRequest("GET", "http://fake.com/", Limit(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	want := []string{"first request", "second request", "second response", "first response"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("got: <%v>, want: <%v>", calls, want)
+	}
+
+	if Chain(nil) != http.DefaultTransport {
+		t.Errorf("got: <%v>, want: <%v>", Chain(nil), http.DefaultTransport)
+	}
+}
+
+func TestWithMiddleware(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(r.Header.Get("X-Trace") + "|" + string(b)))
+	}))
+	defer ts.Close()
+
+	hc := &http.Client{}
+	c, err := NewClient(
+		WithHTTPClient(hc),
+		WithMiddleware(SetHeader("X-Trace", "abc")),
+		WithMiddleware(RewriteQuery(Limit(10))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := c.Send(context.Background(), "POST", ts.URL, Fields("name"), Limit(500))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "abc|fields name; limit 10; "
+	if got := readBody(t, resp); got != want {
+		t.Errorf("got: <%v>, want: <%v>", got, want)
+	}
+
+	if hc.Transport != nil {
+		t.Errorf("got: <%v>, want: <%v>", hc.Transport, nil)
+	}
+
+	if _, err := NewClient(WithMiddleware(nil)); errors.Cause(err) != ErrMissingInput {
+		t.Errorf("got: <%v>, want: <%v>", err, ErrMissingInput)
+	}
+}
+
+func TestRequestClauses(t *testing.T) {
+	body, err := NewRequest("POST", "http://fake.com/?limit=1", Fields("name"), Where("id = 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params, err := NewEncodedRequest("GET", "http://fake.com/?key=abc", URLEncoding, Fields("name"), Limit(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unreadable, err := http.NewRequest("POST", "http://fake.com/", ioutil.NopCloser(strings.NewReader("limit 5;")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repeated, err := http.NewRequest("GET", "http://fake.com/?limit=1&limit=2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		req         *http.Request
+		wantClauses map[string]string
+		wantErr     bool
+	}{
+		{"Body", body, map[string]string{"fields": "name", "where": "id = 1"}, false},
+		{"URL parameters", params, map[string]string{"fields": "name", "limit": "5"}, false},
+		{"Unreadable body", unreadable, nil, true},
+		{"Repeated parameter", repeated, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := RequestClauses(test.req)
+			if (err != nil) != test.wantErr {
+				t.Errorf("got: <%v>, want error: <%v>", err, test.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, test.wantClauses) {
+				t.Errorf("got: <%v>, want: <%v>", got, test.wantClauses)
+			}
+		})
+	}
+}
+
+func TestRewriteQuery(t *testing.T) {
+	clamp := func(max int) Option {
+		return func(filters map[string]string) error {
+			n, err := strconv.Atoi(filters["limit"])
+			if err != nil || n > max {
+				filters["limit"] = strconv.Itoa(max)
+			}
+			return nil
+		}
+	}
+	defaultFields := func(filters map[string]string) error {
+		if _, ok := filters["fields"]; !ok {
+			filters["fields"] = "*"
+		}
+		return nil
+	}
+	noSort := func(filters map[string]string) error {
+		delete(filters, "sort")
+		return nil
+	}
+
+	tests := []struct {
+		name     string
+		enc      Encoding
+		url      string
+		body     string
+		opts     []Option
+		wantBody string
+	}{
+		{"Body query", BodyEncoding, "http://fake.com/", "", []Option{Limit(500), Sort("name", "asc")}, "POST|||fields *; limit 50; "},
+		{"Empty query", BodyEncoding, "http://fake.com/", "", nil, "POST|||fields *; limit 50; "},
+		{"URL query", URLEncoding, "http://fake.com/?key=abc", "", []Option{Fields("name"), Sort("name", "asc")}, "POST|key=abc&fields=name&limit=50||"},
+		{"Multiquery", BodyEncoding, "http://fake.com/", `query games "a" { limit 500; };`, nil, `POST|||query games "a" { limit 500; };`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rt := Chain(RoundTripFunc(echoTransport), RewriteQuery(clamp(50), defaultFields, noSort))
+
+			req, err := NewEncodedRequest("POST", test.url, test.enc, test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.body != "" {
+				req, err = http.NewRequest("POST", test.url, strings.NewReader(test.body))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := readBody(t, resp); got != test.wantBody {
+				t.Errorf("got: <%v>, want: <%v>", got, test.wantBody)
+			}
+		})
+	}
+}
+
+func TestRewriteQueryError(t *testing.T) {
+	rt := Chain(RoundTripFunc(echoTransport), RewriteQuery(Limit(-1)))
+
+	req, err := NewRequest("POST", "http://fake.com/", Limit(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rt.RoundTrip(req); errors.Cause(err) != ErrNegativeInput {
+		t.Errorf("got: <%v>, want: <%v>", err, ErrNegativeInput)
+	}
+}
+
+func TestObserve(t *testing.T) {
+	var got []Exchange
+	rt := Chain(RoundTripFunc(echoTransport), Observe(func(e Exchange) {
+		got = append(got, e)
+	}))
+
+	req, err := NewRequest("POST", "http://fake.com/", Fields("name"), Limit(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if len(got) != 1 {
+		t.Fatalf("got: <%v>, want: <%v>", len(got), 1)
+	}
+
+	want := map[string]string{"fields": "name", "limit": "5"}
+	if !reflect.DeepEqual(got[0].Clauses, want) {
+		t.Errorf("got: <%v>, want: <%v>", got[0].Clauses, want)
+	}
+
+	if got[0].Request != req || got[0].Response != resp || got[0].Err != nil {
+		t.Errorf("got: <%v>, want request <%v> and response <%v>", got[0], req, resp)
+	}
+}
@@ -0,0 +1,68 @@
+package apicalypse
+
+import (
+	"github.com/Henry-Sarabia/blank"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// Subquery is a single named query within a multiquery.
+type Subquery struct {
+	// Endpoint is the endpoint the subquery is sent to (e.g. "games" or "games/count").
+	Endpoint string
+	// Name identifies the results of the subquery in the multiquery response.
+	Name string
+	// Options are the functional options that set the filters of the subquery.
+	Options []Option
+}
+
+// Multiquery processes the provided subqueries into a single Apicalypse compliant
+// multiquery and returns it as a string. Each subquery must have a unique name.
+// The string is ready to be written into the body of an HTTP Request to an API's
+// multiquery endpoint.
+func Multiquery(subs ...Subquery) (string, error) {
+	if len(subs) <= 0 {
+		return "", ErrMissingInput
+	}
+
+	queries := make([]subquery, len(subs))
+	for i, s := range subs {
+		filters, err := queryFilters(s.Options...)
+		if err != nil {
+			return "", errors.Wrapf(err, "cannot create filter map for subquery '%s'", s.Name)
+		}
+		queries[i] = subquery{endpoint: s.Endpoint, name: s.Name, filters: filters}
+	}
+
+	return multiqueryString(queries)
+}
+
+// subquery is a subquery whose options have already been processed into filters.
+type subquery struct {
+	endpoint string
+	name     string
+	filters  map[string]string
+}
+
+// multiqueryString returns the provided subqueries as a single multiquery string.
+func multiqueryString(subs []subquery) (string, error) {
+	names := map[string]bool{}
+	b := strings.Builder{}
+
+	for _, s := range subs {
+		if blank.Is(s.endpoint) || blank.Is(s.name) {
+			return "", ErrBlankArgument
+		}
+		if strings.ContainsRune(s.name, '"') {
+			return "", errors.Errorf("subquery name '%s' cannot contain quotes", s.name)
+		}
+		if names[s.name] {
+			return "", errors.Errorf("subquery name '%s' is repeated", s.name)
+		}
+		names[s.name] = true
+
+		b.WriteString("query " + s.endpoint + ` "` + s.name + `" { ` + toString(s.filters) + "}; ")
+	}
+
+	return b.String(), nil
+}
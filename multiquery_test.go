@@ -0,0 +1,49 @@
+package apicalypse
+
+import (
+	"github.com/pkg/errors"
+	"testing"
+)
+
+func TestMultiquery(t *testing.T) {
+	tests := []struct {
+		name      string
+		subs      []Subquery
+		want      string
+		wantErr   error
+		wantError bool
+	}{
+		{"Zero subqueries", nil, "", ErrMissingInput, true},
+		{"Single subquery", []Subquery{{"games", "Top Games", []Option{Fields("name"), Limit(5)}}}, `query games "Top Games" { fields name; limit 5; }; `, nil, false},
+		{
+			"Multiple subqueries",
+			[]Subquery{{"games", "Games", []Option{Fields("name")}}, {"games/count", "Count", []Option{Where("rating > 80")}}},
+			`query games "Games" { fields name; }; query games/count "Count" { where rating > 80; }; `,
+			nil,
+			false,
+		},
+		{"Subquery without options", []Subquery{{"platforms", "All", nil}}, `query platforms "All" { }; `, nil, false},
+		{"Blank endpoint", []Subquery{{" ", "Games", nil}}, "", ErrBlankArgument, true},
+		{"Blank name", []Subquery{{"games", "", nil}}, "", ErrBlankArgument, true},
+		{"Quoted name", []Subquery{{"games", `"Games"`, nil}}, "", nil, true},
+		{"Repeated name", []Subquery{{"games", "Games", nil}, {"platforms", "Games", nil}}, "", nil, true},
+		{"Error option", []Subquery{{"games", "Games", []Option{Limit(-1)}}}, "", ErrNegativeInput, true},
+		{"Nil option", []Subquery{{"games", "Games", []Option{nil}}}, "", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Multiquery(test.subs...)
+			if (err != nil) != test.wantError {
+				t.Fatalf("got: <%v>, want error: <%v>", err, test.wantError)
+			}
+
+			if test.wantErr != nil && errors.Cause(err) != test.wantErr {
+				t.Errorf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+
+			if got != test.want {
+				t.Errorf("got: <%v>, want: <%v>", got, test.want)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/pkg/errors"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
 var (
@@ -14,6 +15,10 @@ var (
 	ErrBlankArgument = errors.New("a provided argument is blank or empty")
 	// ErrNegativeInput occurs when a function is called with a negative number that should not be negative.
 	ErrNegativeInput = errors.New("input cannot be a negative number")
+	// ErrInvalidOrder occurs when a sort order is neither Asc nor Desc.
+	ErrInvalidOrder = errors.New("sort order must be asc or desc")
+	// ErrInvalidFieldPath occurs when a field path is not made of field names separated by dots (e.g. "cover.url").
+	ErrInvalidFieldPath = errors.New("field path is not valid")
 )
 
 // Option is a functional option type used to set the filters for an API query.
@@ -93,11 +98,12 @@ func Where(custom ...string) Option {
 			}
 		}
 
+		terms := custom
 		if f, ok := filters["where"]; ok {
-			custom = append(custom, f)
+			terms = append(terms[:len(terms):len(terms)], f)
 		}
 
-		j := strings.Join(custom, " & ")
+		j := strings.Join(terms, " & ")
 		filters["where"] = j
 
 		return nil
@@ -105,7 +111,7 @@ func Where(custom ...string) Option {
 }
 
 // Limit is a functional option for setting the number of items to return from a query.
-// This usually has a maximum limit.
+// This usually has a maximum limit, such as 500 for IGDB, which a Profile can enforce.
 func Limit(n int) Option {
 	return func(filters map[string]string) error {
 		if n < 0 {
@@ -129,19 +135,115 @@ func Offset(n int) Option {
 	}
 }
 
+// Order is the direction results are sorted in.
+type Order string
+
+// The orders results can be sorted in.
+const (
+	// Asc sorts results in ascending order.
+	Asc Order = "asc"
+	// Desc sorts results in descending order.
+	Desc Order = "desc"
+)
+
+// SortKey is a field to sort results by and the order to sort them in.
+type SortKey struct {
+	Field string
+	Order Order
+}
+
+// String returns the key in the form "field order".
+func (k SortKey) String() string {
+	return k.Field + " " + string(k.Order)
+}
+
+// validate checks that the key's field is a valid path and its order is Asc or Desc.
+func (k SortKey) validate() error {
+	if blank.Is(k.Field) || blank.Is(string(k.Order)) {
+		return ErrBlankArgument
+	}
+	if !validPath(k.Field) {
+		return errors.Wrapf(ErrInvalidFieldPath, "'%s'", k.Field)
+	}
+	if k.Order != Asc && k.Order != Desc {
+		return errors.Wrapf(ErrInvalidOrder, "'%s'", k.Order)
+	}
+
+	return nil
+}
+
+// validPath reports whether the provided path is made of field names separated by
+// dots, where each name is made of letters, digits, and underscores.
+func validPath(path string) bool {
+	for _, name := range strings.Split(path, ".") {
+		if name == "" {
+			return false
+		}
+		for _, r := range name {
+			if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 // Sort is a functional option for sorting the results of a query by a certain field's
-// values and the use of "asc" or "desc" to sort by ascending or descending order.
-func Sort(field, order string) Option {
+// values in ascending (Asc) or descending (Desc) order. The field must be a path such as
+// "rating" or "cover.width". Sort replaces any previous sort; use SortBy to sort by
+// multiple fields.
+func Sort(field string, order Order) Option {
+	return SortBy(SortKey{Field: field, Order: order})
+}
+
+// SortBy is a functional option for sorting the results of a query by multiple fields.
+// Results are sorted by the first key, with ties broken by the following keys in order.
+// Not every API supports more than one key; a Profile can reject them.
+func SortBy(keys ...SortKey) Option {
 	return func(filters map[string]string) error {
-		if blank.Is(field) || blank.Is(order) {
-			return ErrBlankArgument
+		if len(keys) <= 0 {
+			return ErrMissingInput
+		}
+
+		s := make([]string, len(keys))
+		for i, k := range keys {
+			if err := k.validate(); err != nil {
+				return err
+			}
+			s[i] = k.String()
 		}
 
-		filters["sort"] = field + " " + order
+		filters["sort"] = strings.Join(s, ", ")
 		return nil
 	}
 }
 
+// ParseSort returns the keys of the provided sort clause value written in
+// Apicalypse syntax, such as "rating desc, name". Each key is a field optionally
+// followed by an order; keys without an order are sorted in ascending order.
+// A blank key fails with ErrBlankArgument. The fields and orders themselves are
+// checked by SortBy.
+func ParseSort(value string) ([]SortKey, error) {
+	var keys []SortKey
+	for _, k := range strings.Split(value, ",") {
+		f := strings.Fields(k)
+		var key SortKey
+		switch len(f) {
+		case 0:
+			return nil, errors.Wrapf(ErrBlankArgument, "blank sort key in '%s'", value)
+		case 1:
+			key = SortKey{Field: f[0], Order: Asc}
+		case 2:
+			key = SortKey{Field: f[0], Order: Order(f[1])}
+		default:
+			return nil, errors.Errorf("invalid sort key '%s': want a field optionally followed by an order", strings.TrimSpace(k))
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
 // Search is a functional option for searching for a value in a particular column of data.
 // If the column is omitted, search will be performed on the default column.
 func Search(column, term string) Option {
@@ -158,3 +260,44 @@ func Search(column, term string) Option {
 		return nil
 	}
 }
+
+// ParseClause returns the functional option that sets the named clause to the
+// provided value written in Apicalypse syntax. For example, ParseClause("limit", "10")
+// returns the equivalent of Limit(10) and ParseClause("search", `name "halo"`) returns
+// the equivalent of Search("name", "halo"). This allows clauses written as text, such
+// as in configuration files or user input, to be validated by the same rules as the
+// functional options themselves.
+func ParseClause(keyword, value string) (Option, error) {
+	switch keyword {
+	case "fields":
+		return Fields(strings.Split(value, ",")...), nil
+	case "exclude":
+		return Exclude(strings.Split(value, ",")...), nil
+	case "where":
+		return Where(value), nil
+	case "limit", "offset":
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return nil, errors.Errorf("'%s' is not an integer", value)
+		}
+		if keyword == "limit" {
+			return Limit(n), nil
+		}
+		return Offset(n), nil
+	case "sort":
+		keys, err := ParseSort(value)
+		if err != nil {
+			return nil, err
+		}
+		return SortBy(keys...), nil
+	case "search":
+		value = strings.TrimSpace(value)
+		i := strings.Index(value, `"`)
+		if i < 0 || !strings.HasSuffix(value, `"`) || len(value)-i < 2 {
+			return nil, errors.New("search term must be quoted")
+		}
+		return Search(strings.TrimSpace(value[:i]), value[i+1:len(value)-1]), nil
+	}
+
+	return nil, errors.Errorf("unknown clause '%s'", keyword)
+}
@@ -137,6 +137,22 @@ func TestWhere(t *testing.T) {
 	}
 }
 
+func TestWhereReuse(t *testing.T) {
+	opt := Where("a = 1")
+
+	for i := 0; i < 3; i++ {
+		filters := map[string]string{"where": "b = 2"}
+		if err := opt(filters); err != nil {
+			t.Fatal(err)
+		}
+
+		want := "a = 1 & b = 2"
+		if filters["where"] != want {
+			t.Errorf("got: <%v>, want: <%v>", filters["where"], want)
+		}
+	}
+}
+
 func TestLimit(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -203,14 +219,20 @@ func TestSort(t *testing.T) {
 	tests := []struct {
 		name     string
 		field    string
-		order    string
+		order    Order
 		wantSort string
 		wantErr  error
 	}{
-		{"Non-empty field and non-empty order", "b.count", "desc", "b.count desc", nil},
+		{"Non-empty field and non-empty order", "b.count", Desc, "b.count desc", nil},
+		{"Ascending order", "rating", Asc, "rating asc", nil},
 		{"Non-empty field and empty order", "b.count", " ", "", ErrBlankArgument},
-		{"Empty field and non-empty order", "", "desc", "", ErrBlankArgument},
+		{"Empty field and non-empty order", "", Desc, "", ErrBlankArgument},
 		{"Empty field and empty order", "", "", "", ErrBlankArgument},
+		{"Invalid order", "rating", "descending", "", ErrInvalidOrder},
+		{"Uppercase order", "rating", "DESC", "", ErrInvalidOrder},
+		{"Wildcard field", "*", Asc, "", ErrInvalidFieldPath},
+		{"Empty path segment", "cover..width", Asc, "", ErrInvalidFieldPath},
+		{"Field with space", "first release", Asc, "", ErrInvalidFieldPath},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -221,7 +243,35 @@ func TestSort(t *testing.T) {
 
 			err = Sort(test.field, test.order)(filters)
 
-			if !reflect.DeepEqual(err, test.wantErr) {
+			if errors.Cause(err) != test.wantErr {
+				t.Errorf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+
+			if filters["sort"] != test.wantSort {
+				t.Errorf("got: <%v>, want: <%v>", filters["sort"], test.wantSort)
+			}
+		})
+	}
+}
+
+func TestSortBy(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     []SortKey
+		wantSort string
+		wantErr  error
+	}{
+		{"Single key", []SortKey{{"rating", Desc}}, "rating desc", nil},
+		{"Multiple keys", []SortKey{{"rating", Desc}, {"name", Asc}, {"cover.width", Asc}}, "rating desc, name asc, cover.width asc", nil},
+		{"Invalid second key", []SortKey{{"rating", Desc}, {"name", "up"}}, "", ErrInvalidOrder},
+		{"No keys", nil, "", ErrMissingInput},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filters := map[string]string{}
+
+			err := SortBy(test.keys...)(filters)
+			if errors.Cause(err) != test.wantErr {
 				t.Errorf("got: <%v>, want: <%v>", err, test.wantErr)
 			}
 
@@ -265,6 +315,91 @@ func TestSearch(t *testing.T) {
 	}
 }
 
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []SortKey
+		wantErr bool
+	}{
+		{"Single key", "rating desc", []SortKey{{"rating", Desc}}, false},
+		{"Default order", " rating ", []SortKey{{"rating", Asc}}, false},
+		{"Multiple keys", "rating desc,name, cover.width asc", []SortKey{{"rating", Desc}, {"name", Asc}, {"cover.width", Asc}}, false},
+		{"Unchecked order", "rating descending", []SortKey{{"rating", "descending"}}, false},
+		{"Extra words", "rating desc extra", nil, true},
+		{"Blank key", "rating desc,", nil, true},
+		{"Blank value", "", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseSort(test.value)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got: <%v>, want error: <%v>", err, test.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got: <%v>, want: <%v>", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseClause(t *testing.T) {
+	tests := []struct {
+		name        string
+		keyword     string
+		value       string
+		wantFilters map[string]string
+		wantErr     error
+		wantError   bool
+	}{
+		{"Fields", "fields", "name,rating", map[string]string{"fields": "name,rating"}, nil, false},
+		{"Blank field", "fields", "name,,rating", map[string]string{}, ErrBlankArgument, true},
+		{"Exclude", "exclude", "url", map[string]string{"exclude": "url"}, nil, false},
+		{"Where", "where", "rating > 80", map[string]string{"where": "rating > 80"}, nil, false},
+		{"Limit", "limit", " 10 ", map[string]string{"limit": "10"}, nil, false},
+		{"Negative limit", "limit", "-10", map[string]string{}, ErrNegativeInput, true},
+		{"Non-integer limit", "limit", "ten", nil, nil, true},
+		{"Offset", "offset", "20", map[string]string{"offset": "20"}, nil, false},
+		{"Sort", "sort", "rating desc", map[string]string{"sort": "rating desc"}, nil, false},
+		{"Sort without order", "sort", "rating", map[string]string{"sort": "rating asc"}, nil, false},
+		{"Multiple sort keys without order", "sort", "rating desc, name", map[string]string{"sort": "rating desc, name asc"}, nil, false},
+		{"Sort key with extra words", "sort", "rating desc extra", nil, nil, true},
+		{"Blank sort key", "sort", "rating desc,", nil, nil, true},
+		{"Multiple sort keys", "sort", "rating desc, name asc", map[string]string{"sort": "rating desc, name asc"}, nil, false},
+		{"Invalid sort order", "sort", "rating descending", map[string]string{}, ErrInvalidOrder, true},
+		{"Search with column", "search", `name "halo"`, map[string]string{"search": `name "halo"`}, nil, false},
+		{"Search without column", "search", `"halo"`, map[string]string{"search": `"halo"`}, nil, false},
+		{"Unquoted search", "search", "halo", nil, nil, true},
+		{"Unknown clause", "order", "rating", nil, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opt, err := ParseClause(test.keyword, test.value)
+			if err != nil {
+				if !test.wantError || test.wantFilters != nil {
+					t.Fatalf("got: <%v>, want error: <%v>", err, test.wantError)
+				}
+				return
+			}
+
+			filters := map[string]string{}
+			err = opt(filters)
+			if (err != nil) != test.wantError {
+				t.Fatalf("got: <%v>, want error: <%v>", err, test.wantError)
+			}
+
+			if test.wantErr != nil && errors.Cause(err) != test.wantErr {
+				t.Errorf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+
+			if !reflect.DeepEqual(filters, test.wantFilters) {
+				t.Errorf("got: <%v>, want: <%v>", filters, test.wantFilters)
+			}
+		})
+	}
+}
+
 func ExampleComposeOptions() {
 	// Composing FuncOptions to filter out unpopular results
 	composedOpts := ComposeOptions(
@@ -416,16 +551,16 @@ func ExampleOffset() {
 
 func ExampleSort() {
 	// Retrieve the most popular games
-	req, _ := NewRequest("GET", "https://some-internet-game-database-api/games/", Sort("popularity", "desc"))
+	req, _ := NewRequest("GET", "https://some-internet-game-database-api/games/", Sort("popularity", Desc))
 
 	// Retrieve the least popular games
-	req, _ = NewRequest("GET", "https://some-internet-game-database-api/games/", Sort("popularity", "asc"))
+	req, _ = NewRequest("GET", "https://some-internet-game-database-api/games/", Sort("popularity", Asc))
 
 	// Retrieve the earliest released games by their first release date
-	req, _ = NewRequest("GET", "https://some-internet-game-database-api/games/", Sort("first_release_date", "asc"))
+	req, _ = NewRequest("GET", "https://some-internet-game-database-api/games/", Sort("first_release_date", Asc))
 
 	// Retrieve games with the latest release date
-	req, _ = NewRequest("GET", "https://some-internet-game-database-api/games/", Sort("first_release_date", "desc"))
+	req, _ = NewRequest("GET", "https://some-internet-game-database-api/games/", Sort("first_release_date", Desc))
 
 	http.DefaultClient.Do(req)
 }
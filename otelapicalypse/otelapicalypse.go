@@ -0,0 +1,140 @@
+// Package otelapicalypse adapts OpenTelemetry tracers and meters to the Tracer
+// and Meter interfaces of the apicalypse package, so that the requests of an
+// apicalypse.Client can be traced and measured with apicalypse.Instrument.
+//
+// It is a separate module so that the apicalypse package does not depend on
+// OpenTelemetry.
+package otelapicalypse
+
+import (
+	"context"
+	"fmt"
+	"github.com/Henry-Sarabia/apicalypse"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer returns an apicalypse.Tracer that starts client spans with the provided
+// OpenTelemetry tracer.
+func Tracer(t trace.Tracer) apicalypse.Tracer {
+	return tracer{t}
+}
+
+// tracer is an apicalypse.Tracer backed by an OpenTelemetry tracer.
+type tracer struct {
+	tracer trace.Tracer
+}
+
+// Start starts a client span as a child of any span in the provided context.
+func (t tracer) Start(ctx context.Context, name string) (context.Context, apicalypse.Span) {
+	ctx, s := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, span{s}
+}
+
+// span is an apicalypse.Span backed by an OpenTelemetry span.
+type span struct {
+	span trace.Span
+}
+
+// SetAttributes sets the provided attributes on the span.
+func (s span) SetAttributes(attrs ...apicalypse.Attribute) {
+	s.span.SetAttributes(keyValues(attrs)...)
+}
+
+// RecordError records the provided error on the span and sets the span's status
+// to Error.
+func (s span) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+// End ends the span.
+func (s span) End() {
+	s.span.End()
+}
+
+// Meter returns an apicalypse.Meter whose instruments are created with the
+// provided OpenTelemetry meter. Instruments that cannot be created are reported
+// to the global OpenTelemetry error handler and replaced by instruments that do
+// nothing.
+func Meter(m metric.Meter) apicalypse.Meter {
+	return meter{m}
+}
+
+// meter is an apicalypse.Meter backed by an OpenTelemetry meter.
+type meter struct {
+	meter metric.Meter
+}
+
+// Counter returns an OpenTelemetry Int64Counter with the provided name.
+func (m meter) Counter(name string) apicalypse.Counter {
+	c, err := m.meter.Int64Counter(name, metric.WithUnit("{request}"))
+	if err != nil {
+		otel.Handle(err)
+		c = noop.Int64Counter{}
+	}
+	return counter{c}
+}
+
+// Histogram returns an OpenTelemetry Float64Histogram with the provided name.
+// The histogram of apicalypse.MetricRequestDuration is measured in seconds.
+func (m meter) Histogram(name string) apicalypse.Histogram {
+	var opts []metric.Float64HistogramOption
+	if name == apicalypse.MetricRequestDuration {
+		opts = append(opts, metric.WithUnit("s"))
+	}
+
+	h, err := m.meter.Float64Histogram(name, opts...)
+	if err != nil {
+		otel.Handle(err)
+		h = noop.Float64Histogram{}
+	}
+	return histogram{h}
+}
+
+// counter is an apicalypse.Counter backed by an OpenTelemetry counter.
+type counter struct {
+	counter metric.Int64Counter
+}
+
+// Add adds n to the counter.
+func (c counter) Add(ctx context.Context, n int64, attrs ...apicalypse.Attribute) {
+	c.counter.Add(ctx, n, metric.WithAttributes(keyValues(attrs)...))
+}
+
+// histogram is an apicalypse.Histogram backed by an OpenTelemetry histogram.
+type histogram struct {
+	histogram metric.Float64Histogram
+}
+
+// Record records v in the histogram.
+func (h histogram) Record(ctx context.Context, v float64, attrs ...apicalypse.Attribute) {
+	h.histogram.Record(ctx, v, metric.WithAttributes(keyValues(attrs)...))
+}
+
+// keyValues converts the provided attributes to OpenTelemetry attributes. Values
+// of types other than those documented by apicalypse.Attribute are formatted as
+// strings.
+func keyValues(attrs []apicalypse.Attribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, len(attrs))
+	for i, a := range attrs {
+		switch v := a.Value.(type) {
+		case string:
+			kvs[i] = attribute.String(a.Key, v)
+		case int64:
+			kvs[i] = attribute.Int64(a.Key, v)
+		case bool:
+			kvs[i] = attribute.Bool(a.Key, v)
+		case []string:
+			kvs[i] = attribute.StringSlice(a.Key, v)
+		default:
+			kvs[i] = attribute.String(a.Key, fmt.Sprint(v))
+		}
+	}
+
+	return kvs
+}
@@ -0,0 +1,176 @@
+package otelapicalypse
+
+import (
+	"context"
+	"errors"
+	"github.com/Henry-Sarabia/apicalypse"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestInstrument(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":1}]`))
+	}))
+	defer ts.Close()
+
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	c, err := apicalypse.NewClient(apicalypse.WithMiddleware(apicalypse.Instrument(Tracer(tp.Tracer("test")), Meter(mp.Meter("test")))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := c.Send(context.Background(), "POST", ts.URL+"/games", apicalypse.Fields("name"), apicalypse.Limit(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	ended := spans.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("got: <%v>, want: <%v>", len(ended), 1)
+	}
+	s := ended[0]
+
+	if s.Name() != "apicalypse games" {
+		t.Errorf("got: <%v>, want: <%v>", s.Name(), "apicalypse games")
+	}
+
+	if s.SpanKind() != trace.SpanKindClient {
+		t.Errorf("got: <%v>, want: <%v>", s.SpanKind(), trace.SpanKindClient)
+	}
+
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range s.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	wantAttrs := map[attribute.Key]interface{}{
+		"apicalypse.endpoint":       "games",
+		"apicalypse.clauses":        []string{"fields", "limit"},
+		"apicalypse.limit":          int64(5),
+		"http.response.status_code": int64(200),
+		"http.response.body.size":   int64(10),
+	}
+	for k, want := range wantAttrs {
+		if got := attrs[k].AsInterface(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got: <%v>, want: <%v>", got, want)
+		}
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+
+	units := map[string]string{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			units[m.Name] = m.Unit
+			if m.Name != apicalypse.MetricRequests {
+				continue
+			}
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok || len(sum.DataPoints) != 1 || sum.DataPoints[0].Value != 1 {
+				t.Errorf("got: <%v>, want: <%v>", m.Data, "a single request")
+			}
+		}
+	}
+
+	wantUnits := map[string]string{
+		apicalypse.MetricRequests:        "{request}",
+		apicalypse.MetricRequestDuration: "s",
+	}
+	for name, want := range wantUnits {
+		if got, ok := units[name]; !ok || got != want {
+			t.Errorf("got: <%v>, want: <%v>", got, want)
+		}
+	}
+}
+
+func TestSpanRecordError(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+
+	_, s := Tracer(tp.Tracer("test")).Start(context.Background(), "apicalypse games")
+	s.RecordError(errors.New("connection refused"))
+	s.End()
+
+	ended := spans.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("got: <%v>, want: <%v>", len(ended), 1)
+	}
+
+	want := sdktrace.Status{Code: codes.Error, Description: "connection refused"}
+	if got := ended[0].Status(); got != want {
+		t.Errorf("got: <%v>, want: <%v>", got, want)
+	}
+
+	if got := len(ended[0].Events()); got != 1 {
+		t.Errorf("got: <%v>, want: <%v>", got, 1)
+	}
+}
+
+func TestKeyValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  attribute.Value
+	}{
+		{"String", "games", attribute.StringValue("games")},
+		{"Int64", int64(5), attribute.Int64Value(5)},
+		{"Bool", true, attribute.BoolValue(true)},
+		{"String slice", []string{"fields", "limit"}, attribute.StringSliceValue([]string{"fields", "limit"})},
+		{"Other", 1.5, attribute.StringValue("1.5")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kvs := keyValues([]apicalypse.Attribute{{Key: "key", Value: test.value}})
+			if len(kvs) != 1 {
+				t.Fatalf("got: <%v>, want: <%v>", len(kvs), 1)
+			}
+
+			if kvs[0].Key != "key" {
+				t.Errorf("got: <%v>, want: <%v>", kvs[0].Key, "key")
+			}
+
+			if kvs[0].Value != test.want {
+				t.Errorf("got: <%v>, want: <%v>", kvs[0].Value.Emit(), test.want.Emit())
+			}
+		})
+	}
+}
+
+func Example() {
+	// The global providers are set up by the application, such as with the
+	// OpenTelemetry SDK.
+	tracer := Tracer(otel.Tracer("github.com/Henry-Sarabia/apicalypse"))
+	meter := Meter(otel.Meter("github.com/Henry-Sarabia/apicalypse"))
+
+	c, err := apicalypse.NewClient(apicalypse.WithMiddleware(apicalypse.Instrument(tracer, meter)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	resp, err := c.Send(context.Background(), "POST", "https://api-v3.igdb.com/games", apicalypse.Fields("name"), apicalypse.Limit(5))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+}
@@ -0,0 +1,320 @@
+package apicalypse
+
+import (
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrClauseNotAllowed occurs when a query uses a clause that its Profile does not allow.
+	ErrClauseNotAllowed = errors.New("clause is not allowed by the profile")
+	// ErrLimitExceeded occurs when a query's limit is above the maximum of its Profile.
+	ErrLimitExceeded = errors.New("limit exceeds the maximum of the profile")
+	// ErrOffsetExceeded occurs when a query's offset is above the maximum of its Profile.
+	ErrOffsetExceeded = errors.New("offset exceeds the maximum of the profile")
+	// ErrTooManySubqueries occurs when a multiquery has more subqueries than its Profile allows.
+	ErrTooManySubqueries = errors.New("multiquery exceeds the maximum number of subqueries of the profile")
+	// ErrMultiSortNotAllowed occurs when a query sorts by more than one key and its Profile does not allow it.
+	ErrMultiSortNotAllowed = errors.New("sorting by more than one key is not allowed by the profile")
+)
+
+// Profile declares the restrictions an Apicalypse API places on its queries. A
+// zero maximum leaves its value uncapped.
+type Profile struct {
+	// Name identifies the profile in errors.
+	Name string
+	// DefaultLimit is the number of results the API returns for queries without a
+	// limit clause. Queries are not rewritten to include it.
+	DefaultLimit int
+	// MaxLimit is the highest limit the API accepts.
+	MaxLimit int
+	// MaxOffset is the highest offset the API accepts.
+	MaxOffset int
+	// MaxSubqueries is the highest number of subqueries the API accepts in a
+	// multiquery.
+	MaxSubqueries int
+	// Clauses are the clauses the API accepts, such as "fields" or "where". A nil
+	// slice allows every clause.
+	Clauses []string
+	// MultiSort allows queries to sort by more than one key, as written by SortBy.
+	// Queries that do are rejected if it is not set, even if the profile clamps.
+	MultiSort bool
+	// Clamp lowers limits and offsets above their maximum to the maximum instead
+	// of rejecting them.
+	Clamp bool
+}
+
+// SpecProfile is the Profile of an API that accepts any query the Apicalypse
+// specification allows: every clause, sorting by more than one key, and limits,
+// offsets, and multiqueries of any size. It is the profile to start from for APIs
+// without documented restrictions.
+var SpecProfile = Profile{
+	Name:      "apicalypse",
+	Clauses:   []string{"fields", "exclude", "search", "where", "sort", "limit", "offset"},
+	MultiSort: true,
+}
+
+// IGDBProfile is the Profile of the IGDB API, which returns 10 results by default,
+// accepts a limit of at most 500, accepts at most 10 subqueries in a multiquery,
+// and sorts by a single key.
+var IGDBProfile = Profile{
+	Name:          "igdb",
+	DefaultLimit:  10,
+	MaxLimit:      500,
+	MaxSubqueries: 10,
+	Clauses:       []string{"fields", "exclude", "search", "where", "sort", "limit", "offset"},
+}
+
+// Query is like the Query function but first checks the query against the
+// profile, clamping its limit and offset if the profile allows it. See
+// Profile.Validate for the checks that are made.
+func (p Profile) Query(opts ...Option) (string, error) {
+	filters, err := queryFilters(opts...)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := p.apply(filters); err != nil {
+		return "", err
+	}
+
+	return toString(filters), nil
+}
+
+// Multiquery is like the Multiquery function but first checks the number of
+// subqueries and each subquery against the profile, clamping their limits and
+// offsets if the profile allows it.
+func (p Profile) Multiquery(subs ...Subquery) (string, error) {
+	if len(subs) <= 0 {
+		return "", ErrMissingInput
+	}
+	if p.MaxSubqueries > 0 && len(subs) > p.MaxSubqueries {
+		return "", p.wrap(errors.Wrapf(ErrTooManySubqueries, "%d subqueries, maximum %d", len(subs), p.MaxSubqueries))
+	}
+
+	queries := make([]subquery, len(subs))
+	for i, s := range subs {
+		filters, err := queryFilters(s.Options...)
+		if err != nil {
+			return "", errors.Wrapf(err, "cannot create filter map for subquery '%s'", s.Name)
+		}
+		if _, err := p.apply(filters); err != nil {
+			return "", errors.Wrapf(err, "subquery '%s'", s.Name)
+		}
+		queries[i] = subquery{endpoint: s.Endpoint, name: s.Name, filters: filters}
+	}
+
+	return multiqueryString(queries)
+}
+
+// Validate checks the query built from the provided options against the profile.
+// It rejects clauses the profile does not allow and, unless the profile clamps
+// them, limits and offsets above the profile's maximums. Unless the profile
+// allows it, sorting by more than one key is rejected as well. The cause of the
+// returned error is one of the profile's Err variables.
+func (p Profile) Validate(opts ...Option) error {
+	filters, err := queryFilters(opts...)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.apply(filters)
+	return err
+}
+
+// CostModel returns the DefaultCostModel with its DefaultLimit set to the
+// profile's, if any.
+func (p Profile) CostModel() CostModel {
+	m := DefaultCostModel
+	if p.DefaultLimit > 0 {
+		m.DefaultLimit = p.DefaultLimit
+	}
+
+	return m
+}
+
+// WithProfile is a functional option for checking every request's query against
+// the provided Profile before the request is sent. Requests whose query breaks
+// the profile's restrictions fail with an error whose cause is one of the
+// profile's Err variables, unless the profile clamps them, in which case their
+// query is rewritten with the limit or offset lowered to the maximum.
+func WithProfile(p Profile) ClientOption {
+	return func(c *Client) error {
+		if p.DefaultLimit < 0 || p.MaxLimit < 0 || p.MaxOffset < 0 || p.MaxSubqueries < 0 {
+			return ErrNegativeInput
+		}
+		if p.MaxLimit > 0 && p.DefaultLimit > p.MaxLimit {
+			return errors.Errorf("default limit %d exceeds maximum limit %d", p.DefaultLimit, p.MaxLimit)
+		}
+		for _, k := range p.Clauses {
+			if clauseIndex(k) < 0 {
+				return errors.Errorf("unknown clause '%s'", k)
+			}
+		}
+		c.profile = &p
+
+		return nil
+	}
+}
+
+// apply checks the provided filters against the profile, clamping their limit and
+// offset if the profile allows it, and reports whether the filters were changed.
+func (p *Profile) apply(filters map[string]string) (bool, error) {
+	if p.Clauses != nil {
+		for _, k := range clauseKeys(filters) {
+			if !contains(p.Clauses, k) {
+				return false, p.wrap(errors.Wrapf(ErrClauseNotAllowed, "'%s'", k))
+			}
+		}
+	}
+
+	if v, ok := filters["sort"]; ok && !p.MultiSort && strings.Contains(v, ",") {
+		return false, p.wrap(errors.Wrapf(ErrMultiSortNotAllowed, "'%s'", v))
+	}
+
+	changed := false
+	caps := []struct {
+		clause string
+		max    int
+		err    error
+	}{
+		{"limit", p.MaxLimit, ErrLimitExceeded},
+		{"offset", p.MaxOffset, ErrOffsetExceeded},
+	}
+	for _, c := range caps {
+		v, ok := filters[c.clause]
+		if !ok || c.max <= 0 {
+			continue
+		}
+
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return false, errors.Wrapf(err, "cannot parse %s '%s'", c.clause, v)
+		}
+		if n <= c.max {
+			continue
+		}
+		if !p.Clamp {
+			return false, p.wrap(errors.Wrapf(c.err, "%s %d, maximum %d", c.clause, n, c.max))
+		}
+		filters[c.clause] = strconv.Itoa(c.max)
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// wrap annotates an error with the name of the profile.
+func (p *Profile) wrap(err error) error {
+	if p.Name == "" {
+		return err
+	}
+	return errors.Wrapf(err, "profile '%s'", p.Name)
+}
+
+// enforce checks the query in the provided request's body or, if the body is
+// empty, in its url parameters against the profile and returns the request with
+// its query rewritten if the profile clamped it. On error, the provided request
+// is returned unchanged.
+func (p *Profile) enforce(req *http.Request) (*http.Request, error) {
+	if p == nil {
+		return req, nil
+	}
+
+	q, ok := queryText(req)
+	if !ok {
+		return req, errors.New("cannot read query from request body")
+	}
+
+	if strings.TrimSpace(q) == "" {
+		filters, err := RequestClauses(req)
+		if err != nil {
+			return req, err
+		}
+		changed, err := p.apply(filters)
+		if err != nil || !changed {
+			return req, err
+		}
+
+		r := req.Clone(req.Context())
+		r.URL.RawQuery = encodeParams(req.URL.RawQuery, filters, func(k string) bool {
+			return clauseIndex(k) >= 0
+		})
+		return r, nil
+	}
+
+	rewritten, changed, err := p.applyQuery(q)
+	if err != nil || !changed {
+		return req, err
+	}
+
+	r := req.Clone(req.Context())
+	r.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(rewritten)), nil
+	}
+	r.Body, _ = r.GetBody()
+	r.ContentLength = int64(len(rewritten))
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	return r, nil
+}
+
+// applyQuery checks the provided query string, which may be a multiquery, against
+// the profile and returns it rewritten if the profile clamped any of its clauses.
+func (p *Profile) applyQuery(q string) (string, bool, error) {
+	scan := scanQuery(q, 0)
+	if len(scan.problems) > 0 {
+		pr := scan.problems[0]
+		return "", false, errors.Errorf("%v: %s", position(q, pr.offset), pr.message)
+	}
+
+	filters := map[string]string{}
+	var subs []string
+	changed := false
+	for _, c := range scan.clauses(q) {
+		switch c.keyword {
+		case "":
+			continue
+		case "query":
+			open, close := strings.Index(c.value, "{"), strings.LastIndex(c.value, "}")
+			if open < 0 || close < open {
+				return "", false, errors.Errorf("%v: subquery is missing its body", position(q, c.valueOffset))
+			}
+			sub, err := parseFilters(c.value[open+1 : close])
+			if err != nil {
+				return "", false, errors.Wrapf(err, "%v", position(q, c.valueOffset))
+			}
+			ok, err := p.apply(sub)
+			if err != nil {
+				return "", false, errors.Wrapf(err, "%v", position(q, c.valueOffset))
+			}
+			changed = changed || ok
+			subs = append(subs, "query "+strings.TrimSpace(c.value[:open])+" { "+toString(sub)+"}; ")
+		default:
+			if _, ok := filters[c.keyword]; ok {
+				return "", false, errors.Errorf("%v: clause '%s' is repeated", position(q, c.offset), c.keyword)
+			}
+			filters[c.keyword] = collapseSpace(c.value)
+		}
+	}
+
+	if p.MaxSubqueries > 0 && len(subs) > p.MaxSubqueries {
+		return "", false, p.wrap(errors.Wrapf(ErrTooManySubqueries, "%d subqueries, maximum %d", len(subs), p.MaxSubqueries))
+	}
+
+	ok, err := p.apply(filters)
+	if err != nil {
+		return "", false, err
+	}
+	if !ok && !changed {
+		return q, false, nil
+	}
+
+	return toString(filters) + strings.Join(subs, ""), true, nil
+}
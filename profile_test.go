@@ -0,0 +1,188 @@
+package apicalypse
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProfileQuery(t *testing.T) {
+	clamped := IGDBProfile
+	clamped.Clamp = true
+	clamped.MaxOffset = 100
+
+	tests := []struct {
+		name    string
+		profile Profile
+		opts    []Option
+		want    string
+		wantErr error
+	}{
+		{"Within limits", IGDBProfile, []Option{Fields("name"), Limit(500)}, "fields name; limit 500; ", nil},
+		{"Limit exceeded", IGDBProfile, []Option{Limit(501)}, "", ErrLimitExceeded},
+		{"Limit clamped", clamped, []Option{Limit(501), Offset(200)}, "limit 500; offset 100; ", nil},
+		{"Offset exceeded", Profile{MaxOffset: 100}, []Option{Offset(101)}, "", ErrOffsetExceeded},
+		{"Clause not allowed", Profile{Clauses: []string{"fields"}}, []Option{Fields("name"), Search("", "halo")}, "", ErrClauseNotAllowed},
+		{"Unrestricted", Profile{}, []Option{Limit(1000), Offset(1000)}, "limit 1000; offset 1000; ", nil},
+		{"Single sort", IGDBProfile, []Option{Sort("rating", Desc)}, "sort rating desc; ", nil},
+		{"Multi-sort rejected", clamped, []Option{SortBy(SortKey{"rating", Desc}, SortKey{"name", Asc})}, "", ErrMultiSortNotAllowed},
+		{"Multi-sort allowed", Profile{MultiSort: true}, []Option{SortBy(SortKey{"rating", Desc}, SortKey{"name", Asc})}, "sort rating desc, name asc; ", nil},
+		{"Invalid option", IGDBProfile, []Option{Limit(-1)}, "", ErrNegativeInput},
+		{"Spec within limits", SpecProfile, []Option{Fields("name"), Limit(1000), Offset(5000)}, "fields name; limit 1000; offset 5000; ", nil},
+		{"Spec multi-sort", SpecProfile, []Option{SortBy(SortKey{"rating", Desc}, SortKey{"name", Asc})}, "sort rating desc, name asc; ", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.profile.Query(test.opts...)
+			if errors.Cause(err) != test.wantErr {
+				t.Fatalf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+
+			if got != test.want {
+				t.Errorf("got: <%v>, want: <%v>", got, test.want)
+			}
+
+			if err := test.profile.Validate(test.opts...); errors.Cause(err) != test.wantErr {
+				t.Errorf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestProfileErrorMessage(t *testing.T) {
+	_, err := IGDBProfile.Query(Limit(600))
+	want := "profile 'igdb': limit 600, maximum 500: limit exceeds the maximum of the profile"
+	if err == nil || err.Error() != want {
+		t.Errorf("got: <%v>, want: <%v>", err, want)
+	}
+}
+
+func TestProfileMultiquery(t *testing.T) {
+	sub := func(name string, opts ...Option) Subquery {
+		return Subquery{Endpoint: "games", Name: name, Options: opts}
+	}
+
+	tests := []struct {
+		name    string
+		profile Profile
+		subs    []Subquery
+		want    string
+		wantErr error
+	}{
+		{"Within limits", IGDBProfile, []Subquery{sub("a", Limit(5))}, `query games "a" { limit 5; }; `, nil},
+		{"Too many subqueries", Profile{MaxSubqueries: 1}, []Subquery{sub("a"), sub("b")}, "", ErrTooManySubqueries},
+		{"Subquery limit exceeded", IGDBProfile, []Subquery{sub("a", Limit(501))}, "", ErrLimitExceeded},
+		{"Subquery limit clamped", Profile{MaxLimit: 10, Clamp: true}, []Subquery{sub("a", Limit(50))}, `query games "a" { limit 10; }; `, nil},
+		{"No subqueries", IGDBProfile, nil, "", ErrMissingInput},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.profile.Multiquery(test.subs...)
+			if errors.Cause(err) != test.wantErr {
+				t.Fatalf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+
+			if got != test.want {
+				t.Errorf("got: <%v>, want: <%v>", got, test.want)
+			}
+		})
+	}
+}
+
+func TestProfileCostModel(t *testing.T) {
+	if got := IGDBProfile.CostModel().DefaultLimit; got != 10 {
+		t.Errorf("got: <%v>, want: <%v>", got, 10)
+	}
+
+	if got := SpecProfile.CostModel(); got != DefaultCostModel {
+		t.Errorf("got: <%v>, want: <%v>", got, DefaultCostModel)
+	}
+
+	if got := (Profile{}).CostModel(); got != DefaultCostModel {
+		t.Errorf("got: <%v>, want: <%v>", got, DefaultCostModel)
+	}
+}
+
+func TestWithProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile Profile
+		wantErr bool
+	}{
+		{"IGDB", IGDBProfile, false},
+		{"Spec", SpecProfile, false},
+		{"Negative limit", Profile{MaxLimit: -1}, true},
+		{"Default above maximum", Profile{DefaultLimit: 20, MaxLimit: 10}, true},
+		{"Unknown clause", Profile{Clauses: []string{"fields", "group"}}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewClient(WithProfile(test.profile))
+			if (err != nil) != test.wantErr {
+				t.Errorf("got: <%v>, want error: <%v>", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientProfile(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(r.URL.RawQuery + "|" + string(b)))
+	}))
+	defer ts.Close()
+
+	clamped := IGDBProfile
+	clamped.Clamp = true
+
+	tests := []struct {
+		name    string
+		profile Profile
+		enc     Encoding
+		body    string
+		opts    []Option
+		want    string
+		wantErr error
+	}{
+		{"Unchanged", IGDBProfile, BodyEncoding, "", []Option{Fields("name"), Limit(50)}, "|fields name; limit 50; ", nil},
+		{"Rejected", IGDBProfile, BodyEncoding, "", []Option{Limit(501)}, "", ErrLimitExceeded},
+		{"Clamped", clamped, BodyEncoding, "", []Option{Fields("name"), Limit(501)}, "|fields name; limit 500; ", nil},
+		{"Clamped in url", clamped, URLEncoding, "", []Option{Limit(501)}, "limit=500|", nil},
+		{"Multiquery clamped", clamped, BodyEncoding, `query games "a" { fields name; limit 600; }; query games "b" { limit 5; };`, nil, `|query games "a" { fields name; limit 500; }; query games "b" { limit 5; }; `, nil},
+		{"Multiquery unchanged", clamped, BodyEncoding, `query games "a" { limit 5; };`, nil, `|query games "a" { limit 5; };`, nil},
+		{"Multiquery too large", Profile{MaxSubqueries: 1}, BodyEncoding, `query games "a" { limit 5; }; query games "b" { limit 5; };`, nil, "", ErrTooManySubqueries},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := NewClient(WithProfile(test.profile), WithMethod("POST"), WithEncoding(test.enc))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var resp *http.Response
+			if test.body != "" {
+				req, rerr := http.NewRequest("POST", ts.URL, strings.NewReader(test.body))
+				if rerr != nil {
+					t.Fatal(rerr)
+				}
+				resp, err = c.Do(context.Background(), req)
+			} else {
+				resp, err = c.Send(context.Background(), "", ts.URL, test.opts...)
+			}
+			if errors.Cause(err) != test.wantErr {
+				t.Fatalf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if got := readBody(t, resp); got != test.want {
+				t.Errorf("got: <%v>, want: <%v>", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package apicalypse
+
+import (
+	"context"
+	"github.com/Henry-Sarabia/blank"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"io/ioutil"
+	"net/url"
+	"strings"
+)
+
+// protobufSuffix is appended to an endpoint's path to request Protocol Buffers
+// encoded results instead of JSON.
+const protobufSuffix = ".pb"
+
+// ProtobufURL returns the url of the Protocol Buffers variant of the provided
+// endpoint url by appending ".pb" to its path, as expected by APIs such as IGDB.
+// Urls that already end in ".pb" are returned unchanged.
+func ProtobufURL(rawurl string) (string, error) {
+	if blank.Is(rawurl) {
+		return "", ErrBlankArgument
+	}
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot parse url '%s'", rawurl)
+	}
+
+	p := strings.TrimSuffix(u.Path, "/")
+	if !strings.HasSuffix(p, protobufSuffix) {
+		p += protobufSuffix
+	}
+	u.Path = p
+	u.RawPath = ""
+
+	return u.String(), nil
+}
+
+// FetchProto sends a query built from the provided options to the Protocol Buffers
+// variant of the provided url using the Client's method and decodes the returned
+// message into msg. The query is the same one Fetch would send for the provided
+// options; only the encoding of the response differs. APIs usually return a wrapper
+// message holding a repeated field of results (e.g. GameResult for the games
+// endpoint), so msg should be the generated Go type of that wrapper message.
+// If the API responds with a status code outside of the 2xx range, the returned
+// error is a *StatusError.
+func FetchProto(ctx context.Context, c *Client, url string, msg proto.Message, opts ...Option) error {
+	if msg == nil {
+		return ErrMissingInput
+	}
+
+	pburl, err := ProtobufURL(url)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Send(ctx, c.method, pburl, opts...)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "cannot read response body")
+	}
+
+	if err := proto.Unmarshal(b, msg); err != nil {
+		return errors.Wrap(err, "cannot decode protobuf response body")
+	}
+
+	return nil
+}
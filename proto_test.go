@@ -0,0 +1,92 @@
+package apicalypse
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProtobufURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		want    string
+		wantErr error
+	}{
+		{"Endpoint url", "https://api.igdb.com/v4/games", "https://api.igdb.com/v4/games.pb", nil},
+		{"Trailing slash", "https://api.igdb.com/v4/games/", "https://api.igdb.com/v4/games.pb", nil},
+		{"Protobuf url", "https://api.igdb.com/v4/games.pb", "https://api.igdb.com/v4/games.pb", nil},
+		{"Query string", "https://api.igdb.com/v4/games?x=1", "https://api.igdb.com/v4/games.pb?x=1", nil},
+		{"Blank url", " ", "", ErrBlankArgument},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ProtobufURL(test.url)
+			if errors.Cause(err) != test.wantErr {
+				t.Errorf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+
+			if got != test.want {
+				t.Errorf("got: <%v>, want: <%v>", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFetchProto(t *testing.T) {
+	want, err := structpb.NewList([]interface{}{"Halo", "Zelda"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := proto.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		status    int
+		body      []byte
+		wantError bool
+	}{
+		{"Valid message", 200, body, false},
+		{"Invalid message", 200, []byte{0xff, 0xff}, true},
+		{"Unsuccessful status", 500, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/games.pb" {
+					t.Errorf("got: <%v>, want: <%v>", r.URL.Path, "/games.pb")
+				}
+				b, _ := ioutil.ReadAll(r.Body)
+				if string(b) != "fields name; " {
+					t.Errorf("got: <%v>, want: <%v>", string(b), "fields name; ")
+				}
+				w.WriteHeader(test.status)
+				w.Write(test.body)
+			}))
+			defer ts.Close()
+
+			c, err := NewClient(WithMethod("POST"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := &structpb.ListValue{}
+			err = FetchProto(context.Background(), c, ts.URL+"/games", got, Fields("name"))
+			if (err != nil) != test.wantError {
+				t.Fatalf("got: <%v>, want error: <%v>", err, test.wantError)
+			}
+
+			if !test.wantError && !proto.Equal(got, want) {
+				t.Errorf("got: <%v>, want: <%v>", got, want)
+			}
+		})
+	}
+}
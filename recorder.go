@@ -0,0 +1,399 @@
+package apicalypse
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/Henry-Sarabia/blank"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// redactedValue replaces the values of redacted headers.
+const redactedValue = "REDACTED"
+
+// CredentialHeaders are the headers that usually hold credentials. They are always
+// redacted from the cassettes of a Recorder and may be redacted by Export.
+var CredentialHeaders = []string{"Authorization", "Proxy-Authorization", "Client-ID", "X-Api-Key", "Cookie", "Set-Cookie"}
+
+// RecorderMode is whether a Recorder records or replays exchanges.
+type RecorderMode int
+
+const (
+	// ReplayMode serves responses from the cassette without sending any requests.
+	ReplayMode RecorderMode = iota
+	// RecordMode sends requests and records each exchange to the cassette.
+	RecordMode
+)
+
+// Recorder records exchanges with an API to a cassette file and replays them,
+// so that tests of code sending queries are fast and deterministic. Exchanges are
+// matched by method, url, and canonical query, so queries that only differ in the
+// order of their clauses or fields match the same exchange. Identical queries
+// recorded several times are replayed in the order they were recorded.
+//
+// The values of headers that usually hold credentials, such as Authorization and
+// Client-ID, are redacted from the cassette. Put the Recorder's Middleware before
+// any Middleware that authenticates requests so that replaying never needs them.
+//
+// A Recorder is safe for concurrent use by multiple goroutines.
+type Recorder struct {
+	path   string
+	mode   RecorderMode
+	redact []string
+
+	mu       sync.Mutex
+	cassette cassette
+	replayed map[string]int
+}
+
+// cassette is the file format of recorded exchanges.
+type cassette struct {
+	Interactions []interaction `json:"interactions"`
+}
+
+// interaction is a single recorded exchange.
+type interaction struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+// recordedRequest is a recorded request.
+type recordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Query  string      `json:"query,omitempty"`
+}
+
+// key returns the key the request is matched by.
+func (r recordedRequest) key() string {
+	return r.Method + " " + r.URL + "\n" + canonicalize(r.Query)
+}
+
+// recordedResponse is a recorded response. Bodies that are not valid UTF-8, such
+// as Protocol Buffers, are stored base64 encoded.
+type recordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 string      `json:"body_base64,omitempty"`
+}
+
+// NewRecorder returns a Recorder using the cassette at the provided path in the
+// provided mode. In ReplayMode, the cassette must exist. In RecordMode, the
+// cassette is replaced as soon as the first exchange is recorded. The values of
+// the provided headers are redacted in addition to the default ones.
+func NewRecorder(path string, mode RecorderMode, redact ...string) (*Recorder, error) {
+	if blank.Is(path) {
+		return nil, ErrBlankArgument
+	}
+
+	r := &Recorder{
+		path:     path,
+		mode:     mode,
+		redact:   append(append([]string{}, CredentialHeaders...), redact...),
+		replayed: map[string]int{},
+	}
+
+	switch mode {
+	case RecordMode:
+		return r, nil
+	case ReplayMode:
+	default:
+		return nil, errors.Errorf("unknown recorder mode %d", mode)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read cassette")
+	}
+	if err := json.Unmarshal(b, &r.cassette); err != nil {
+		return nil, errors.Wrapf(err, "cannot decode cassette '%s'", path)
+	}
+
+	return r, nil
+}
+
+// Middleware returns Middleware that records or replays every request according
+// to the Recorder's mode. In ReplayMode, requests are never passed on, so the
+// Middleware may be used with a nil transport in Chain.
+func (r *Recorder) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			rec, err := r.request(req)
+			if err != nil || r.mode == ReplayMode {
+				if req.Body != nil {
+					req.Body.Close()
+				}
+				if err != nil {
+					return nil, err
+				}
+				return r.replay(req, rec)
+			}
+
+			return r.record(req, rec, next)
+		})
+	}
+}
+
+// request returns the recorded form of the provided request.
+func (r *Recorder) request(req *http.Request) (recordedRequest, error) {
+	q, ok := queryText(req)
+	if !ok {
+		return recordedRequest{}, errors.New("cannot record request whose body cannot be rewound")
+	}
+
+	return recordedRequest{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: r.redacted(req.Header),
+		Query:  q,
+	}, nil
+}
+
+// record sends the provided request and records the exchange.
+func (r *Recorder) record(req *http.Request, rec recordedRequest, next http.RoundTripper) (*http.Response, error) {
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read response body to record")
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	res := recordedResponse{StatusCode: resp.StatusCode, Header: r.redacted(resp.Header)}
+	if utf8.Valid(body) {
+		res.Body = string(body)
+	} else {
+		res.BodyBase64 = base64.StdEncoding.EncodeToString(body)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction{Request: rec, Response: res})
+	if err := r.save(); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// save writes the cassette to its file. The caller must hold r.mu.
+func (r *Recorder) save() error {
+	b, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "cannot encode cassette")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return errors.Wrap(err, "cannot create cassette directory")
+	}
+	if err := ioutil.WriteFile(r.path, append(b, '\n'), 0644); err != nil {
+		return errors.Wrap(err, "cannot write cassette")
+	}
+
+	return nil
+}
+
+// replay returns the recorded response to the provided request.
+func (r *Recorder) replay(req *http.Request, rec recordedRequest) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := rec.key()
+	var matches []recordedResponse
+	for _, in := range r.cassette.Interactions {
+		if in.Request.key() == key {
+			matches = append(matches, in.Response)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, r.unmatched(rec)
+	}
+
+	i := r.replayed[key]
+	if i >= len(matches) {
+		i = len(matches) - 1
+	}
+	r.replayed[key]++
+	res := matches[i]
+
+	body := []byte(res.Body)
+	if res.BodyBase64 != "" {
+		b, err := base64.StdEncoding.DecodeString(res.BodyBase64)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot decode recorded response body")
+		}
+		body = b
+	}
+
+	header := res.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", res.StatusCode, http.StatusText(res.StatusCode)),
+		StatusCode:    res.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// redacted returns a copy of the provided header with the values of redacted
+// headers replaced.
+func (r *Recorder) redacted(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+
+	c := h.Clone()
+	for _, k := range r.redact {
+		if _, ok := c[http.CanonicalHeaderKey(k)]; ok {
+			c.Set(k, redactedValue)
+		}
+	}
+
+	return c
+}
+
+// UnmatchedError occurs when a Recorder in ReplayMode has no recorded exchange
+// matching a request.
+type UnmatchedError struct {
+	// Method is the method of the request.
+	Method string
+	// URL is the url of the request.
+	URL string
+	// Query is the query of the request.
+	Query string
+	// Closest is the recorded request most similar to the request, formatted as
+	// its method and url, or empty if the cassette is empty.
+	Closest string
+	// Diff compares the clauses of the closest recorded query, prefixed with "-",
+	// to those of the request's query, prefixed with "+".
+	Diff string
+}
+
+// Error describes the request and how it differs from the closest recorded one.
+func (e *UnmatchedError) Error() string {
+	msg := fmt.Sprintf("no recorded response for %s %s with query '%s'", e.Method, e.URL, strings.TrimSpace(e.Query))
+	if e.Closest == "" {
+		return msg + ": cassette is empty"
+	}
+	return msg + "; closest recorded request is " + e.Closest + ":\n" + e.Diff
+}
+
+// unmatched returns an UnmatchedError for the provided request. The caller must
+// hold r.mu.
+func (r *Recorder) unmatched(rec recordedRequest) error {
+	e := &UnmatchedError{Method: rec.Method, URL: rec.URL, Query: rec.Query}
+
+	best := -1
+	var closest recordedRequest
+	for _, in := range r.cassette.Interactions {
+		d := diffSize(in.Request.Query, rec.Query)
+		if in.Request.Method != rec.Method {
+			d += 100
+		}
+		if in.Request.URL != rec.URL {
+			d += 1000
+		}
+		if best < 0 || d < best {
+			best, closest = d, in.Request
+		}
+	}
+	if best < 0 {
+		return e
+	}
+
+	e.Closest = closest.Method + " " + closest.URL
+	b := strings.Builder{}
+	for _, line := range queryDiff(closest.Query, rec.Query) {
+		b.WriteString(line + "\n")
+	}
+	e.Diff = b.String()
+
+	return e
+}
+
+// queryDiff returns the clauses of the provided queries in canonical order, with
+// clauses only found in the recorded query prefixed with "-", clauses only found
+// in the actual query prefixed with "+", and clauses found in both prefixed with
+// a space.
+func queryDiff(recorded, actual string) []string {
+	rf, af := diffFilters(recorded), diffFilters(actual)
+
+	all := map[string]string{}
+	for k := range rf {
+		all[k] = ""
+	}
+	for k := range af {
+		all[k] = ""
+	}
+
+	var lines []string
+	for _, k := range clauseKeys(all) {
+		rv, rok := rf[k]
+		av, aok := af[k]
+		switch {
+		case rok && aok && rv == av:
+			lines = append(lines, "  "+clauseLine(k, rv))
+		default:
+			if rok {
+				lines = append(lines, "- "+clauseLine(k, rv))
+			}
+			if aok {
+				lines = append(lines, "+ "+clauseLine(k, av))
+			}
+		}
+	}
+
+	return lines
+}
+
+// diffSize returns the number of clauses that differ between the provided queries.
+func diffSize(recorded, actual string) int {
+	n := 0
+	for _, line := range queryDiff(recorded, actual) {
+		if !strings.HasPrefix(line, " ") {
+			n++
+		}
+	}
+	return n
+}
+
+// diffFilters returns the canonical filters of the provided query for diffing.
+// A query that cannot be parsed is returned as a single clause without a keyword.
+func diffFilters(q string) map[string]string {
+	f, err := parseFilters(q)
+	if err != nil {
+		return map[string]string{"": collapseSpace(strings.TrimSpace(q))}
+	}
+	return canonicalFilters(f)
+}
+
+// clauseLine formats a clause for a diff.
+func clauseLine(keyword, value string) string {
+	if keyword == "" {
+		return value
+	}
+	return keyword + " " + value + ";"
+}
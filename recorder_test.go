@@ -0,0 +1,158 @@
+package apicalypse
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNewRecorder(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := ioutil.WriteFile(invalid, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		mode    RecorderMode
+		wantErr bool
+	}{
+		{"Record new cassette", filepath.Join(dir, "new.json"), RecordMode, false},
+		{"Replay missing cassette", filepath.Join(dir, "missing.json"), ReplayMode, true},
+		{"Replay invalid cassette", invalid, ReplayMode, true},
+		{"Unknown mode", filepath.Join(dir, "new.json"), RecorderMode(9), true},
+		{"Blank path", " ", RecordMode, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewRecorder(test.path, test.mode)
+			if (err != nil) != test.wantErr {
+				t.Errorf("got: <%v>, want error: <%v>", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=secret")
+		if strings.Contains(string(b), "cover") {
+			w.Write([]byte{0xff, 0xfe, byte(n)})
+			return
+		}
+		w.Write([]byte(`[{"call":` + strconv.Itoa(int(n)) + `}]`))
+	}))
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "testdata", "games.json")
+	send := func(c *Client, opts ...Option) string {
+		t.Helper()
+		resp, err := c.Send(context.Background(), "POST", ts.URL, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return readBody(t, resp)
+	}
+
+	rec, err := NewRecorder(path, RecordMode, "X-Secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(WithMiddleware(SetHeader("Authorization", "Bearer abc"), SetHeader("X-Secret", "xyz"), rec.Middleware()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorded := []string{
+		send(c, Fields("name", "id"), Limit(5)),
+		send(c, Fields("name", "id"), Limit(5)),
+		send(c, Fields("cover")),
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"abc", "xyz", "session"} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("got: <%v>, want no: <%v>", string(b), secret)
+		}
+	}
+
+	rec, err = NewRecorder(path, ReplayMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = NewClient(WithMiddleware(rec.Middleware()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayed := []string{
+		send(c, Limit(5), Fields("id", "name")),
+		send(c, Fields("name", "id"), Limit(5)),
+		send(c, Fields("cover")),
+	}
+	for i := range recorded {
+		if replayed[i] != recorded[i] {
+			t.Errorf("got: <%v>, want: <%v>", replayed[i], recorded[i])
+		}
+	}
+
+	if calls != 3 {
+		t.Errorf("got: <%v>, want: <%v>", calls, 3)
+	}
+}
+
+func TestRecorderUnmatched(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.json")
+	cassette := `{"interactions": [
+		{"request": {"method": "POST", "url": "http://fake.com/games", "query": "fields name; limit 5;"}, "response": {"status_code": 200}},
+		{"request": {"method": "POST", "url": "http://fake.com/covers", "query": "fields name; limit 10;"}, "response": {"status_code": 200}}
+	]}`
+	if err := ioutil.WriteFile(path, []byte(cassette), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := NewRecorder(path, ReplayMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := Chain(nil, rec.Middleware())
+
+	req, err := NewRequest("POST", "http://fake.com/games", Fields("name"), Limit(10), Sort("name", "asc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = rt.RoundTrip(req)
+	uerr, ok := errors.Cause(err).(*UnmatchedError)
+	if !ok {
+		t.Fatalf("got: <%v>, want: <%v>", err, "*UnmatchedError")
+	}
+
+	if uerr.Closest != "POST http://fake.com/games" {
+		t.Errorf("got: <%v>, want: <%v>", uerr.Closest, "POST http://fake.com/games")
+	}
+
+	wantDiff := "  fields name;\n+ sort name asc;\n- limit 5;\n+ limit 10;\n"
+	if uerr.Diff != wantDiff {
+		t.Errorf("got: <%v>, want: <%v>", uerr.Diff, wantDiff)
+	}
+
+	if !strings.Contains(err.Error(), wantDiff) {
+		t.Errorf("got: <%v>, want: <%v>", err.Error(), wantDiff)
+	}
+}
@@ -0,0 +1,202 @@
+package apicalypse
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBaseDelay = 500 * time.Millisecond
+	defaultMaxDelay  = 30 * time.Second
+)
+
+// RetryPolicy configures how a Client retries a request after a transient failure.
+// A request is retried when it fails with a network error or when the server
+// responds with 429 Too Many Requests or a 5xx status code.
+//
+// Only idempotent requests are retried. Apicalypse queries only read data, so
+// requests sent with GET, HEAD, OPTIONS, POST, or PUT are all considered idempotent.
+// A request with a body is only retried if the body can be rewound using GetBody,
+// which is always the case for requests created by NewRequest.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a request is sent, including
+	// the first attempt.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry. The delay doubles with each
+	// subsequent retry and is randomly jittered. Defaults to 500ms.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay before any retry, including delays requested by a
+	// Retry-After header. Defaults to 30s.
+	MaxDelay time.Duration
+	// OnRetry, if set, is called before each retry.
+	OnRetry func(RetryEvent)
+}
+
+// RetryEvent describes a failed attempt that is about to be retried.
+type RetryEvent struct {
+	// Request is the request that failed.
+	Request *http.Request
+	// Attempt is the number of the attempt that failed, starting at 1.
+	Attempt int
+	// Delay is how long the Client will wait before the next attempt.
+	Delay time.Duration
+	// StatusCode is the status code of the failed response or 0 if Err is set.
+	StatusCode int
+	// Err is the network error that caused the attempt to fail, if any.
+	Err error
+}
+
+// WithRetry is a functional option for setting the RetryPolicy the Client uses
+// to retry requests after a transient failure.
+func WithRetry(p RetryPolicy) ClientOption {
+	return func(c *Client) error {
+		if p.MaxAttempts < 1 {
+			return errors.New("max attempts must be at least 1")
+		}
+		if p.BaseDelay < 0 || p.MaxDelay < 0 {
+			return ErrNegativeInput
+		}
+		if p.BaseDelay == 0 {
+			p.BaseDelay = defaultBaseDelay
+		}
+		if p.MaxDelay == 0 {
+			p.MaxDelay = defaultMaxDelay
+		}
+		c.retry = &p
+
+		return nil
+	}
+}
+
+type attemptKey struct{}
+
+// withAttempt returns a copy of the provided context holding the number of the
+// attempt a request is sent in.
+func withAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+// RetryAttempt returns the number of the attempt, starting at 1, a request sent
+// with the provided context is, or 0 if the request was not sent by a Client.
+// Middleware can use it to tell retries from first attempts via the request's
+// context.
+func RetryAttempt(ctx context.Context) int {
+	n, _ := ctx.Value(attemptKey{}).(int)
+	return n
+}
+
+// shouldRetry reports whether the provided attempt should be retried given its
+// response and error.
+func (p *RetryPolicy) shouldRetry(ctx context.Context, req *http.Request, resp *http.Response, err error, attempt int) bool {
+	if p == nil || attempt >= p.MaxAttempts || ctx.Err() != nil {
+		return false
+	}
+
+	if !isIdempotent(req) {
+		return false
+	}
+
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	if err != nil {
+		return true
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// delay returns how long to wait before retrying the provided attempt. A delay
+// requested by the response's Retry-After header takes precedence over the
+// exponential backoff.
+func (p *RetryPolicy) delay(resp *http.Response, attempt int) time.Duration {
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			if d > p.MaxDelay {
+				return p.MaxDelay
+			}
+			return d
+		}
+	}
+
+	d := p.BaseDelay << uint(attempt-1)
+	if d > p.MaxDelay || d <= 0 {
+		d = p.MaxDelay
+	}
+
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retryAfter parses the value of a Retry-After header, which is either a number
+// of seconds or an HTTP date.
+func retryAfter(v string) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+
+	if s, err := strconv.Atoi(v); err == nil {
+		if s < 0 {
+			return 0, false
+		}
+		return time.Duration(s) * time.Second, true
+	}
+
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+
+	d := time.Until(t)
+	if d < 0 {
+		d = 0
+	}
+
+	return d, true
+}
+
+// isIdempotent reports whether the provided request can be safely sent again.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPost, http.MethodPut:
+		return true
+	}
+
+	return false
+}
+
+// rewind returns a copy of the provided request with a fresh body.
+func rewind(req *http.Request) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	if req.GetBody == nil {
+		return r, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = body
+
+	return r, nil
+}
+
+// sleep waits for the provided duration or until the provided context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,254 @@
+package apicalypse
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    RetryPolicy
+		wantBase  time.Duration
+		wantMax   time.Duration
+		wantError bool
+	}{
+		{"Default delays", RetryPolicy{MaxAttempts: 3}, defaultBaseDelay, defaultMaxDelay, false},
+		{"Custom delays", RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute}, time.Second, time.Minute, false},
+		{"Zero max attempts", RetryPolicy{}, 0, 0, true},
+		{"Negative base delay", RetryPolicy{MaxAttempts: 3, BaseDelay: -time.Second}, 0, 0, true},
+		{"Negative max delay", RetryPolicy{MaxAttempts: 3, MaxDelay: -time.Second}, 0, 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := NewClient(WithRetry(test.policy))
+			if (err != nil) != test.wantError {
+				t.Fatalf("got: <%v>, want error: <%v>", err, test.wantError)
+			}
+
+			if test.wantError {
+				return
+			}
+
+			if c.retry.BaseDelay != test.wantBase {
+				t.Errorf("got: <%v>, want: <%v>", c.retry.BaseDelay, test.wantBase)
+			}
+
+			if c.retry.MaxDelay != test.wantMax {
+				t.Errorf("got: <%v>, want: <%v>", c.retry.MaxDelay, test.wantMax)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOk bool
+	}{
+		{"Empty value", "", 0, false},
+		{"Seconds", "3", 3 * time.Second, true},
+		{"Negative seconds", "-3", 0, false},
+		{"Past date", "Wed, 21 Oct 2015 07:28:00 GMT", 0, true},
+		{"Invalid value", "soon", 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := retryAfter(test.value)
+			if ok != test.wantOk {
+				t.Errorf("got: <%v>, want: <%v>", ok, test.wantOk)
+			}
+
+			if got != test.want {
+				t.Errorf("got: <%v>, want: <%v>", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := &RetryPolicy{MaxAttempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	tests := []struct {
+		name    string
+		header  string
+		attempt int
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{"First attempt", "", 1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{"Third attempt", "", 3, 200 * time.Millisecond, 400 * time.Millisecond},
+		{"Capped attempt", "", 9, 500 * time.Millisecond, time.Second},
+		{"Retry-After header", "1", 1, time.Second, time.Second},
+		{"Capped Retry-After header", "120", 1, time.Second, time.Second},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if test.header != "" {
+				resp.Header.Set("Retry-After", test.header)
+			}
+
+			got := p.delay(resp, test.attempt)
+			if got < test.wantMin || got > test.wantMax {
+				t.Errorf("got: <%v>, want between: <%v> and <%v>", got, test.wantMin, test.wantMax)
+			}
+		})
+	}
+}
+
+func TestClientDoRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		statuses     []int
+		maxAttempts  int
+		wantStatus   int
+		wantAttempts int32
+	}{
+		{"Success on first attempt", "POST", []int{200}, 3, 200, 1},
+		{"Retry on server error", "POST", []int{503, 500, 200}, 3, 200, 3},
+		{"Retry on too many requests", "GET", []int{429, 200}, 3, 200, 2},
+		{"Exhausted attempts", "POST", []int{502, 502, 502}, 3, 502, 3},
+		{"Client error", "POST", []int{400}, 3, 400, 1},
+		{"Non-idempotent method", "DELETE", []int{503, 200}, 3, 503, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var attempts int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&attempts, 1)
+				b, _ := ioutil.ReadAll(r.Body)
+				if string(b) != "limit 5; " {
+					t.Errorf("got: <%v>, want: <%v>", string(b), "limit 5; ")
+				}
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(test.statuses[n-1])
+			}))
+			defer ts.Close()
+
+			var events []RetryEvent
+			c, err := NewClient(WithRetry(RetryPolicy{
+				MaxAttempts: test.maxAttempts,
+				BaseDelay:   time.Millisecond,
+				OnRetry:     func(e RetryEvent) { events = append(events, e) },
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Send only accepts the methods allowed for queries, so the request is
+			// built directly to cover methods that are not idempotent.
+			req, err := http.NewRequest(test.method, ts.URL, strings.NewReader("limit 5; "))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := c.Do(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != test.wantStatus {
+				t.Errorf("got: <%v>, want: <%v>", resp.StatusCode, test.wantStatus)
+			}
+
+			if attempts != test.wantAttempts {
+				t.Errorf("got: <%v>, want: <%v>", attempts, test.wantAttempts)
+			}
+
+			if len(events) != int(test.wantAttempts)-1 {
+				t.Errorf("got: <%v>, want: <%v>", len(events), test.wantAttempts-1)
+			}
+
+			for i, e := range events {
+				if e.Attempt != i+1 || e.StatusCode != test.statuses[i] {
+					t.Errorf("got: <%v>, want attempt <%v> with status <%v>", e, i+1, test.statuses[i])
+				}
+			}
+		})
+	}
+}
+
+func TestClientDoRetryNetworkError(t *testing.T) {
+	var attempts int32
+	hc := &http.Client{Transport: RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if atomic.AddInt32(&attempts, 1) < 2 {
+			return nil, errors.New("connection reset by peer")
+		}
+		return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader("")), Request: r}, nil
+	})}
+
+	c, err := NewClient(WithHTTPClient(hc), WithRetry(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := c.Send(context.Background(), "POST", "http://fake.com/", Limit(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if !reflect.DeepEqual(attempts, int32(2)) {
+		t.Errorf("got: <%v>, want: <%v>", attempts, 2)
+	}
+}
+
+func TestRetryAttempt(t *testing.T) {
+	var got []int
+	hc := &http.Client{Transport: RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		got = append(got, RetryAttempt(r.Context()))
+		return &http.Response{StatusCode: 503, Body: ioutil.NopCloser(strings.NewReader("")), Request: r}, nil
+	})}
+
+	c, err := NewClient(WithHTTPClient(hc), WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := c.Send(context.Background(), "POST", "http://fake.com/", Limit(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got: <%v>, want: <%v>", got, want)
+	}
+
+	if n := RetryAttempt(context.Background()); n != 0 {
+		t.Errorf("got: <%v>, want: <%v>", n, 0)
+	}
+}
+
+func TestClientDoRetryCancel(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(WithRetry(RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = c.Send(ctx, "POST", ts.URL, Limit(5))
+	if errors.Cause(err) != context.DeadlineExceeded {
+		t.Errorf("got: <%v>, want: <%v>", err, context.DeadlineExceeded)
+	}
+}
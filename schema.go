@@ -0,0 +1,447 @@
+package apicalypse
+
+import (
+	"encoding/json"
+	"github.com/Henry-Sarabia/blank"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrUnknownEndpoint occurs when an endpoint is not registered with a Schema.
+	ErrUnknownEndpoint = errors.New("endpoint is not registered with the schema")
+	// ErrUnknownField occurs when a query refers to a field that its endpoint does not have.
+	ErrUnknownField = errors.New("field does not exist")
+	// ErrNotExpandable occurs when a query expands a field that is not a reference.
+	ErrNotExpandable = errors.New("field is not a reference and cannot be expanded")
+	// ErrNotSortable occurs when a query sorts its results by a field that is not sortable.
+	ErrNotSortable = errors.New("field is not sortable")
+	// ErrTypeMismatch occurs when a query compares a field to a value of a different type.
+	ErrTypeMismatch = errors.New("value does not match the type of the field")
+)
+
+// FieldType is the type of the values held by a field.
+type FieldType int
+
+// The types of values a field can hold.
+const (
+	TypeInt FieldType = iota + 1
+	TypeFloat
+	TypeString
+	TypeBool
+)
+
+// String returns the name of the type.
+func (t FieldType) String() string {
+	switch t {
+	case TypeInt:
+		return "int"
+	case TypeFloat:
+		return "float"
+	case TypeString:
+		return "string"
+	case TypeBool:
+		return "bool"
+	}
+	return "unknown"
+}
+
+// FieldSchema describes a field of an endpoint's results.
+type FieldSchema struct {
+	// Name is the name of the field, such as "rating".
+	Name string
+	// Type is the type of the field's values. The values of a reference are the IDs
+	// of the referenced items, so the type of a reference defaults to TypeInt.
+	Type FieldType
+	// Ref is the name of the endpoint the field references. References can be
+	// expanded with a dot to reach the fields of the referenced endpoint, such as
+	// "cover.url".
+	Ref string
+	// Array is true if the field holds a list of values or references.
+	Array bool
+	// Nullable is true if the field may be null or missing from results.
+	Nullable bool
+	// Sortable is true if the results can be sorted by the field.
+	Sortable bool
+}
+
+// EndpointSchema describes an endpoint and the fields of its results.
+type EndpointSchema struct {
+	// Name is the name of the endpoint, such as "games".
+	Name   string
+	Fields []FieldSchema
+}
+
+// field returns the field with the provided name.
+func (e EndpointSchema) field(name string) (FieldSchema, bool) {
+	for _, f := range e.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return FieldSchema{}, false
+}
+
+// Schema describes the endpoints of an API so that queries can be checked for
+// unknown fields, mismatched types, and unsortable fields before they are sent.
+// A Schema is safe for concurrent use.
+type Schema struct {
+	mu        sync.RWMutex
+	endpoints map[string]EndpointSchema
+}
+
+// NewSchema returns an empty Schema. Use Register to add endpoints to it.
+func NewSchema() *Schema {
+	return &Schema{endpoints: map[string]EndpointSchema{}}
+}
+
+// Register adds the provided endpoints to the schema, replacing any registered
+// endpoints of the same name. References to endpoints that are not registered
+// are allowed but cannot be expanded until the referenced endpoint is registered.
+func (s *Schema) Register(endpoints ...EndpointSchema) error {
+	for _, e := range endpoints {
+		if err := validateEndpoint(e); err != nil {
+			return errors.Wrapf(err, "cannot register endpoint '%s'", e.Name)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range endpoints {
+		fields := make([]FieldSchema, len(e.Fields))
+		for i, f := range e.Fields {
+			if f.Ref != "" && f.Type == 0 {
+				f.Type = TypeInt
+			}
+			fields[i] = f
+		}
+		s.endpoints[e.Name] = EndpointSchema{Name: e.Name, Fields: fields}
+	}
+
+	return nil
+}
+
+// validateEndpoint reports the first problem with the provided endpoint.
+func validateEndpoint(e EndpointSchema) error {
+	if blank.Is(e.Name) {
+		return ErrBlankArgument
+	}
+
+	seen := map[string]bool{}
+	for _, f := range e.Fields {
+		if blank.Is(f.Name) || strings.ContainsAny(f.Name, ".,;*\"") || f.Name != strings.TrimSpace(f.Name) {
+			return errors.Errorf("invalid field name '%s'", f.Name)
+		}
+		if seen[f.Name] {
+			return errors.Errorf("field '%s' is repeated", f.Name)
+		}
+		seen[f.Name] = true
+
+		if f.Type == 0 && f.Ref == "" {
+			return errors.Errorf("field '%s' is missing a type", f.Name)
+		}
+		if f.Type < 0 || f.Type > TypeBool {
+			return errors.Errorf("field '%s' has an unknown type", f.Name)
+		}
+	}
+
+	return nil
+}
+
+// Endpoint returns the registered endpoint with the provided name and whether it
+// was found.
+func (s *Schema) Endpoint(name string) (EndpointSchema, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.endpoints[name]
+	return e, ok
+}
+
+// Endpoints returns the names of the registered endpoints in alphabetical order.
+func (s *Schema) Endpoints() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.endpoints))
+	for n := range s.endpoints {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// schemaDoc is the document format of a Schema, which is written in YAML or JSON:
+//
+//	endpoints:
+//	  - name: games
+//	    fields:
+//	      - {name: name, type: string, sortable: true}
+//	      - {name: cover, ref: covers, nullable: true}
+//	      - {name: genres, ref: genres, array: true}
+//
+// This is the same format read by the apicalypse-gen command.
+type schemaDoc struct {
+	Endpoints []endpointDoc `yaml:"endpoints" json:"endpoints"`
+}
+
+// endpointDoc is the document format of an EndpointSchema.
+type endpointDoc struct {
+	Name   string     `yaml:"name" json:"name"`
+	Fields []fieldDoc `yaml:"fields,omitempty" json:"fields,omitempty"`
+}
+
+// fieldDoc is the document format of a FieldSchema. The type of a reference is
+// omitted since it is always an integer ID.
+type fieldDoc struct {
+	Name     string `yaml:"name" json:"name"`
+	Type     string `yaml:"type,omitempty" json:"type,omitempty"`
+	Ref      string `yaml:"ref,omitempty" json:"ref,omitempty"`
+	Array    bool   `yaml:"array,omitempty" json:"array,omitempty"`
+	Nullable bool   `yaml:"nullable,omitempty" json:"nullable,omitempty"`
+	Sortable bool   `yaml:"sortable,omitempty" json:"sortable,omitempty"`
+}
+
+// ParseSchema parses a Schema written in YAML or JSON in the format produced by
+// encoding a Schema with either format, which is also the format read by the
+// apicalypse-gen command. Field types are written as int, float, string, or bool.
+func ParseSchema(data []byte) (*Schema, error) {
+	var doc schemaDoc
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrap(err, "cannot parse schema")
+	}
+
+	var endpoints []EndpointSchema
+	for _, ed := range doc.Endpoints {
+		e := EndpointSchema{Name: ed.Name}
+		for _, fd := range ed.Fields {
+			f := FieldSchema{Name: fd.Name, Ref: fd.Ref, Array: fd.Array, Nullable: fd.Nullable, Sortable: fd.Sortable}
+			if fd.Type != "" {
+				t, ok := fieldTypeNames[fd.Type]
+				if !ok {
+					return nil, errors.Errorf("endpoint '%s': field '%s' has unknown type '%s'", ed.Name, fd.Name, fd.Type)
+				}
+				f.Type = t
+			}
+			e.Fields = append(e.Fields, f)
+		}
+		endpoints = append(endpoints, e)
+	}
+
+	s := NewSchema()
+	if err := s.Register(endpoints...); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// fieldTypeNames maps the name of each FieldType to the type.
+var fieldTypeNames = map[string]FieldType{
+	"int":    TypeInt,
+	"float":  TypeFloat,
+	"string": TypeString,
+	"bool":   TypeBool,
+}
+
+// doc returns the document format of the schema with its endpoints in
+// alphabetical order.
+func (s *Schema) doc() schemaDoc {
+	var doc schemaDoc
+	for _, name := range s.Endpoints() {
+		e, _ := s.Endpoint(name)
+
+		ed := endpointDoc{Name: e.Name}
+		for _, f := range e.Fields {
+			fd := fieldDoc{Name: f.Name, Ref: f.Ref, Array: f.Array, Nullable: f.Nullable, Sortable: f.Sortable}
+			if f.Ref == "" {
+				fd.Type = f.Type.String()
+			}
+			ed.Fields = append(ed.Fields, fd)
+		}
+		doc.Endpoints = append(doc.Endpoints, ed)
+	}
+
+	return doc
+}
+
+// MarshalJSON encodes the schema in the format read by ParseSchema.
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.doc())
+}
+
+// MarshalYAML encodes the schema in the format read by ParseSchema. Each field is
+// written on a single line.
+func (s *Schema) MarshalYAML() (interface{}, error) {
+	var n yaml.Node
+	if err := n.Encode(s.doc()); err != nil {
+		return nil, err
+	}
+
+	// The document is a mapping holding the list of endpoints, each of which is a
+	// mapping holding its list of fields.
+	for _, e := range n.Content[1].Content {
+		for i := 0; i+1 < len(e.Content); i += 2 {
+			if e.Content[i].Value != "fields" {
+				continue
+			}
+			for _, f := range e.Content[i+1].Content {
+				f.Style = yaml.FlowStyle
+			}
+		}
+	}
+
+	return &n, nil
+}
+
+// QueryFor is like Query but first validates the query against the provided
+// endpoint of the schema. See Schema.Validate for the checks that are made.
+func QueryFor(s *Schema, endpoint string, opts ...Option) (string, error) {
+	filters, err := queryFilters(opts...)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.validate(endpoint, filters); err != nil {
+		return "", errors.Wrapf(err, "invalid query for endpoint '%s'", endpoint)
+	}
+
+	return toString(filters), nil
+}
+
+// Validate checks the query built from the provided options against the provided
+// endpoint. It rejects fields in the fields, exclude, sort, and where clauses that
+// the endpoint does not have, expansions of fields that are not references, sorts
+// by fields that are not sortable, and comparisons in the where clause between a
+// field and a value of a different type, such as a string compared to an integer.
+// The cause of the returned error is one of the schema's Err variables or, if the
+// where clause cannot be parsed, a syntax error.
+func (s *Schema) Validate(endpoint string, opts ...Option) error {
+	filters, err := queryFilters(opts...)
+	if err != nil {
+		return err
+	}
+
+	return s.validate(endpoint, filters)
+}
+
+// validate checks the provided filters against the provided endpoint.
+func (s *Schema) validate(endpoint string, filters map[string]string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.endpoints[endpoint]; !ok {
+		return errors.Wrapf(ErrUnknownEndpoint, "cannot validate '%s'", endpoint)
+	}
+
+	for _, k := range []string{"fields", "exclude"} {
+		v, ok := filters[k]
+		if !ok {
+			continue
+		}
+		for _, path := range strings.Split(v, ",") {
+			if _, err := s.resolve(endpoint, strings.TrimSpace(path), true); err != nil {
+				return errors.Wrapf(err, "%s clause", k)
+			}
+		}
+	}
+
+	if v, ok := filters["sort"]; ok {
+		keys, err := ParseSort(v)
+		if err != nil {
+			return errors.Wrap(err, "sort clause")
+		}
+		for _, k := range keys {
+			f, err := s.resolve(endpoint, k.Field, false)
+			if err != nil {
+				return errors.Wrap(err, "sort clause")
+			}
+			if !f.Sortable {
+				return errors.Wrapf(ErrNotSortable, "sort clause: '%s'", k.Field)
+			}
+		}
+	}
+
+	if v, ok := filters["where"]; ok {
+		comps, err := parseWhere(v)
+		if err != nil {
+			return errors.Wrap(err, "where clause")
+		}
+		for _, c := range comps {
+			f, err := s.resolve(endpoint, c.field, false)
+			if err != nil {
+				return errors.Wrap(err, "where clause")
+			}
+			if err := checkComparison(f, c); err != nil {
+				return errors.Wrapf(err, "where clause: '%s'", c.field)
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolve returns the field at the provided path, such as "cover.url", starting
+// from the provided endpoint. A wildcard may end the path if allowed, in which
+// case the returned field is empty.
+func (s *Schema) resolve(endpoint, path string, wildcard bool) (FieldSchema, error) {
+	e := s.endpoints[endpoint]
+	segs := strings.Split(path, ".")
+
+	for i, seg := range segs {
+		if seg == "*" && wildcard && i == len(segs)-1 {
+			return FieldSchema{}, nil
+		}
+
+		f, ok := e.field(seg)
+		if !ok {
+			return FieldSchema{}, errors.Wrapf(ErrUnknownField, "'%s' of endpoint '%s'", strings.Join(segs[:i+1], "."), e.Name)
+		}
+		if i == len(segs)-1 {
+			return f, nil
+		}
+
+		if f.Ref == "" {
+			return FieldSchema{}, errors.Wrapf(ErrNotExpandable, "'%s'", strings.Join(segs[:i+1], "."))
+		}
+		if e, ok = s.endpoints[f.Ref]; !ok {
+			return FieldSchema{}, errors.Wrapf(ErrUnknownEndpoint, "'%s' references '%s'", strings.Join(segs[:i+1], "."), f.Ref)
+		}
+	}
+
+	return FieldSchema{}, nil
+}
+
+// checkComparison reports whether the values of the provided comparison match the
+// type of the provided field.
+func checkComparison(f FieldSchema, c comparison) error {
+	if c.op == "~" && f.Type != TypeString {
+		return errors.Wrapf(ErrTypeMismatch, "operator '~' cannot be used on a field of type %v", f.Type)
+	}
+
+	for _, v := range c.values {
+		ok := false
+		switch v.kind {
+		case literalNull:
+			ok = true
+		case literalInt:
+			ok = f.Type == TypeInt || f.Type == TypeFloat
+		case literalFloat:
+			ok = f.Type == TypeFloat
+		case literalString:
+			ok = f.Type == TypeString
+		case literalBool:
+			ok = f.Type == TypeBool
+		}
+		if !ok {
+			return errors.Wrapf(ErrTypeMismatch, "field of type %v compared to %v", f.Type, v.kind)
+		}
+	}
+
+	return nil
+}
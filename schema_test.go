@@ -0,0 +1,301 @@
+package apicalypse
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"testing"
+)
+
+// testSchema returns a schema describing a few IGDB endpoints.
+func testSchema(t *testing.T) *Schema {
+	s := NewSchema()
+	err := s.Register(
+		EndpointSchema{Name: "games", Fields: []FieldSchema{
+			{Name: "id", Type: TypeInt, Sortable: true},
+			{Name: "name", Type: TypeString, Sortable: true},
+			{Name: "rating", Type: TypeFloat, Sortable: true},
+			{Name: "free", Type: TypeBool},
+			{Name: "cover", Ref: "covers"},
+			{Name: "genres", Ref: "genres", Array: true},
+			{Name: "franchise", Ref: "franchises"},
+		}},
+		EndpointSchema{Name: "covers", Fields: []FieldSchema{
+			{Name: "id", Type: TypeInt},
+			{Name: "url", Type: TypeString},
+			{Name: "width", Type: TypeInt, Sortable: true},
+		}},
+		EndpointSchema{Name: "genres", Fields: []FieldSchema{
+			{Name: "id", Type: TypeInt},
+			{Name: "name", Type: TypeString},
+		}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestSchemaRegister(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint EndpointSchema
+		wantErr  bool
+	}{
+		{"Valid endpoint", EndpointSchema{Name: "games", Fields: []FieldSchema{{Name: "id", Type: TypeInt}}}, false},
+		{"Endpoint without fields", EndpointSchema{Name: "games"}, false},
+		{"Blank name", EndpointSchema{Name: " "}, true},
+		{"Blank field name", EndpointSchema{Name: "games", Fields: []FieldSchema{{Name: "", Type: TypeInt}}}, true},
+		{"Dotted field name", EndpointSchema{Name: "games", Fields: []FieldSchema{{Name: "cover.url", Type: TypeString}}}, true},
+		{"Repeated field", EndpointSchema{Name: "games", Fields: []FieldSchema{{Name: "id", Type: TypeInt}, {Name: "id", Type: TypeInt}}}, true},
+		{"Missing type", EndpointSchema{Name: "games", Fields: []FieldSchema{{Name: "id"}}}, true},
+		{"Unknown type", EndpointSchema{Name: "games", Fields: []FieldSchema{{Name: "id", Type: 42}}}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewSchema()
+			err := s.Register(test.endpoint)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got: <%v>, want error: <%v>", err, test.wantErr)
+			}
+
+			_, ok := s.Endpoint(test.endpoint.Name)
+			if ok == test.wantErr {
+				t.Errorf("got: <%v>, want: <%v>", ok, !test.wantErr)
+			}
+		})
+	}
+}
+
+func TestSchemaEndpoints(t *testing.T) {
+	s := testSchema(t)
+
+	want := []string{"covers", "games", "genres"}
+	if got := s.Endpoints(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got: <%v>, want: <%v>", got, want)
+	}
+
+	e, ok := s.Endpoint("games")
+	if !ok {
+		t.Fatal("games endpoint is not registered")
+	}
+	if f, _ := e.field("cover"); f.Type != TypeInt {
+		t.Errorf("got: <%v>, want: <%v>", f.Type, TypeInt)
+	}
+}
+
+func TestQueryFor(t *testing.T) {
+	s := testSchema(t)
+
+	tests := []struct {
+		name     string
+		endpoint string
+		opts     []Option
+		want     string
+		wantErr  error
+	}{
+		{"No options", "games", nil, "", nil},
+		{"Known fields", "games", []Option{Fields("name", "rating", "cover.url", "genres.*")}, "fields name,rating,cover.url,genres.*; ", nil},
+		{"Wildcard", "games", []Option{Fields("*"), Exclude("free")}, "fields *; exclude free; ", nil},
+		{"Valid where", "games", []Option{Where(`rating > 80 & free = true & name ~ *"halo"* & genres = (5,12) & cover.width >= 100 & cover != null`)}, `where rating > 80 & free = true & name ~ *"halo"* & genres = (5,12) & cover.width >= 100 & cover != null; `, nil},
+		{"Integer compared to float field", "games", []Option{Where("rating >= 80")}, "where rating >= 80; ", nil},
+		{"Sortable field", "games", []Option{Sort("rating", "desc")}, "sort rating desc; ", nil},
+		{"Sortable nested field", "games", []Option{Sort("cover.width", "asc")}, "sort cover.width asc; ", nil},
+		{"Search", "games", []Option{Search("", "halo")}, `search "halo"; `, nil},
+		{"Unknown endpoint", "platforms", []Option{Fields("name")}, "", ErrUnknownEndpoint},
+		{"Unknown field", "games", []Option{Fields("name", "ratting")}, "", ErrUnknownField},
+		{"Unknown excluded field", "games", []Option{Exclude("nmae")}, "", ErrUnknownField},
+		{"Unknown nested field", "games", []Option{Fields("cover.height")}, "", ErrUnknownField},
+		{"Expanded non-reference", "games", []Option{Fields("name.id")}, "", ErrNotExpandable},
+		{"Expanded unregistered reference", "games", []Option{Fields("franchise.name")}, "", ErrUnknownEndpoint},
+		{"Unregistered reference", "games", []Option{Fields("franchise")}, "fields franchise; ", nil},
+		{"Unknown sort field", "games", []Option{Sort("popularity", "desc")}, "", ErrUnknownField},
+		{"Unsortable field", "games", []Option{Sort("free", "asc")}, "", ErrNotSortable},
+		{"Wildcard sort", "games", []Option{Sort("*", "asc")}, "", ErrInvalidFieldPath},
+		{"Multiple sortable fields", "games", []Option{SortBy(SortKey{"rating", Desc}, SortKey{"cover.width", Asc})}, "sort rating desc, cover.width asc; ", nil},
+		{"Unsortable tie-breaker", "games", []Option{SortBy(SortKey{"rating", Desc}, SortKey{"free", Asc})}, "", ErrNotSortable},
+		{"Unknown where field", "games", []Option{Where("ratng > 80")}, "", ErrUnknownField},
+		{"String compared to integer", "games", []Option{Where(`id = "5"`)}, "", ErrTypeMismatch},
+		{"Integer compared to string", "games", []Option{Where(`name = 5`)}, "", ErrTypeMismatch},
+		{"Float compared to integer", "games", []Option{Where(`id > 5.5`)}, "", ErrTypeMismatch},
+		{"Boolean compared to string", "games", []Option{Where(`name = true`)}, "", ErrTypeMismatch},
+		{"Mismatch in list", "games", []Option{Where(`genres = (5, "rpg")`)}, "", ErrTypeMismatch},
+		{"Mismatch in nested field", "games", []Option{Where(`cover.url = 5`)}, "", ErrTypeMismatch},
+		{"Case insensitive match on integer", "games", []Option{Where(`id ~ 5`)}, "", ErrTypeMismatch},
+		{"Invalid option", "games", []Option{Limit(-1)}, "", ErrNegativeInput},
+		{"Blank sort", "games", []Option{func(f map[string]string) error { f["sort"] = " "; return nil }}, "", ErrBlankArgument},
+		{"Blank sort key", "games", []Option{func(f map[string]string) error { f["sort"] = "rating desc,"; return nil }}, "", ErrBlankArgument},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := QueryFor(s, test.endpoint, test.opts...)
+			if errors.Cause(err) != test.wantErr {
+				t.Fatalf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+
+			if got != test.want {
+				t.Errorf("got: <%v>, want: <%v>", got, test.want)
+			}
+		})
+	}
+}
+
+func TestQueryForSyntaxError(t *testing.T) {
+	s := testSchema(t)
+
+	_, err := QueryFor(s, "games", Where("rating >"))
+	want := "invalid query for endpoint 'games': where clause: expected a value at end of clause"
+	if err == nil || err.Error() != want {
+		t.Errorf("got: <%v>, want: <%v>", err, want)
+	}
+}
+
+func TestQueryForNilOption(t *testing.T) {
+	s := testSchema(t)
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"Nil option", []Option{nil}},
+		{"Nil option after valid option", []Option{Fields("name"), nil}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := QueryFor(s, "games", test.opts...); err == nil {
+				t.Errorf("got: <%v>, want: <%v>", err, "an error")
+			}
+
+			if err := s.Validate("games", test.opts...); err == nil {
+				t.Errorf("got: <%v>, want: <%v>", err, "an error")
+			}
+		})
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	const (
+		rating FloatField  = "rating"
+		name   StringField = "name"
+	)
+	s := testSchema(t)
+
+	if err := s.Validate("games", rating.Gt(80), Select(name)); err != nil {
+		t.Errorf("got: <%v>, want: <%v>", err, nil)
+	}
+
+	err := s.Validate("games", StringField("rating").Eq("high"))
+	want := "where clause: 'rating': field of type float compared to a string: value does not match the type of the field"
+	if err == nil || err.Error() != want {
+		t.Errorf("got: <%v>, want: <%v>", err, want)
+	}
+}
+
+func TestParseSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		want    map[string][]FieldSchema
+		wantErr bool
+	}{
+		{
+			"YAML",
+			"endpoints:\n  - name: games\n    fields:\n      - {name: name, type: string, sortable: true, nullable: true}\n      - {name: genres, ref: genres, array: true}\n",
+			map[string][]FieldSchema{"games": {
+				{Name: "name", Type: TypeString, Nullable: true, Sortable: true},
+				{Name: "genres", Type: TypeInt, Ref: "genres", Array: true},
+			}},
+			false,
+		},
+		{
+			"JSON",
+			`{"endpoints": [{"name": "covers", "fields": [{"name": "width", "type": "int"}]}, {"name": "empty"}]}`,
+			map[string][]FieldSchema{"covers": {{Name: "width", Type: TypeInt}}, "empty": {}},
+			false,
+		},
+		{"Malformed", "endpoints: [", nil, true},
+		{"Unknown type", "endpoints: [{name: games, fields: [{name: a, type: date}]}]", nil, true},
+		{"Missing type", "endpoints: [{name: games, fields: [{name: a}]}]", nil, true},
+		{"Blank endpoint", "endpoints: [{name: ''}]", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := ParseSchema([]byte(test.doc))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got: <%v>, want error: <%v>", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+
+			for name, fields := range test.want {
+				e, ok := s.Endpoint(name)
+				if !ok {
+					t.Fatalf("got: <%v>, want: <%v>", s.Endpoints(), name)
+				}
+
+				if !reflect.DeepEqual(e.Fields, fields) {
+					t.Errorf("got: <%v>, want: <%v>", e.Fields, fields)
+				}
+			}
+		})
+	}
+}
+
+func TestSchemaMarshal(t *testing.T) {
+	s := NewSchema()
+	err := s.Register(
+		EndpointSchema{Name: "games", Fields: []FieldSchema{
+			{Name: "name", Type: TypeString, Sortable: true},
+			{Name: "cover", Ref: "covers", Nullable: true},
+		}},
+		EndpointSchema{Name: "covers", Fields: []FieldSchema{
+			{Name: "sizes", Type: TypeInt, Array: true},
+		}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantYAML := `endpoints:
+    - name: covers
+      fields:
+        - {name: sizes, type: int, array: true}
+    - name: games
+      fields:
+        - {name: name, type: string, sortable: true}
+        - {name: cover, ref: covers, nullable: true}
+`
+	y, err := yaml.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(y) != wantYAML {
+		t.Errorf("got: <%v>, want: <%v>", string(y), wantYAML)
+	}
+
+	wantJSON := `{"endpoints":[{"name":"covers","fields":[{"name":"sizes","type":"int","array":true}]},{"name":"games","fields":[{"name":"name","type":"string","sortable":true},{"name":"cover","ref":"covers","nullable":true}]}]}`
+	j, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(j) != wantJSON {
+		t.Errorf("got: <%v>, want: <%v>", string(j), wantJSON)
+	}
+
+	for _, b := range [][]byte{y, j} {
+		parsed, err := ParseSchema(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range s.Endpoints() {
+			got, _ := parsed.Endpoint(name)
+			want, _ := s.Endpoint(name)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got: <%v>, want: <%v>", got, want)
+			}
+		}
+	}
+}
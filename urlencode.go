@@ -0,0 +1,178 @@
+package apicalypse
+
+import (
+	"github.com/Henry-Sarabia/blank"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrUnknownEncoding occurs when an Encoding other than BodyEncoding or URLEncoding is used.
+var ErrUnknownEncoding = errors.New("unknown encoding")
+
+// Encoding is the way the query options of a request are written into it.
+type Encoding int
+
+const (
+	// BodyEncoding writes the query into the body of the request. This is the
+	// default and the only encoding supported by every Apicalypse API.
+	BodyEncoding Encoding = iota
+	// URLEncoding writes each clause of the query as a parameter of the request's
+	// url, such as "?fields=name,rating&limit=10", and leaves the body empty. Use it
+	// when the body of a request cannot be sent, such as from a browser or through
+	// a proxy or CDN that drops the bodies of GET requests.
+	URLEncoding
+)
+
+// String returns the name of the encoding.
+func (e Encoding) String() string {
+	switch e {
+	case BodyEncoding:
+		return "body"
+	case URLEncoding:
+		return "url"
+	}
+	return "unknown"
+}
+
+// NewEncodedRequest returns a request configured for the provided url using the
+// provided method, with the provided query options written into it according to
+// the provided encoding. NewRequest is equivalent to NewEncodedRequest with
+// BodyEncoding.
+func NewEncodedRequest(method, url string, enc Encoding, opts ...Option) (*http.Request, error) {
+	method, err := normalizeMethod(method)
+	if err != nil {
+		return nil, err
+	}
+
+	switch enc {
+	case BodyEncoding:
+		return NewRequest(method, url, opts...)
+	case URLEncoding:
+	default:
+		return nil, ErrUnknownEncoding
+	}
+
+	u, err := EncodeURL(url, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create request with method '%s' for url '%s'", method, u)
+	}
+
+	return req, nil
+}
+
+// EncodeURL returns the provided url with each clause of the query built from the
+// provided options added as a url parameter. Clauses are added in the same order
+// Query writes them, and their values are percent-encoded with spaces written as
+// "%20" rather than "+". Parameters already in the url are kept unless they name
+// one of the clauses, in which case they are replaced.
+func EncodeURL(rawurl string, opts ...Option) (string, error) {
+	if blank.Is(rawurl) {
+		return "", ErrBlankArgument
+	}
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot parse url '%s'", rawurl)
+	}
+
+	filters, err := queryFilters(opts...)
+	if err != nil {
+		return "", err
+	}
+
+	u.RawQuery = encodeParams(u.RawQuery, filters, nil)
+
+	return u.String(), nil
+}
+
+// encodeParams returns the provided raw url query with the provided filters added
+// as parameters in canonical order. Existing parameters are kept unless they name
+// one of the filters or satisfy drop.
+func encodeParams(rawQuery string, filters map[string]string, drop func(key string) bool) string {
+	var params []string
+	for _, p := range strings.Split(rawQuery, "&") {
+		if p == "" {
+			continue
+		}
+		k, _ := url.QueryUnescape(strings.SplitN(p, "=", 2)[0])
+		if _, ok := filters[k]; ok || (drop != nil && drop(k)) {
+			continue
+		}
+		params = append(params, p)
+	}
+
+	for _, k := range clauseKeys(filters) {
+		params = append(params, escapeParam(k)+"="+escapeParam(filters[k]))
+	}
+
+	return strings.Join(params, "&")
+}
+
+// escapeParam percent-encodes a url parameter key or value.
+func escapeParam(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+// queryFilters returns the filters built from the provided options, rejecting nil
+// options as Query does.
+func queryFilters(opts ...Option) (map[string]string, error) {
+	for _, opt := range opts {
+		if opt == nil {
+			return nil, errors.New("a provided option is nil")
+		}
+	}
+
+	filters, err := newFilters(opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create new filter map")
+	}
+
+	return filters, nil
+}
+
+// ParseURL returns the options encoded as parameters of the provided url, such as
+// those written by EncodeURL. Parameters that do not name a clause are ignored.
+func ParseURL(rawurl string) ([]Option, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse url '%s'", rawurl)
+	}
+
+	v, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse parameters of url '%s'", rawurl)
+	}
+
+	return ParseValues(v)
+}
+
+// ParseValues returns the options encoded as the provided url parameters, such as
+// those of an incoming request's url. Parameters that do not name a clause are
+// ignored. The options are returned in the same order Query writes their clauses.
+func ParseValues(v url.Values) ([]Option, error) {
+	var opts []Option
+	for _, k := range clauseOrder {
+		vals, ok := v[k]
+		if !ok {
+			continue
+		}
+		if len(vals) > 1 {
+			return nil, errors.Errorf("clause '%s' is repeated", k)
+		}
+
+		opt, err := ParseClause(k, vals[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s parameter", k)
+		}
+		opts = append(opts, opt)
+	}
+
+	return opts, nil
+}
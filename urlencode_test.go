@@ -0,0 +1,196 @@
+package apicalypse
+
+import (
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestEncodeURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		opts    []Option
+		wantURL string
+		wantErr error
+	}{
+		{"Zero options", "https://api.com/games", nil, "https://api.com/games", nil},
+		{
+			"Multiple options",
+			"https://api.com/games",
+			[]Option{Limit(10), Fields("name", "age"), Where(`name = "a&b c"`, "rating > 80")},
+			"https://api.com/games?fields=name%2Cage&where=name%20%3D%20%22a%26b%20c%22%20%26%20rating%20%3E%2080&limit=10",
+			nil,
+		},
+		{
+			"Existing parameters",
+			"https://api.com/games?key=abc&limit=5",
+			[]Option{Limit(10)},
+			"https://api.com/games?key=abc&limit=10",
+			nil,
+		},
+		{"Plus sign", "https://api.com/games", []Option{Search("", "c++")}, "https://api.com/games?search=%22c%2B%2B%22", nil},
+		{"Blank url", " ", []Option{Limit(10)}, "", ErrBlankArgument},
+		{"Invalid option", "https://api.com/games", []Option{Limit(-1)}, "", ErrNegativeInput},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := EncodeURL(test.url, test.opts...)
+			if errors.Cause(err) != test.wantErr {
+				t.Errorf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+
+			if got != test.wantURL {
+				t.Errorf("got: <%v>, want: <%v>", got, test.wantURL)
+			}
+		})
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		wantQuery string
+		wantErr   bool
+	}{
+		{"No parameters", "https://api.com/games", "", false},
+		{
+			"Encoded parameters",
+			"https://api.com/games?fields=name%2Cage&where=name%20%3D%20%22a%26b%20c%22&limit=10&key=abc",
+			`fields name,age; where name = "a&b c"; limit 10; `,
+			false,
+		},
+		{"Plus as space", "https://api.com/games?sort=name+desc&search=%22halo%22", `search "halo"; sort name desc; `, false},
+		{"Repeated clause", "https://api.com/games?limit=1&limit=2", "", true},
+		{"Invalid clause", "https://api.com/games?limit=ten", "", true},
+		{"Invalid url", "https://api.com/games?limit=%zz", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts, err := ParseURL(test.url)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got: <%v>, want error: <%v>", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			got, err := Query(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != test.wantQuery {
+				t.Errorf("got: <%v>, want: <%v>", got, test.wantQuery)
+			}
+		})
+	}
+}
+
+func TestEncodeURLRoundTrip(t *testing.T) {
+	opts := []Option{
+		Fields("name", "cover.url"),
+		Where(`name ~ *"100%"*`, "genres = (4,5)"),
+		Search("name", "a + b"),
+		Sort("rating", "desc"),
+		Limit(50),
+		Offset(100),
+	}
+
+	want, err := Query(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := EncodeURL("https://api.com/games", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseURL(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Query(parsed...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != want {
+		t.Errorf("got: <%v>, want: <%v>", got, want)
+	}
+}
+
+func TestNewEncodedRequest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(r.URL.RawQuery + "|" + string(b)))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		method   string
+		enc      Encoding
+		wantBody string
+		wantErr  error
+	}{
+		{"Body encoding", "GET", BodyEncoding, "|limit 5; ", nil},
+		{"URL encoding", "GET", URLEncoding, "limit=5|", nil},
+		{"Unknown encoding", "GET", Encoding(9), "", ErrUnknownEncoding},
+		{"Lowercase method with body encoding", "get", BodyEncoding, "|limit 5; ", nil},
+		{"Lowercase method with URL encoding", "get", URLEncoding, "limit=5|", nil},
+		{"Invalid method with body encoding", "delete", BodyEncoding, "", ErrInvalidMethod},
+		{"Invalid method with URL encoding", "delete", URLEncoding, "", ErrInvalidMethod},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := NewEncodedRequest(test.method, ts.URL, test.enc, Limit(5))
+			if errors.Cause(err) != test.wantErr {
+				t.Fatalf("got: <%v>, want: <%v>", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			b, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != test.wantBody {
+				t.Errorf("got: <%v>, want: <%v>", string(b), test.wantBody)
+			}
+		})
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	v := url.Values{"limit": {"5"}, "fields": {"name"}, "page": {"2"}}
+
+	opts, err := ParseValues(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Query(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "fields name; limit 5; "
+	if got != want {
+		t.Errorf("got: <%v>, want: <%v>", got, want)
+	}
+}
@@ -0,0 +1,295 @@
+package apicalypse
+
+import (
+	"github.com/pkg/errors"
+	"strings"
+	"unicode"
+)
+
+// literalKind is the kind of a literal value in a where clause.
+type literalKind int
+
+const (
+	literalNull literalKind = iota
+	literalInt
+	literalFloat
+	literalString
+	literalBool
+)
+
+// String returns the name of the kind.
+func (k literalKind) String() string {
+	switch k {
+	case literalInt:
+		return "an integer"
+	case literalFloat:
+		return "a float"
+	case literalString:
+		return "a string"
+	case literalBool:
+		return "a boolean"
+	}
+	return "null"
+}
+
+// literal is a literal value in a where clause.
+type literal struct {
+	kind literalKind
+	text string
+	// start and end are the byte offsets of the value within the clause,
+	// including any quotes and wildcards.
+	start int
+	end   int
+}
+
+// comparison is a single comparison of a field to one or more values in a
+// where clause, such as "rating > 80" or "genres = (5,12)".
+type comparison struct {
+	field  string
+	op     string
+	values []literal
+}
+
+// tokenKind is the kind of a token in a where clause.
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOp
+	tokenPunct
+)
+
+// token is a lexical token in a where clause.
+type token struct {
+	kind  tokenKind
+	text  string
+	start int
+	end   int
+}
+
+// whereOperators are the comparison operators, longest first.
+var whereOperators = []string{"!=", ">=", "<=", "=", ">", "<", "~"}
+
+// lexWhere splits a where clause into tokens.
+func lexWhere(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		r := rune(s[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return nil, errors.Errorf("unterminated string at offset %d", i)
+			}
+			toks = append(toks, token{tokenString, s[i+1 : j], i, j + 1})
+			i = j + 1
+		case r == '-' || r == '.' || unicode.IsDigit(r):
+			j := i + 1
+			for j < len(s) && (s[j] == '.' || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			toks = append(toks, token{tokenNumber, s[i:j], i, j})
+			i = j
+		case r == '_' || unicode.IsLetter(r):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || s[j] == '.' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			toks = append(toks, token{tokenIdent, s[i:j], i, j})
+			i = j
+		default:
+			op := ""
+			for _, o := range whereOperators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op != "" {
+				toks = append(toks, token{tokenOp, op, i, i + len(op)})
+				i += len(op)
+				continue
+			}
+			if !strings.ContainsRune("()[]{},&|!*", r) {
+				return nil, errors.Errorf("unexpected '%c' at offset %d", r, i)
+			}
+			toks = append(toks, token{tokenPunct, string(r), i, i + 1})
+			i++
+		}
+	}
+
+	return append(toks, token{kind: tokenEOF, start: len(s), end: len(s)}), nil
+}
+
+// whereParser parses the tokens of a where clause.
+type whereParser struct {
+	toks        []token
+	pos         int
+	comparisons []comparison
+}
+
+// parseWhere parses a where clause and returns its comparisons in the order
+// they appear. Comparisons may be combined with & and |, grouped with
+// parentheses, and negated with !.
+func parseWhere(s string) ([]comparison, error) {
+	toks, err := lexWhere(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &whereParser{toks: toks}
+	if err := p.expr(); err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, errors.Errorf("unexpected '%s' at offset %d", t.text, t.start)
+	}
+
+	return p.comparisons, nil
+}
+
+// peek returns the current token.
+func (p *whereParser) peek() token {
+	return p.toks[p.pos]
+}
+
+// next returns the current token and advances to the next one.
+func (p *whereParser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept advances past the current token if it is the provided punctuation.
+func (p *whereParser) accept(punct string) bool {
+	if t := p.peek(); t.kind == tokenPunct && t.text == punct {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expr parses comparisons joined by & or |.
+func (p *whereParser) expr() error {
+	for {
+		if err := p.term(); err != nil {
+			return err
+		}
+		if !p.accept("&") && !p.accept("|") {
+			return nil
+		}
+	}
+}
+
+// term parses a comparison or a parenthesized expression, either of which may be
+// negated.
+func (p *whereParser) term() error {
+	p.accept("!")
+
+	if p.accept("(") {
+		if err := p.expr(); err != nil {
+			return err
+		}
+		if !p.accept(")") {
+			return p.unexpected("')'")
+		}
+		return nil
+	}
+
+	t := p.peek()
+	if t.kind != tokenIdent {
+		return p.unexpected("a field")
+	}
+	p.next()
+
+	op := p.peek()
+	if op.kind != tokenOp {
+		return p.unexpected("an operator")
+	}
+	p.next()
+
+	c := comparison{field: t.text, op: op.text}
+	p.accept("!")
+
+	closer := map[string]string{"(": ")", "[": "]", "{": "}"}
+	if t := p.peek(); t.kind == tokenPunct && closer[t.text] != "" {
+		p.next()
+		for {
+			l, err := p.literal()
+			if err != nil {
+				return err
+			}
+			c.values = append(c.values, l)
+			if !p.accept(",") {
+				break
+			}
+		}
+		if !p.accept(closer[t.text]) {
+			return p.unexpected("'" + closer[t.text] + "'")
+		}
+	} else {
+		l, err := p.literal()
+		if err != nil {
+			return err
+		}
+		c.values = append(c.values, l)
+	}
+
+	p.comparisons = append(p.comparisons, c)
+	return nil
+}
+
+// literal parses a literal value. Strings may be preceded or followed by a
+// wildcard.
+func (p *whereParser) literal() (literal, error) {
+	start := p.peek().start
+	prefix := p.accept("*")
+
+	t := p.peek()
+	l := literal{text: t.text, start: start, end: t.end}
+	switch {
+	case t.kind == tokenString:
+		l.kind = literalString
+	case prefix:
+		return literal{}, p.unexpected("a string")
+	case t.kind == tokenNumber && strings.Contains(t.text, "."):
+		l.kind = literalFloat
+	case t.kind == tokenNumber:
+		l.kind = literalInt
+	case t.kind == tokenIdent && t.text == "null":
+		l.kind = literalNull
+	case t.kind == tokenIdent && (t.text == "true" || t.text == "false"):
+		l.kind = literalBool
+	default:
+		return literal{}, p.unexpected("a value")
+	}
+	p.next()
+
+	if l.kind == literalString && p.accept("*") {
+		l.end++
+	}
+
+	return l, nil
+}
+
+// unexpected returns an error describing the current token and what was expected
+// in its place.
+func (p *whereParser) unexpected(want string) error {
+	t := p.peek()
+	if t.kind == tokenEOF {
+		return errors.Errorf("expected %s at end of clause", want)
+	}
+	return errors.Errorf("expected %s but found '%s' at offset %d", want, t.text, t.start)
+}
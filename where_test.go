@@ -0,0 +1,62 @@
+package apicalypse
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseWhere(t *testing.T) {
+	tests := []struct {
+		name    string
+		where   string
+		want    []comparison
+		wantErr string
+	}{
+		{"Integer", "rating > 80", []comparison{{"rating", ">", []literal{{literalInt, "80", 9, 11}}}}, ""},
+		{"Negative float", "score <= -1.5", []comparison{{"score", "<=", []literal{{literalFloat, "-1.5", 9, 13}}}}, ""},
+		{"String with wildcards", `name ~ *"halo"*`, []comparison{{"name", "~", []literal{{literalString, "halo", 7, 15}}}}, ""},
+		{"Escaped string", `name = "a \"b\""`, []comparison{{"name", "=", []literal{{literalString, `a \"b\"`, 7, 16}}}}, ""},
+		{"Null and boolean", "cover != null & free = true", []comparison{
+			{"cover", "!=", []literal{{literalNull, "null", 9, 13}}},
+			{"free", "=", []literal{{literalBool, "true", 23, 27}}},
+		}, ""},
+		{"List", "genres = !(5, 12)", []comparison{{"genres", "=", []literal{{literalInt, "5", 11, 12}, {literalInt, "12", 14, 16}}}}, ""},
+		{"Bracket and brace lists", "a = [1] | b = {2}", []comparison{
+			{"a", "=", []literal{{literalInt, "1", 5, 6}}},
+			{"b", "=", []literal{{literalInt, "2", 15, 16}}},
+		}, ""},
+		{"Nested groups", "!(a.b = 1 | (c = 2 & d = 3))", []comparison{
+			{"a.b", "=", []literal{{literalInt, "1", 8, 9}}},
+			{"c", "=", []literal{{literalInt, "2", 17, 18}}},
+			{"d", "=", []literal{{literalInt, "3", 25, 26}}},
+		}, ""},
+		{"Missing value", "rating >", nil, "expected a value at end of clause"},
+		{"Missing operator", "rating 80", nil, "expected an operator but found '80'"},
+		{"Missing field", "= 80", nil, "expected a field but found '='"},
+		{"Unclosed group", "(a = 1", nil, "expected ')' at end of clause"},
+		{"Unclosed list", "a = (1, 2", nil, "expected ')' at end of clause"},
+		{"Wildcard without string", "a = *1", nil, "expected a string but found '1'"},
+		{"Unterminated string", `a = "x`, nil, "unterminated string"},
+		{"Unexpected character", "a = 1 ; b = 2", nil, "unexpected ';'"},
+		{"Trailing token", "a = 1 b", nil, "unexpected 'b'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseWhere(test.where)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("got: <%v>, want: <%v>", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got: <%v>, want: <%v>", got, test.want)
+			}
+		})
+	}
+}